@@ -14,8 +14,9 @@
 package apbq
 
 import (
-	"fmt"
+	"context"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -24,30 +25,42 @@ import (
 
 const name = "abpq"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "apbq",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostFast,
+		Requires:   attacks.Requirements{Hints: 2},
+		F:          Attack,
+	})
+}
+
 // Attack implements the abpq method against a ciphertext.
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 	var x, y int64
 	k := ks[0]
 	if k.Hints == nil || len(k.Hints) < 2 {
-		ch <- fmt.Errorf("invalid arguments for attack %s: this attack requires 2 hints", name)
-		return
+		return attacks.NotApplicable("invalid arguments for attack %s: this attack requires 2 hints", name)
 	}
 
 	if k.BruteMax == 0 {
-		ch <- fmt.Errorf("invalid arguments for attack %s: this attack requires a maximum value > 0 to brute force for x and y", name)
-		return
+		return attacks.NotApplicable("invalid arguments for attack %s: this attack requires a maximum value > 0 to brute force for x and y", name)
 	}
 
 	for x = 1; x <= k.BruteMax; x++ {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		for y = 1; y <= k.BruteMax; y++ {
 			kq := new(fmp.Fmpz).GCD(fmp.NewFmpz(x).MulZ(k.Hints[0]).SubZ(fmp.NewFmpz(y).MulZ(k.Hints[1])), k.Key.N)
 			if kq.Cmp(ln.BigOne) > 0 {
 				k.PackGivenP(kq)
-				ch <- nil
-				return
+				return attacks.Solved(k)
 			}
 		}
 	}
 
-	ch <- fmt.Errorf("%s was unable to factor the key", name)
+	return attacks.Failed("%s was unable to factor the key", name)
 }
@@ -6,96 +6,35 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/sourcekris/goRsaTool/attacks/apbq"
-	"github.com/sourcekris/goRsaTool/attacks/brokenrsa"
-	"github.com/sourcekris/goRsaTool/attacks/commonfactor"
-	"github.com/sourcekris/goRsaTool/attacks/commonmodulus"
-	"github.com/sourcekris/goRsaTool/attacks/crt"
-	"github.com/sourcekris/goRsaTool/attacks/defectivee"
-	"github.com/sourcekris/goRsaTool/attacks/factordb"
-	"github.com/sourcekris/goRsaTool/attacks/fermat"
-	"github.com/sourcekris/goRsaTool/attacks/franklinreiter"
-	"github.com/sourcekris/goRsaTool/attacks/gmpecm"
-	"github.com/sourcekris/goRsaTool/attacks/hastads"
-	"github.com/sourcekris/goRsaTool/attacks/hastadsbroadcast"
-	"github.com/sourcekris/goRsaTool/attacks/knownprime"
-	"github.com/sourcekris/goRsaTool/attacks/londahl"
-	"github.com/sourcekris/goRsaTool/attacks/manysmallprimes"
-	"github.com/sourcekris/goRsaTool/attacks/notableprimes"
-	"github.com/sourcekris/goRsaTool/attacks/oraclemodulus"
-	"github.com/sourcekris/goRsaTool/attacks/partiald"
-	"github.com/sourcekris/goRsaTool/attacks/pastctfprimes"
-	"github.com/sourcekris/goRsaTool/attacks/pollardrhobrent"
-	"github.com/sourcekris/goRsaTool/attacks/pollardsp1"
-	"github.com/sourcekris/goRsaTool/attacks/pollardsrho"
-	"github.com/sourcekris/goRsaTool/attacks/qicheng"
-	"github.com/sourcekris/goRsaTool/attacks/smallfractions"
-	"github.com/sourcekris/goRsaTool/attacks/smallq"
-	"github.com/sourcekris/goRsaTool/attacks/squaren"
-	"github.com/sourcekris/goRsaTool/attacks/wiener"
-	"github.com/sourcekris/goRsaTool/attacks/wienermultiprime"
-	"github.com/sourcekris/goRsaTool/attacks/williamsp1"
 	"github.com/sourcekris/goRsaTool/keys"
 )
 
-// default timeout 3m0s
-const DefaultTimeout int = 180
+// DefaultTimeout is how long an attack runs for unless it registers or is given another timeout.
+const DefaultTimeout = 3 * time.Minute
 
 // SupportedAttacks stores the list of registered attacks we support.
-var SupportedAttacks *Attacks
-
-func init() {
-	SupportedAttacks = NewAttacks()
-	// TODO(sourcekris): Register them in each package init function.
-	SupportedAttacks.RegisterAttack("crtsolver", false, true, DefaultTimeout, crt.Attack)
-	SupportedAttacks.RegisterAttack("factordb", false, true, DefaultTimeout, factordb.Attack)
-	SupportedAttacks.RegisterAttack("hastads", false, true, DefaultTimeout, hastads.Attack)
-	SupportedAttacks.RegisterAttack("hastadsbroadcast", true, true, DefaultTimeout, hastadsbroadcast.Attack)
-	SupportedAttacks.RegisterAttack("commonfactors", true, true, DefaultTimeout, commonfactor.Attack)
-	SupportedAttacks.RegisterAttack("commonmodulus", true, true, DefaultTimeout, commonmodulus.Attack)
-	SupportedAttacks.RegisterAttack("partiald", false, false, DefaultTimeout, partiald.Attack)
-	SupportedAttacks.RegisterAttack("knownprime", false, false, DefaultTimeout, knownprime.Attack)
-	SupportedAttacks.RegisterAttack("brokenrsa", false, true, DefaultTimeout, brokenrsa.Attack)
-	SupportedAttacks.RegisterAttack("notableprimes", false, true, DefaultTimeout, notableprimes.Attack)
-	SupportedAttacks.RegisterAttack("pastctf", false, true, DefaultTimeout, pastctfprimes.Attack)
-	SupportedAttacks.RegisterAttack("smallq", false, true, DefaultTimeout, smallq.Attack)
-	SupportedAttacks.RegisterAttack("wiener", false, true, DefaultTimeout, wiener.Attack)
-	SupportedAttacks.RegisterAttack("wienermultiprime", false, true, DefaultTimeout, wienermultiprime.Attack)
-	SupportedAttacks.RegisterAttack("qicheng", false, true, DefaultTimeout, qicheng.Attack)
-	SupportedAttacks.RegisterAttack("fermat", false, true, DefaultTimeout, fermat.Attack)
-	SupportedAttacks.RegisterAttack("londahl", false, true, DefaultTimeout, londahl.Attack)
-	SupportedAttacks.RegisterAttack("smallfractions", false, true, DefaultTimeout, smallfractions.Attack)
-	SupportedAttacks.RegisterAttack("manysmallprimes", false, true, DefaultTimeout, manysmallprimes.Attack)
-	SupportedAttacks.RegisterAttack("ecm", false, true, DefaultTimeout, gmpecm.Attack)
-	SupportedAttacks.RegisterAttack("franklinreiter", true, true, DefaultTimeout, franklinreiter.Attack)
-	SupportedAttacks.RegisterAttack("pollardsp1", false, true, DefaultTimeout, pollardsp1.Attack)
-	SupportedAttacks.RegisterAttack("pollardsrho", false, true, 300, pollardsrho.Attack)
-	SupportedAttacks.RegisterAttack("pollardrhobrent", false, true, 300, pollardrhobrent.Attack)
-	SupportedAttacks.RegisterAttack("williamsp1", false, true, DefaultTimeout, williamsp1.Attack)
-	SupportedAttacks.RegisterAttack("defectivee", false, true, DefaultTimeout, defectivee.Attack)
-	SupportedAttacks.RegisterAttack("oraclemodulus", false, true, DefaultTimeout, oraclemodulus.Attack)
-	SupportedAttacks.RegisterAttack("squaren", false, true, DefaultTimeout, squaren.Attack)
-	SupportedAttacks.RegisterAttack("apbq", false, true, DefaultTimeout, apbq.Attack)
-
-	// Aliased attacks (names that point to attacks already in the above list).
-	SupportedAttacks.RegisterAttack("mersenne", false, false, DefaultTimeout, notableprimes.Attack)
-	SupportedAttacks.RegisterAttack("lucas", false, false, DefaultTimeout, notableprimes.Attack)
-	SupportedAttacks.RegisterAttack("novelty", false, false, DefaultTimeout, notableprimes.Attack)
-	SupportedAttacks.RegisterAttack("pastprimes", false, false, DefaultTimeout, pastctfprimes.Attack)
-	SupportedAttacks.RegisterAttack("pastctfprimes", false, false, DefaultTimeout, pastctfprimes.Attack)
-	SupportedAttacks.RegisterAttack("sexyprimes", false, false, DefaultTimeout, fermat.Attack)
-	SupportedAttacks.RegisterAttack("smalle", false, true, DefaultTimeout, hastads.Attack)
-}
+var SupportedAttacks = NewAttacks()
 
-type attackFunc func([]*keys.RSA, chan error)
+// attackFunc is the signature of an attack. Attacks must return promptly once ctx is done.
+type attackFunc func(context.Context, []*keys.RSA) *Result
 
-// Attack encodes a single attack and what features it supports.
+// Attack encodes a single attack, what features it supports and what it needs to run.
 type Attack struct {
 	Name          string
 	SupportsMulti bool
 	Unnatended    bool
-	Timeout       int
-	F             attackFunc
+	Timeout       time.Duration
+	Requires      Requirements
+	// UnnatendedRequires are further requirements the attack must meet to run in unnatended mode
+	// when it was not selected by name, for attacks that are too slow without some extra input.
+	UnnatendedRequires Requirements
+	// Cost is how expensive the attack usually is, used to order attacks in unnatended mode.
+	Cost Cost
+	// LargeE is set for attacks that target a small private exponent and so a large public one.
+	LargeE bool
+	F      attackFunc
+	// userTimeout is set when Timeout was given by the user and so is not capped by the planner.
+	userTimeout bool
 }
 
 // Attacks wraps a slice of Attack objects that are supported.
@@ -108,13 +47,118 @@ func NewAttacks() *Attacks {
 	return &Attacks{}
 }
 
-// RegisterAttack adds a new attack to the receiving Attacks.
-func (a *Attacks) RegisterAttack(name string, multi bool, unnatended bool, timeout int, f attackFunc) {
+// Register adds at to the receiving Attacks. Attack packages call this from their init function.
+func (a *Attacks) Register(at *Attack) {
 	if a == nil {
-		a = NewAttacks()
+		return
+	}
+
+	a.Supported = append(a.Supported, at)
+}
+
+// RegisterAttack adds a new attack with no input requirements to the receiving Attacks.
+func (a *Attacks) RegisterAttack(name string, multi bool, unnatended bool, timeout time.Duration, f attackFunc) {
+	a.Register(&Attack{
+		Name:          name,
+		SupportsMulti: multi,
+		Unnatended:    unnatended,
+		Timeout:       timeout,
+		F:             f,
+	})
+}
+
+// RegisterAlias adds alias as another name for the already registered attack name. Aliases are
+// never run in unnatended mode since the attack they point to already is.
+func (a *Attacks) RegisterAlias(alias, name string) {
+	at := a.Get(name)
+	if at == nil {
+		return
 	}
 
-	a.Supported = append(a.Supported, &Attack{name, multi, unnatended, timeout, f})
+	c := *at
+	c.Name = alias
+	c.Unnatended = false
+	a.Register(&c)
+}
+
+// Get returns the named attack or nil if it is not registered.
+func (a *Attacks) Get(name string) *Attack {
+	if a == nil {
+		return nil
+	}
+
+	for _, at := range a.Supported {
+		if at.Name == name {
+			return at
+		}
+	}
+
+	return nil
+}
+
+// Select returns a copy of a containing only the attacks named in include, or every attack if
+// include is empty, less any attacks named in skip. Attacks named in include run in unnatended
+// mode even when they normally do not and regardless of their UnnatendedRequires. An error is returned if any name is not registered.
+func (a *Attacks) Select(include, skip []string) (*Attacks, error) {
+	if a == nil {
+		return nil, errors.New("no attacks registered")
+	}
+
+	for _, name := range append(append([]string{}, include...), skip...) {
+		if !a.IsSupported(name) {
+			return nil, fmt.Errorf("unsupported attack: %v", name)
+		}
+	}
+
+	in := func(name string, names []string) bool {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	s := NewAttacks()
+	for _, at := range a.Supported {
+		if len(include) > 0 && !in(at.Name, include) || in(at.Name, skip) {
+			continue
+		}
+
+		c := *at
+		if len(include) > 0 {
+			c.Unnatended = true
+			c.UnnatendedRequires = Requirements{}
+		}
+		s.Register(&c)
+	}
+
+	return s, nil
+}
+
+// SetTimeout sets the timeout of every attack in a to d.
+func (a *Attacks) SetTimeout(d time.Duration) {
+	if a == nil {
+		return
+	}
+
+	for _, at := range a.Supported {
+		at.Timeout = d
+		at.userTimeout = true
+	}
+}
+
+// SetAttackTimeout sets the timeout of the named attack to d.
+func (a *Attacks) SetAttackTimeout(name string, d time.Duration) error {
+	at := a.Get(name)
+	if at == nil {
+		return fmt.Errorf("unsupported attack: %v", name)
+	}
+
+	at.Timeout = d
+	at.userTimeout = true
+	return nil
 }
 
 // IsSupported returns true if name attack is supported.
@@ -139,34 +183,45 @@ func (a *Attacks) SupportsMulti(name string) bool {
 	return false
 }
 
-// Execute executes the named attack against t.
-func (a *Attacks) Execute(name string, t []*keys.RSA) error {
-	if SupportedAttacks == nil {
-		return errors.New("no attacks registered")
+// Execute executes the named attack against t and returns its result. The attack is cancelled
+// when ctx is done or when the attack timeout expires.
+func (a *Attacks) Execute(ctx context.Context, name string, t []*keys.RSA) *Result {
+	if a == nil {
+		return Failed("no attacks registered")
 	}
 
-	if !a.IsSupported(name) {
-		return fmt.Errorf("unsupported attack: %v", name)
+	at := a.Get(name)
+	if at == nil {
+		return Failed("unsupported attack: %v", name)
 	}
 
-	for _, a := range SupportedAttacks.Supported {
-		if a.Name == name {
-			ctx := context.Background()
-			ctx, cancel := context.WithTimeout(ctx, time.Duration(a.Timeout*int(time.Second)))
-			defer cancel()
-
-			ch := make(chan error)
+	if err := at.Applicable(t); err != nil {
+		return &Result{Name: name, Status: StatusNotApplicable, Message: fmt.Sprintf("%s: %v", name, err)}
+	}
 
-			go a.F(t, ch)
+	return at.execute(ctx, t, at.Timeout)
+}
 
-			select {
-			case result := <-ch:
-				return result
-			case <-ctx.Done():
-				return fmt.Errorf("%s failed to factorize the key in the given time", name)
-			}
-		}
+// execute runs the attack against t for at most timeout and returns its result.
+func (at *Attack) execute(ctx context.Context, t []*keys.RSA, timeout time.Duration) *Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	res := at.F(ctx, t)
+
+	switch {
+	case res == nil:
+		res = Failed("%s returned no result", at.Name)
+	case res.Succeeded():
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		res = &Result{Status: StatusTimedOut, Message: fmt.Sprintf("%s failed to factorize the key in the given time", at.Name)}
+	case ctx.Err() != nil:
+		res = &Result{Status: StatusTimedOut, Message: fmt.Sprintf("%s cancelled: %v", at.Name, ctx.Err())}
 	}
 
-	return errors.New("attack not found")
+	res.Name = at.Name
+	res.Duration = time.Since(start)
+
+	return res
 }
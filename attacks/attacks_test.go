@@ -0,0 +1,536 @@
+package attacks
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+func TestExecute(t *testing.T) {
+	slow := func(ctx context.Context, _ []*keys.RSA) *Result {
+		<-ctx.Done()
+		return Stopped(ctx.Err())
+	}
+
+	nothing := func(_ context.Context, _ []*keys.RSA) *Result {
+		return NotApplicable("nothing to do")
+	}
+
+	tt := []struct {
+		name   string
+		f      attackFunc
+		req    Requirements
+		cancel bool
+		want   Status
+	}{
+		{
+			name:   "attack stops when parent context is cancelled",
+			f:      slow,
+			cancel: true,
+			want:   StatusTimedOut,
+		},
+		{
+			name: "not applicable attack is reported",
+			f:    nothing,
+			want: StatusNotApplicable,
+		},
+		{
+			name: "attack with unmet requirements is not run",
+			f:    slow,
+			req:  Requirements{CipherText: true},
+			want: StatusNotApplicable,
+		},
+	}
+
+	for _, tc := range tt {
+		a := NewAttacks()
+		a.Register(&Attack{Name: "test", Unnatended: true, Timeout: time.Minute, Requires: tc.req, F: tc.f})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if tc.cancel {
+			cancel()
+		}
+
+		k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: fmp.NewFmpz(35), E: fmp.NewFmpz(3)}), nil, nil, "", false)
+		res := a.Execute(ctx, "test", []*keys.RSA{k})
+		cancel()
+
+		if res.Status != tc.want {
+			t.Errorf("Execute() failed: %s expected status %v got %v", tc.name, tc.want, res.Status)
+		}
+
+		if res.Name != "test" {
+			t.Errorf("Execute() failed: %s expected result name to be set got %q", tc.name, res.Name)
+		}
+	}
+}
+
+func TestExecuteParallel(t *testing.T) {
+	slow := func(ctx context.Context, _ []*keys.RSA) *Result {
+		<-ctx.Done()
+		return Stopped(ctx.Err())
+	}
+
+	fast := func(_ context.Context, ks []*keys.RSA) *Result {
+		ks[0].PlainText = []byte("flag")
+		return Solved(ks[0])
+	}
+
+	broken := func(_ context.Context, _ []*keys.RSA) *Result {
+		return Failed("broken failed")
+	}
+
+	tt := []struct {
+		name    string
+		attacks map[string]attackFunc
+		workers int
+		want    string
+	}{
+		{
+			name:    "fast attack wins and slow attacks are cancelled",
+			attacks: map[string]attackFunc{"slow1": slow, "fast": fast, "slow2": slow},
+			workers: 3,
+			want:    "fast",
+		},
+		{
+			name:    "single worker still finds the winner",
+			attacks: map[string]attackFunc{"broken": broken, "fast": fast},
+			workers: 1,
+			want:    "fast",
+		},
+		{
+			name:    "no winner returns the failures",
+			attacks: map[string]attackFunc{"broken": broken},
+			workers: 2,
+		},
+	}
+
+	for _, tc := range tt {
+		a := NewAttacks()
+		for name, f := range tc.attacks {
+			a.RegisterAttack(name, false, true, time.Minute, f)
+		}
+
+		k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: fmp.NewFmpz(35), E: fmp.NewFmpz(3)}), nil, nil, "", false)
+
+		done := make(chan struct{})
+		var (
+			winner  *Result
+			results []*Result
+		)
+		go func() {
+			winner, results = a.ExecuteParallel(context.Background(), []*keys.RSA{k}, tc.workers)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("ExecuteParallel() failed: %s did not return", tc.name)
+		}
+
+		if tc.want == "" {
+			if winner != nil {
+				t.Errorf("ExecuteParallel() failed: %s expected no winner got %q", tc.name, winner.Name)
+			}
+
+			if len(results) == 0 || results[0].Status != StatusFailed {
+				t.Errorf("ExecuteParallel() failed: %s expected failed results got %v", tc.name, results)
+			}
+
+			continue
+		}
+
+		if winner == nil || winner.Name != tc.want {
+			t.Fatalf("ExecuteParallel() failed: %s expected winner %q got %v", tc.name, tc.want, winner)
+		}
+
+		if string(k.PlainText) != "flag" || string(winner.PlainText) != "flag" {
+			t.Errorf("ExecuteParallel() failed: %s expected plaintext from winner got %q", tc.name, k.PlainText)
+		}
+	}
+}
+
+func TestRequirementsCheck(t *testing.T) {
+	newKey := func(e int64) *keys.RSA {
+		k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: fmp.NewFmpz(35), E: fmp.NewFmpz(e)}), nil, nil, "", false)
+		return k
+	}
+
+	withCt := newKey(3)
+	withCt.CipherText = []byte{1}
+
+	withDp := newKey(3)
+	withDp.Key.Precomputed = &keys.PrecomputedValues{Dp: fmp.NewFmpz(1)}
+
+	tt := []struct {
+		name    string
+		req     Requirements
+		multi   bool
+		ks      []*keys.RSA
+		wantErr bool
+	}{
+		{
+			name: "no requirements",
+			ks:   []*keys.RSA{newKey(3)},
+		},
+		{
+			name:    "missing ciphertext",
+			req:     Requirements{CipherText: true},
+			ks:      []*keys.RSA{newKey(3)},
+			wantErr: true,
+		},
+		{
+			name: "ciphertext present",
+			req:  Requirements{CipherText: true},
+			ks:   []*keys.RSA{withCt},
+		},
+		{
+			name:    "missing ciphertext on second key of a multi key attack",
+			req:     Requirements{CipherText: true},
+			multi:   true,
+			ks:      []*keys.RSA{withCt, newKey(3)},
+			wantErr: true,
+		},
+		{
+			name: "second key ignored for single key attack",
+			req:  Requirements{CipherText: true},
+			ks:   []*keys.RSA{withCt, newKey(3)},
+		},
+		{
+			name:    "too few keys",
+			req:     Requirements{MinKeys: 2},
+			multi:   true,
+			ks:      []*keys.RSA{newKey(3)},
+			wantErr: true,
+		},
+		{
+			name:    "too many keys",
+			req:     Requirements{MaxKeys: 2},
+			multi:   true,
+			ks:      []*keys.RSA{newKey(3), newKey(3), newKey(3)},
+			wantErr: true,
+		},
+		{
+			name:    "e too large",
+			req:     Requirements{MaxE: 11},
+			ks:      []*keys.RSA{newKey(65537)},
+			wantErr: true,
+		},
+		{
+			name:    "e too small for a large e attack",
+			req:     Requirements{LargeE: true},
+			ks:      []*keys.RSA{newKey(3)},
+			wantErr: true,
+		},
+		{
+			name: "e close to n",
+			req:  Requirements{LargeE: true},
+			ks:   []*keys.RSA{newKey(33)},
+		},
+		{
+			name:    "dp without dq",
+			req:     Requirements{Precomputed: true},
+			ks:      []*keys.RSA{withDp},
+			wantErr: true,
+		},
+		{
+			name: "dp for a CRT exponent attack",
+			req:  Requirements{CRTExponent: true},
+			ks:   []*keys.RSA{withDp},
+		},
+		{
+			name:    "missing unknown bytes",
+			req:     Requirements{UnknownBytes: true},
+			ks:      []*keys.RSA{newKey(3)},
+			wantErr: true,
+		},
+		{
+			name:    "not enough hints",
+			req:     Requirements{Hints: 2},
+			ks:      []*keys.RSA{newKey(3)},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		err := tc.req.Check(tc.ks, tc.multi)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("Check() failed: %s expected error %v got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	nop := func(_ context.Context, _ []*keys.RSA) *Result {
+		return Failed("nop")
+	}
+
+	newKey := func(n, e string) *keys.RSA {
+		k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: ln.FmpString(n), E: ln.FmpString(e)}), nil, nil, "", false)
+		return k
+	}
+
+	// 512 bit modulus.
+	bigN := "12290181482018409908418063373549495476154617359580939567880306186449440546549813516186591592498406547318413025917263931004495598069853347591054306211548611"
+
+	a := NewAttacks()
+	a.Register(&Attack{Name: "smallq", Unnatended: true, Timeout: time.Minute, Cost: CostInstant, F: nop})
+	a.Register(&Attack{Name: "wiener", Unnatended: true, Timeout: time.Minute, Cost: CostFast, LargeE: true, F: nop})
+	a.Register(&Attack{Name: "fermat", Unnatended: true, Timeout: time.Minute, Cost: CostFast, F: nop})
+	a.Register(&Attack{Name: "rho", Unnatended: true, Timeout: 5 * time.Minute, Cost: CostSlow, F: nop})
+	a.Register(&Attack{Name: "factordb", Unnatended: true, Timeout: time.Minute, Cost: CostNetwork, F: nop})
+	a.Register(&Attack{Name: "commonfactors", SupportsMulti: true, Unnatended: true, Timeout: time.Minute, Cost: CostFast, F: nop})
+	a.Register(&Attack{Name: "needsct", Unnatended: true, Timeout: time.Minute, Requires: Requirements{CipherText: true}, F: nop})
+	a.Register(&Attack{Name: "alias", Timeout: time.Minute, F: nop})
+
+	tt := []struct {
+		name        string
+		ks          []*keys.RSA
+		want        map[string]Cost
+		wantSkipped int
+	}{
+		{
+			name: "large key with small e",
+			ks:   []*keys.RSA{newKey(bigN, "65537")},
+			want: map[string]Cost{
+				"smallq":        CostInstant,
+				"fermat":        CostFast,
+				"commonfactors": CostFast,
+				"wiener":        CostSlow,
+				"rho":           CostSlow,
+				"factordb":      CostNetwork,
+			},
+			wantSkipped: 1,
+		},
+		{
+			name: "large e moves wiener forward",
+			ks:   []*keys.RSA{newKey(bigN, "1234567890123456789012345678901234567890123456789012345678901234567890123456789012345678901234567890123")},
+			want: map[string]Cost{
+				"smallq":        CostInstant,
+				"wiener":        CostInstant,
+				"fermat":        CostFast,
+				"commonfactors": CostFast,
+				"rho":           CostSlow,
+				"factordb":      CostNetwork,
+			},
+			wantSkipped: 1,
+		},
+		{
+			name: "small modulus moves factoring forward and multiple keys promote multi key attacks",
+			ks:   []*keys.RSA{newKey("143", "7"), newKey("221", "7")},
+			want: map[string]Cost{
+				"smallq":        CostInstant,
+				"commonfactors": CostInstant,
+				"fermat":        CostFast,
+				"wiener":        CostSlow,
+				"rho":           CostFast,
+				"factordb":      CostNetwork,
+			},
+			wantSkipped: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		plan, skipped := a.Plan(tc.ks)
+
+		if len(skipped) != tc.wantSkipped {
+			t.Errorf("Plan() failed: %s expected %d skipped attacks got %d", tc.name, tc.wantSkipped, len(skipped))
+		}
+
+		got := make(map[string]Cost)
+		last := CostInstant
+		for _, stage := range plan {
+			for _, s := range stage {
+				if s.Cost < last {
+					t.Errorf("Plan() failed: %s stages out of order at %s", tc.name, s.Attack.Name)
+				}
+				last = s.Cost
+				got[s.Attack.Name] = s.Cost
+
+				if b, ok := stageBudget[s.Cost]; ok && s.Timeout > b {
+					t.Errorf("Plan() failed: %s %s timeout %v exceeds stage budget %v", tc.name, s.Attack.Name, s.Timeout, b)
+				}
+			}
+		}
+
+		if len(got) != len(tc.want) {
+			t.Errorf("Plan() failed: %s expected %d planned attacks got %d", tc.name, len(tc.want), len(got))
+		}
+
+		for name, c := range tc.want {
+			if got[name] != c {
+				t.Errorf("Plan() failed: %s expected %s to cost %v got %v", tc.name, name, c, got[name])
+			}
+		}
+	}
+}
+
+func TestPlanUserTimeout(t *testing.T) {
+	nop := func(_ context.Context, _ []*keys.RSA) *Result {
+		return Failed("nop")
+	}
+
+	k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: ln.FmpString("143"), E: ln.FmpString("7")}), nil, nil, "", false)
+
+	a := NewAttacks()
+	a.Register(&Attack{Name: "smallq", Unnatended: true, Timeout: time.Minute, Cost: CostInstant, F: nop})
+	a.Register(&Attack{Name: "fermat", Unnatended: true, Timeout: 5 * time.Minute, Cost: CostFast, F: nop})
+	a.Register(&Attack{Name: "wiener", Unnatended: true, Timeout: 5 * time.Minute, Cost: CostFast, F: nop})
+
+	if err := a.SetAttackTimeout("fermat", 2*time.Minute); err != nil {
+		t.Fatalf("SetAttackTimeout() unexpected error: %v", err)
+	}
+
+	want := map[string]time.Duration{
+		"smallq": stageBudget[CostInstant],
+		"fermat": 2 * time.Minute,
+		"wiener": stageBudget[CostFast],
+	}
+
+	plan, _ := a.Plan([]*keys.RSA{k})
+	for _, stage := range plan {
+		for _, s := range stage {
+			if s.Timeout != want[s.Attack.Name] {
+				t.Errorf("Plan() failed: expected %s timeout %v got %v", s.Attack.Name, want[s.Attack.Name], s.Timeout)
+			}
+		}
+	}
+
+	// A global timeout overrides every stage budget.
+	a.SetTimeout(3 * time.Minute)
+	plan, _ = a.Plan([]*keys.RSA{k})
+	for _, stage := range plan {
+		for _, s := range stage {
+			if s.Timeout != 3*time.Minute {
+				t.Errorf("Plan() failed: expected %s timeout %v got %v", s.Attack.Name, 3*time.Minute, s.Timeout)
+			}
+		}
+	}
+}
+
+func TestPlanUnnatendedRequires(t *testing.T) {
+	nop := func(_ context.Context, _ []*keys.RSA) *Result {
+		return Failed("nop")
+	}
+
+	k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: ln.FmpString("143"), E: ln.FmpString("7")}), nil, nil, "", false)
+
+	a := NewAttacks()
+	a.Register(&Attack{Name: "fermat", Unnatended: true, Timeout: time.Minute, Cost: CostFast, F: nop})
+	a.Register(&Attack{Name: "stereotyped", Unnatended: true, Timeout: time.Minute, Cost: CostFast, UnnatendedRequires: Requirements{UnknownBytes: true}, F: nop})
+
+	planned := func(a *Attacks) bool {
+		plan, _ := a.Plan([]*keys.RSA{k})
+		for _, stage := range plan {
+			for _, s := range stage {
+				if s.Attack.Name == "stereotyped" {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	if planned(a) {
+		t.Errorf("Plan() failed: expected stereotyped to be skipped without unknown bytes")
+	}
+
+	s, err := a.Select([]string{"stereotyped"}, nil)
+	if err != nil {
+		t.Fatalf("Select() unexpected error: %v", err)
+	}
+
+	if !planned(s) {
+		t.Errorf("Plan() failed: expected stereotyped to be planned when selected by name")
+	}
+
+	k.UnknownBytes = 6
+	if !planned(a) {
+		t.Errorf("Plan() failed: expected stereotyped to be planned with unknown bytes")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	nop := func(_ context.Context, _ []*keys.RSA) *Result {
+		return Failed("nop")
+	}
+
+	a := NewAttacks()
+	a.RegisterAttack("fermat", false, true, time.Minute, nop)
+	a.RegisterAttack("ecm", false, true, time.Minute, nop)
+	a.RegisterAttack("factordb", false, true, time.Minute, nop)
+	a.RegisterAttack("partiald", false, false, time.Minute, nop)
+
+	tt := []struct {
+		name    string
+		include []string
+		skip    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "everything",
+			want: []string{"fermat", "ecm", "factordb", "partiald"},
+		},
+		{
+			name: "everything except factordb",
+			skip: []string{"factordb"},
+			want: []string{"fermat", "ecm", "partiald"},
+		},
+		{
+			name:    "only the included attacks",
+			include: []string{"ecm", "partiald"},
+			want:    []string{"ecm", "partiald"},
+		},
+		{
+			name:    "unknown attack",
+			skip:    []string{"nope"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		s, err := a.Select(tc.include, tc.skip)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("Select() failed: %s expected error %v got %v", tc.name, tc.wantErr, err)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		var got []string
+		for _, at := range s.Supported {
+			got = append(got, at.Name)
+			if len(tc.include) > 0 && !at.Unnatended {
+				t.Errorf("Select() failed: %s expected included attack %s to run unnatended", tc.name, at.Name)
+			}
+		}
+
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("Select() failed: %s expected %v got %v", tc.name, tc.want, got)
+		}
+	}
+
+	s, _ := a.Select(nil, nil)
+	s.SetTimeout(time.Second)
+	if err := s.SetAttackTimeout("ecm", time.Hour); err != nil {
+		t.Fatalf("SetAttackTimeout() failed: %v", err)
+	}
+
+	if s.Get("ecm").Timeout != time.Hour || s.Get("fermat").Timeout != time.Second {
+		t.Errorf("SetTimeout() failed: got ecm %v fermat %v", s.Get("ecm").Timeout, s.Get("fermat").Timeout)
+	}
+
+	if a.Get("ecm").Timeout != time.Minute {
+		t.Errorf("SetAttackTimeout() failed: original attacks were modified")
+	}
+}
@@ -0,0 +1,97 @@
+// Package batchgcd finds factors shared between any of a large set of moduli with Bernstein's
+// batch GCD. The common factors attack compares every pair of keys which is too slow for the
+// thousands of keys in a scraped corpus, the product and remainder trees find all the shared
+// factors at the cost of a few multiplications of the product of every modulus.
+package batchgcd
+
+import (
+	"context"
+	"log"
+
+	"github.com/sourcekris/goRsaTool/attacks"
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// name is the name of this attack.
+const name = "batch gcd"
+
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:          "batchgcd",
+		SupportsMulti: true,
+		Unnatended:    true,
+		Timeout:       attacks.DefaultTimeout,
+		Cost:          attacks.CostFast,
+		Requires:      attacks.Requirements{MinKeys: 2},
+		F:             Attack,
+	})
+}
+
+// split returns a proper factor of n shared with one of the other moduli or nil. It is used when
+// every factor of n is shared so the batch gcd returns n itself.
+func split(n *fmp.Fmpz, ns []*fmp.Fmpz) *fmp.Fmpz {
+	for _, m := range ns {
+		if g := new(fmp.Fmpz).GCD(n, m); g.Cmp(ln.BigOne) > 0 && g.Cmp(n) < 0 {
+			return g
+		}
+	}
+
+	return nil
+}
+
+// Attack implements the batch gcd method against the moduli of many keys, every key that shares
+// a factor with another is factored.
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
+	if len(ks) < 2 {
+		return attacks.NotApplicable("%s attack requires 2+ public keys, got: %d", name, len(ks))
+	}
+
+	ns := make([]*fmp.Fmpz, len(ks))
+	for i, k := range ks {
+		ns[i] = k.Key.N
+	}
+
+	gs := ln.BatchGCD(ns)
+	if ctx.Err() != nil {
+		return attacks.Stopped(ctx.Err())
+	}
+
+	var solved *keys.RSA
+	for i, g := range gs {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
+		k := ks[i]
+		if g.Equals(ln.BigOne) || k.Key.D != nil {
+			continue
+		}
+
+		if g.Equals(ns[i]) {
+			if g = split(ns[i], ns); g == nil {
+				if k.Verbose {
+					log.Printf("%s: %s has a modulus repeated in another key", name, k.KeyFilename)
+				}
+				continue
+			}
+		}
+
+		if k.Verbose {
+			log.Printf("%s: %s shares the factor %v", name, k.KeyFilename, g)
+		}
+
+		k.PackGivenP(g)
+		if solved == nil {
+			solved = k
+		}
+	}
+
+	if solved == nil {
+		return attacks.Failed("%s found no factors shared between the %d keys", name, len(ks))
+	}
+
+	return attacks.Solved(solved)
+}
@@ -0,0 +1,66 @@
+package batchgcd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+	"github.com/sourcekris/goRsaTool/utils"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+func TestAttack(t *testing.T) {
+	var (
+		p = ln.FmpString("206649527463365580008337435483770722577")
+		q = ln.FmpString("327990926585457938257140776345444065243")
+
+		// n1 = p * q shares both its factors so its batch gcd is n1 itself.
+		n1 = ln.FmpString("67779169991156313953976086119991464718779730349607858782745496509542441091211")
+		n2 = ln.FmpString("50298582706490822307889199830230264538466215367691409279068811662803456266451")
+		n3 = ln.FmpString("57488184812096622371128663324110505629109930326811419824539609510291393528181")
+		n4 = ln.FmpString("77104860310592755937333890081418132110699847840173585625662177633348479954557")
+	)
+
+	tt := []struct {
+		name    string
+		ns      []*fmp.Fmpz
+		want    []*fmp.Fmpz
+		wantErr bool
+	}{
+		{
+			name: "keys sharing factors are broken",
+			ns:   []*fmp.Fmpz{n1, n2, n3, n4},
+			want: []*fmp.Fmpz{p, p, nil, q},
+		},
+		{
+			name:    "keys without shared factors",
+			ns:      []*fmp.Fmpz{n2, n3},
+			want:    []*fmp.Fmpz{nil, nil},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		var ks []*keys.RSA
+		for _, n := range tc.ns {
+			k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: n, E: fmp.NewFmpz(65537)}), nil, nil, "", false)
+			ks = append(ks, k)
+		}
+
+		err := Attack(context.Background(), ks).Err()
+		if (err != nil) != tc.wantErr {
+			t.Errorf("Attack() %s: want error %v got %v", tc.name, tc.wantErr, err)
+		}
+
+		for i, k := range ks {
+			switch {
+			case tc.want[i] == nil && k.Key.D != nil:
+				t.Errorf("Attack() %s: key %d should not be factored got primes %v", tc.name, i, k.Key.Primes)
+			case tc.want[i] != nil && !utils.FoundP(tc.want[i], k.Key.Primes):
+				t.Errorf("Attack() %s: key %d expected primes not found - got %v wanted %v", tc.name, i, k.Key.Primes, tc.want[i])
+			}
+		}
+	}
+}
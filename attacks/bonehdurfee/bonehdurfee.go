@@ -0,0 +1,230 @@
+// Package bonehdurfee implements the Boneh-Durfee attack which recovers private exponents
+// d < N^0.292, beyond the N^0.25 bound of Wiener's attack. The lattice follows the improvements of
+// Herrmann and May, "Maximizing Small Root Bounds by Linearization and Applications to Small
+// Secret Exponent RSA".
+package bonehdurfee
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/sourcekris/goRsaTool/attacks"
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// name is the name of this attack.
+const name = "boneh durfee"
+
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "bonehdurfee",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostFast,
+		Requires:   attacks.Requirements{LargeE: true},
+		LargeE:     true,
+		F:          Attack,
+	})
+}
+
+// params are the bounds d < N^delta and lattice sizes m to try in turn. Larger m reaches closer
+// to the theoretical bound at the cost of a larger lattice.
+var params = []struct {
+	delta float64
+	m     int
+}{
+	{0.26, 4},
+	{0.27, 5},
+	{0.28, 6},
+	{0.285, 7},
+	{0.292, 8},
+}
+
+// The variables of the polynomials, u is substituted for xy + 1.
+const (
+	vu = iota
+	vx
+	vy
+)
+
+// unlinearize returns p with every x*y replaced by u - 1.
+func unlinearize(p *ln.MPoly) *ln.MPoly {
+	var (
+		r      = ln.NewMPoly(3)
+		uMinus = ln.MPolyVar(3, vu).Sub(ln.MPolyConst(3, ln.BigOne))
+	)
+
+	for _, exp := range p.Monomials() {
+		c := min(exp[vx], exp[vy])
+		t := ln.NewMPoly(3).AddTerm(p.Coeff(exp...), exp[vu], exp[vx]-c, exp[vy]-c)
+		r = r.Add(t.Mul(uMinus.Pow(c)))
+	}
+
+	return r
+}
+
+// linearize returns p in u, x, y as a polynomial in x, y with u = xy + 1.
+func linearize(p *ln.MPoly) *ln.MPoly {
+	var (
+		r = ln.NewMPoly(2)
+		u = ln.MPolyVar(2, 0).Mul(ln.MPolyVar(2, 1)).Add(ln.MPolyConst(2, ln.BigOne))
+	)
+
+	for _, exp := range p.Monomials() {
+		t := ln.NewMPoly(2).AddTerm(p.Coeff(exp...), exp[vx], exp[vy])
+		r = r.Add(t.Mul(u.Pow(exp[vu])))
+	}
+
+	return r
+}
+
+// candidates returns the small roots (x0, y0) of f(x, y) = 1 + x * (A + y) mod e found with a
+// lattice of size m for d < N^delta.
+func candidates(n, e *fmp.Fmpz, delta float64, m int) [][]*fmp.Fmpz {
+	var (
+		a = new(fmp.Fmpz).Add(n, ln.BigOne)
+		t = int((1 - 2*delta) * float64(m))
+		x = fmp.NewFmpz(2).Lsh(int(delta * float64(n.BitLen())))
+		y = new(fmp.Fmpz).Sqrt(n)
+		u = new(fmp.Fmpz).Mul(x, y)
+	)
+
+	a.Div(a, ln.BigTwo)
+	u.Add(u, ln.BigOne)
+
+	if t < 1 {
+		t = 1
+	}
+
+	// f(x, y) = 1 + x * (A + y) = u + A * x.
+	f := ln.MPolyVar(3, vu).Add(ln.MPolyVar(3, vx).Scale(a))
+
+	var gs []*ln.MPoly
+
+	// x shifts x^i * f^k * e^(m-k).
+	for k := 0; k <= m; k++ {
+		base := f.Pow(k).Scale(new(fmp.Fmpz).ExpXI(e, m-k))
+		for i := 0; i <= m-k; i++ {
+			gs = append(gs, base.Mul(ln.MPolyVar(3, vx).Pow(i)))
+		}
+	}
+
+	// y shifts y^j * f^k * e^(m-k) selected by Herrmann and May.
+	for j := 1; j <= t; j++ {
+		for k := m / t * j; k <= m; k++ {
+			g := ln.MPolyVar(3, vy).Pow(j).Mul(f.Pow(k)).Scale(new(fmp.Fmpz).ExpXI(e, m-k))
+			gs = append(gs, unlinearize(g))
+		}
+	}
+
+	var (
+		monomials [][]int
+		seen      = make(map[string]bool)
+	)
+
+	for _, g := range gs {
+		for _, exp := range g.Monomials() {
+			if key := fmt.Sprint(exp); !seen[key] {
+				seen[key] = true
+				monomials = append(monomials, exp)
+			}
+		}
+	}
+
+	scale := make([]*fmp.Fmpz, len(monomials))
+	for j, exp := range monomials {
+		scale[j] = new(fmp.Fmpz).ExpXI(u, exp[vu])
+		scale[j].Mul(scale[j], new(fmp.Fmpz).ExpXI(x, exp[vx]))
+		scale[j].Mul(scale[j], new(fmp.Fmpz).ExpXI(y, exp[vy]))
+	}
+
+	rows := make([][]*fmp.Fmpz, len(gs))
+	for i, g := range gs {
+		rows[i] = make([]*fmp.Fmpz, len(monomials))
+		for j, exp := range monomials {
+			rows[i][j] = new(fmp.Fmpz).Mul(g.Coeff(exp...), scale[j])
+		}
+	}
+
+	var hs []*ln.MPoly
+	for _, row := range ln.LLL(rows) {
+		h := ln.NewMPoly(3)
+		for j, v := range row {
+			h.AddTerm(new(fmp.Fmpz).Quo(v, scale[j]), monomials[j]...)
+		}
+
+		if h := linearize(h); h.Degree() > 0 {
+			hs = append(hs, h)
+		}
+	}
+
+	// y0 = -(p + q) / 2 which is a little larger than sqrt(N) for balanced primes.
+	return ln.CommonRoots(hs, []*fmp.Fmpz{x, new(fmp.Fmpz).Mul(y, ln.BigTwo)})
+}
+
+// factor returns p given a root (x0, y0) of 1 + x * (A + y) mod e where y0 = -(p + q) / 2, or
+// nil if the root does not factor n.
+func factor(n, e *fmp.Fmpz, root []*fmp.Fmpz) *fmp.Fmpz {
+	// 1 + x0 * (A + y0) = e * d
+	a := new(fmp.Fmpz).Add(n, ln.BigOne)
+	a.Div(a, ln.BigTwo)
+
+	ed := new(fmp.Fmpz).Add(a, root[1])
+	ed.Mul(ed, root[0]).Add(ed, ln.BigOne)
+	if ed.Sign() <= 0 || !new(fmp.Fmpz).Mod(ed, e).IsZero() {
+		return nil
+	}
+
+	// p and q are the roots of z^2 - s*z + n where s = p + q.
+	s := new(fmp.Fmpz).Mul(root[1], fmp.NewFmpz(-2))
+	disc := new(fmp.Fmpz).Mul(s, s)
+	disc.Sub(disc, new(fmp.Fmpz).Mul(n, ln.BigFour))
+	if disc.Sign() < 0 {
+		return nil
+	}
+
+	r := ln.IsPerfectSquare(disc)
+	if r.Equals(ln.BigNOne) {
+		return nil
+	}
+
+	p := new(fmp.Fmpz).Add(s, r)
+	p.Div(p, ln.BigTwo)
+	if p.Cmp(ln.BigOne) <= 0 || !new(fmp.Fmpz).Mod(n, p).IsZero() {
+		return nil
+	}
+
+	return p
+}
+
+// Attack implements the Boneh-Durfee attack on keys with a small private exponent.
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
+	k := ks[0]
+	if k.Key.D != nil {
+		return attacks.Solved(k)
+	}
+
+	n, e := k.Key.N, k.Key.PublicKey.E
+	for _, pr := range params {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
+		if k.Verbose {
+			log.Printf("%s: trying d < N^%v with m = %d", name, pr.delta, pr.m)
+		}
+
+		for _, root := range candidates(n, e, pr.delta, pr.m) {
+			if p := factor(n, e, root); p != nil {
+				k.PackGivenP(p)
+				return attacks.Solved(k)
+			}
+		}
+	}
+
+	return attacks.Failed("%s did not find d < N^%v", name, params[len(params)-1].delta)
+}
@@ -0,0 +1,63 @@
+package bonehdurfee
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sourcekris/goRsaTool/attacks/wiener"
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+	"github.com/sourcekris/goRsaTool/utils"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+func TestAttack(t *testing.T) {
+	tt := []struct {
+		name  string
+		n     *fmp.Fmpz
+		e     *fmp.Fmpz
+		wantD *fmp.Fmpz
+		wantP *fmp.Fmpz
+		// noWiener is set when d is too large for the wiener attack to find.
+		noWiener bool
+	}{
+		{
+			name:  "256 bit modulus with d ~ N^0.258",
+			n:     ln.FmpString("73038473923129604960528348561146899987773796013835700496602523833131136874907"),
+			e:     ln.FmpString("39651860982298651569655235358372762410862559817099200736839943657438890096739"),
+			wantD: ln.FmpString("48347420011691301059"),
+			wantP: ln.FmpString("279822840994420410528759556475825253701"),
+		},
+		{
+			name:     "256 bit modulus with d ~ N^0.265",
+			n:        ln.FmpString("81484724436591507958603238615993744833308658325335716956023563345673830052507"),
+			e:        ln.FmpString("73373306985390195869830620138184942271080084594591317661897444266382606238791"),
+			wantD:    ln.FmpString("224220913413025561063"),
+			wantP:    ln.FmpString("240661382486441282895702052238828612623"),
+			noWiener: true,
+		},
+	}
+
+	for _, tc := range tt {
+		if tc.noWiener {
+			wk, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: tc.n, E: tc.e}), nil, nil, "", false)
+			if err := wiener.Attack(context.Background(), []*keys.RSA{wk}).Err(); err == nil {
+				t.Errorf("wiener.Attack() succeeded: %s expected an error got d %v", tc.name, wk.Key.D)
+			}
+		}
+
+		k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: tc.n, E: tc.e}), nil, nil, "", false)
+		if err := Attack(context.Background(), []*keys.RSA{k}).Err(); err != nil {
+			t.Errorf("Attack() failed: %s expected no error got error: %v", tc.name, err)
+		}
+
+		if k.Key.D == nil || !k.Key.D.Equals(tc.wantD) {
+			t.Errorf("Attack() failed: %s expected d %v got %v", tc.name, tc.wantD, k.Key.D)
+		}
+
+		if !utils.FoundP(tc.wantP, k.Key.Primes) {
+			t.Errorf("Attack() failed: %s expected prime %v not found in %v", tc.name, tc.wantP, k.Key.Primes)
+		}
+	}
+}
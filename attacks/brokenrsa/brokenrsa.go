@@ -5,8 +5,9 @@
 package brokenrsa
 
 import (
-	"fmt"
+	"context"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -15,23 +16,32 @@ import (
 
 const name = "brokenrsa"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "brokenrsa",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostInstant,
+		Requires:   attacks.Requirements{CipherText: true},
+		F:          Attack,
+	})
+}
+
 // Attack implements the brokenrsa method against ciphertext in multiple keys.
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 
 	k := ks[0]
 	if k.CipherText == nil {
-		ch <- fmt.Errorf("invalid arguments for attack %s: this attack requires the ciphertext", name)
-		return
+		return attacks.NotApplicable("invalid arguments for attack %s: this attack requires the ciphertext", name)
 	}
 	d, u, _ := ln.XGCD(k.Key.PublicKey.E, k.Key.N)
 	if !d.Equals(ln.BigOne) {
-		ch <- fmt.Errorf("n and e were not coprime so %s attack will not work: GCE(e,n) == %v", name, d)
-		return
+		return attacks.NotApplicable("n and e were not coprime so %s attack will not work: GCE(e,n) == %v", name, d)
 	}
 
 	ct := ln.BytesToNumber(k.CipherText)
 	pt := new(fmp.Fmpz).Mul(ct, u)
 	k.PlainText = ln.NumberToBytes(pt.Mod(pt, k.Key.N))
 
-	ch <- nil
+	return attacks.Solved(k)
 }
@@ -1,6 +1,7 @@
 package brokenrsa
 
 import (
+	"context"
 	"testing"
 
 	"github.com/sourcekris/goRsaTool/keys"
@@ -48,9 +49,7 @@ func TestAttack(t *testing.T) {
 		if tc.ct != nil {
 			k.CipherText = ln.NumberToBytes(tc.ct)
 		}
-		ch := make(chan error)
-		go Attack([]*keys.RSA{k}, ch)
-		err := <-ch
+		err := Attack(context.Background(), []*keys.RSA{k}).Err()
 		if err != nil && !tc.wantErr {
 			t.Errorf("Attack() failed: %s expected no error got error: %v", tc.name, err)
 		}
@@ -1,8 +1,9 @@
 package commonfactor
 
 import (
-	"fmt"
+	"context"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -12,9 +13,29 @@ import (
 // name is the name of this attack.
 const name = "common factors"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:          "commonfactors",
+		SupportsMulti: true,
+		Unnatended:    true,
+		Timeout:       attacks.DefaultTimeout,
+		Cost:          attacks.CostInstant,
+		Requires:      attacks.Requirements{MinKeys: 2},
+		F:             Attack,
+	})
+}
+
 // Attack implements the common factors method against moduli in multiple keys.
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
+	if len(ks) < 2 {
+		return attacks.NotApplicable("%s attack requires 2+ public keys, got: %d", name, len(ks))
+	}
+
 	for _, i := range ks {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		for _, j := range ks {
 			if i.Key.N == j.Key.N {
 				continue
@@ -25,10 +46,9 @@ func Attack(ks []*keys.RSA, ch chan error) {
 				i.PackGivenP(g)
 				j.PackGivenP(g)
 
-				ch <- nil
-				return
+				return attacks.Solved(i)
 			}
 		}
 	}
-	ch <- fmt.Errorf("%s was unable to factor the keys", name)
+	return attacks.Failed("%s was unable to factor the keys", name)
 }
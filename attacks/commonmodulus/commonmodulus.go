@@ -1,8 +1,9 @@
 package commonmodulus
 
 import (
-	"fmt"
+	"context"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -12,21 +13,30 @@ import (
 // name is the name of this attack.
 const name = "common modulus"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:          "commonmodulus",
+		SupportsMulti: true,
+		Unnatended:    true,
+		Timeout:       attacks.DefaultTimeout,
+		Cost:          attacks.CostInstant,
+		Requires:      attacks.Requirements{CipherText: true, MinKeys: 2, MaxKeys: 2},
+		F:             Attack,
+	})
+}
+
 // Attack implements the common modulus attack against two keys.
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 	if len(ks) != 2 {
-		ch <- fmt.Errorf("%s attack expects exactly 2 keys - got %d keys", name, len(ks))
-		return
+		return attacks.NotApplicable("%s attack expects exactly 2 keys - got %d keys", name, len(ks))
 	}
 
 	if ks[0].CipherText == nil || ks[1].CipherText == nil {
-		ch <- fmt.Errorf("%s attack requires each key be associated with a ciphertext", name)
-		return
+		return attacks.NotApplicable("%s attack requires each key be associated with a ciphertext", name)
 	}
 
 	if !ks[0].Key.N.Equals(ks[1].Key.N) {
-		ch <- fmt.Errorf("%s attack requires that both keys share the same modulus", name)
-		return
+		return attacks.NotApplicable("%s attack requires that both keys share the same modulus", name)
 	}
 
 	c1 := ln.BytesToNumber(ks[0].CipherText)
@@ -50,5 +60,5 @@ func Attack(ks []*keys.RSA, ch chan error) {
 
 	ks[0].PlainText = ln.NumberToBytes(new(fmp.Fmpz).Root(new(fmp.Fmpz).Mul(p1, p2).ModZ(n), int32(g.GetInt())))
 
-	ch <- nil
+	return attacks.Solved(ks[0])
 }
@@ -1,8 +1,9 @@
 package crt
 
 import (
-	"fmt"
+	"context"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -12,14 +13,24 @@ import (
 // name is the name of this attack.
 const name = "crt solver"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "crtsolver",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostInstant,
+		Requires:   attacks.Requirements{CipherText: true, KnownPrime: true, Precomputed: true},
+		F:          Attack,
+	})
+}
+
 // Attack solves for a plaintext given a ciphertext and the CRT components Dp, Dq, p, q.
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 	k := ks[0]
 
 	// We need values in the precomputed portion of the key for this attack.
-	if k.Key.Precomputed == nil {
-		ch <- fmt.Errorf("%s failed - Precomputed values (Dp, Dq, etc) is not in key %s", name, k.KeyFilename)
-		return
+	if k.Key.Precomputed == nil || k.Key.Precomputed.Dp == nil || k.Key.Precomputed.Dq == nil {
+		return attacks.NotApplicable("%s failed - Precomputed values (Dp, Dq, etc) is not in key %s", name, k.KeyFilename)
 	}
 
 	// If we only got 1 prime, deduce the 2nd prime from N/p = q
@@ -28,13 +39,11 @@ func Attack(ks []*keys.RSA, ch chan error) {
 	}
 
 	if len(k.Key.Primes) < 2 {
-		ch <- fmt.Errorf("%s failed - need two primes", name)
-		return
+		return attacks.NotApplicable("%s failed - need two primes", name)
 	}
 
 	if k.CipherText == nil {
-		ch <- fmt.Errorf("%s failed - no ciphertext provided", name)
-		return
+		return attacks.NotApplicable("%s failed - no ciphertext provided", name)
 	}
 
 	pp := new(fmp.Fmpz).Sub(k.Key.Primes[0], ln.BigOne)
@@ -55,9 +64,8 @@ func Attack(ks []*keys.RSA, ch chan error) {
 	k.PlainText = ln.NumberToBytes(new(fmp.Fmpz).Exp(ln.BytesToNumber(k.CipherText), d, n))
 
 	if len(k.PlainText) > 0 {
-		ch <- nil
-		return
+		return attacks.Solved(k)
 	}
 
-	ch <- fmt.Errorf("%s failed", name)
+	return attacks.Failed("%s failed", name)
 }
@@ -1,126 +1,172 @@
 // Package defectivee implements a common broken rsa implementation where e
-// and phi(n) are not coprime by finding the root of unity modulo n.
-// Given some n, and e we factor n for p and q.
-// We find the totient = (p-1)*(q-1) but it is not coprime to e so RSA is
-// defective since one ciphertext can have many correct solutions.
+// and phi(n) are not coprime so one ciphertext has many correct solutions.
+// Given the factors of n we find every e'th root of c modulo each prime using
+// the Adleman-Manders-Miller generalisation of Tonelli-Shanks and combine
+// them with the CRT. The plaintext is picked with a crib if we have one or
+// else by how printable each candidate is.
 // An example was seen in BuckEye CTF 2021:
 // https://github.com/cscosu/buckeyectf-2021/tree/master/crypto/defective_rsa/solve
 package defectivee
 
 import (
 	"bytes"
-	"fmt"
+	"context"
+	"log"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
 	fmp "github.com/sourcekris/goflint"
-	mp "github.com/sourcekris/mathparse"
 )
 
 const name = "defective e"
 
-var rounds int64 = 500
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "defectivee",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostFast,
+		Requires:   attacks.Requirements{CipherText: true, KnownPrime: true},
+		F:          Attack,
+	})
+}
+
+// maxCandidates bounds the number of plaintexts tried, the product of the number of roots
+// modulo each prime.
+var maxCandidates = 1 << 20
 
-func rootsOfUnity(e, phi, n *fmp.Fmpz, rounds int64) ([]*fmp.Fmpz, *fmp.Fmpz) {
+// primes returns the prime factors of n given at least one of them. When a single factor is
+// missing it is the cofactor of the others.
+func primes(n *fmp.Fmpz, given []*fmp.Fmpz) []*fmp.Fmpz {
 	var (
-		i     int64
-		roots []*fmp.Fmpz
+		ps   []*fmp.Fmpz
+		prod = fmp.NewFmpz(1)
 	)
 
-	phiCoprime := new(fmp.Fmpz).Set(phi)
-
-	for new(fmp.Fmpz).GCD(phiCoprime, e).Cmp(ln.BigOne) != 0 {
-		phiCoprime.Div(phiCoprime, new(fmp.Fmpz).GCD(phiCoprime, e))
+	for _, p := range given {
+		ps = append(ps, new(fmp.Fmpz).Set(p))
+		prod.MulZ(p)
 	}
 
-OUTER:
-	for i = 0; i < rounds; i++ {
-		r := new(fmp.Fmpz).Exp(fmp.NewFmpz(i), phiCoprime, n)
-
-		// Don't add duplicate roots.
-		for _, root := range roots {
-			if r.Cmp(root) == 0 {
-				continue OUTER
-			}
-		}
+	if prod.Equals(n) {
+		return ps
+	}
 
-		roots = append(roots, r)
+	q, r := new(fmp.Fmpz).DivMod(n, prod, new(fmp.Fmpz))
+	if !r.IsZero() {
+		return nil
 	}
 
-	return roots, phiCoprime
+	return append(ps, q)
 }
 
 // Attack implements the defectivee method against RSA given at least one prime.
-func Attack(ks []*keys.RSA, ch chan error) {
-
-	var p, q *fmp.Fmpz
-
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 	k := ks[0]
 	if k.Key.Primes == nil {
-		ch <- fmt.Errorf("%s attack requires the modulus to already be factored, provide at least one prime with -p flag", name)
-		return
+		return attacks.NotApplicable("%s attack requires the modulus to already be factored, provide at least one prime with -p flag", name)
 	}
 
-	if len(k.Key.Primes) == 1 {
-		p = k.Key.Primes[0]
-		q = new(fmp.Fmpz).Div(k.Key.N, p)
+	var (
+		e = new(fmp.Fmpz).Set(k.Key.PublicKey.E)
+		c = ln.BytesToNumber(k.CipherText)
+		n = k.Key.N
+	)
 
-		if new(fmp.Fmpz).Mul(p, q).Cmp(k.Key.N) != 0 {
-			ch <- fmt.Errorf("%s failed. n is not the product of primes p and q", name)
-			return
-		}
+	ps := primes(n, k.Key.Primes)
+	if ps == nil {
+		return attacks.Failed("%s failed. n is not the product of the given primes", name)
 	}
 
-	// TODO: Use a hueristic instead like, "are all of the bytes in m considered printable?"
-	if k.KnownPlainText == nil {
-		ch <- fmt.Errorf("%s requires a crib, part of the plaintext, so we know when our solution is found (e.g. CTF flag format)", name)
-		return
+	phi := fmp.NewFmpz(1)
+	for _, p := range ps {
+		phi.MulZ(new(fmp.Fmpz).Sub(p, ln.BigOne))
 	}
 
-	e := new(fmp.Fmpz).Set(k.Key.PublicKey.E)
-	n := new(fmp.Fmpz).Set(k.Key.N)
-	c := new(fmp.Fmpz).Set(ln.BytesToNumber(k.CipherText))
+	if new(fmp.Fmpz).GCD(e, phi).Equals(ln.BigOne) {
+		return attacks.NotApplicable("%s failed: e is co-prime to phi(n) so the key is not defective", name)
+	}
 
-	phi, _ := mp.Evalf("(%v-1)*(%v-1))", p, q)
-	d := new(fmp.Fmpz).ModInverse(phi, e)
+	// Find every e'th root of c modulo each prime.
+	var (
+		roots = make([][]*fmp.Fmpz, len(ps))
+		total = 1
+	)
 
-	if d.Cmp(ln.BigZero) != 0 {
-		ch <- fmt.Errorf("%s failed: e is probably co-prime to phi(n) since there exists an inverse modulus of e, phi(n): %v", name, d)
-		return
-	}
+	for i, p := range ps {
+		rs, err := ln.NthRootsMod(c, e, p)
+		if err != nil {
+			return attacks.Failed("%s failed: %v", name, err)
+		}
 
-	// Find e'th roots of unity modulo n.
-	roots, phiCoprime := rootsOfUnity(e, phi, n, rounds)
+		if len(rs) == 0 {
+			return attacks.Failed("%s failed: the ciphertext has no e'th root modulo %v", name, p)
+		}
 
-	// Use phiCoprime to get one possible plaintext for c.
-	d = new(fmp.Fmpz).ModInverse(e, phiCoprime)
-	m := new(fmp.Fmpz).Exp(c, d, n)
+		if total *= len(rs); total > maxCandidates {
+			return attacks.Failed("%s failed: more than %d candidate plaintexts", name, maxCandidates)
+		}
 
-	testC := new(fmp.Fmpz).Exp(m, e, n)
-	if testC.Cmp(c) != 0 {
-		ch <- fmt.Errorf("%s failed to find a possible plaintext for the given ciphertext and key", name)
-		return
+		roots[i] = rs
 	}
 
-	// Maybe the first m is the right one? If so pack the key and return.
-	if bytes.HasPrefix(ln.NumberToBytes(m), k.KnownPlainText) {
-		k.Key.D = new(fmp.Fmpz).Set(d)
-		k.PlainText = ln.NumberToBytes(m)
-		k.Key.Primes = append(k.Key.Primes, q)
-		ch <- nil
+	if k.Verbose {
+		log.Printf("%s: trying %d candidate plaintexts", name, total)
 	}
 
-	// Search the roots for a plaintext matching our crib.
-	for _, root := range roots {
-		mt := new(fmp.Fmpz).Mul(m, root).ModZ(n)
-		if bytes.HasPrefix(ln.NumberToBytes(mt), k.KnownPlainText) {
-			k.Key.D = new(fmp.Fmpz).Set(d)
-			k.PlainText = ln.NumberToBytes(mt)
-			k.Key.Primes = append(k.Key.Primes, q)
-			ch <- nil
+	// Walk every combination of roots in mixed radix.
+	var (
+		idx       = make([]int, len(ps))
+		mrs       = make([][]*fmp.Fmpz, len(ps))
+		best      []byte
+		bestScore float64
+	)
+
+	for i := 0; i < total; i++ {
+		if i%1024 == 0 && ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
+		for j, p := range ps {
+			mrs[j] = []*fmp.Fmpz{roots[j][idx[j]], p}
 		}
+
+		for j := range idx {
+			if idx[j]++; idx[j] < len(roots[j]) {
+				break
+			}
+			idx[j] = 0
+		}
+
+		pt := ln.NumberToBytes(ln.SolveCRT(mrs))
+		if k.KnownPlainText != nil {
+			if bytes.Contains(pt, k.KnownPlainText) {
+				best = pt
+				break
+			}
+			continue
+		}
+
+		if score := ln.PlaintextScore(pt); score > bestScore {
+			best, bestScore = pt, score
+		}
+	}
+
+	if best == nil || (k.KnownPlainText == nil && bestScore < 1) {
+		return attacks.Failed("%s failed to find a plaintext among %d candidates", name, total)
 	}
 
-	ch <- nil
+	// Keep the exponent that decrypts to one of the candidates.
+	phiCoprime := new(fmp.Fmpz).Set(phi)
+	for g := new(fmp.Fmpz).GCD(phiCoprime, e); !g.Equals(ln.BigOne); g.GCD(phiCoprime, e) {
+		phiCoprime.Div(phiCoprime, g)
+	}
+
+	k.Key.D = new(fmp.Fmpz).ModInverse(e, phiCoprime)
+	k.Key.Primes = ps
+	k.PlainText = best
+
+	return attacks.Solved(k)
 }
@@ -1,8 +1,13 @@
+// Package dixons implements Dixon's factorization method. Relations are generated with the
+// continued fraction method (CFRAC) which yields congruences x^2 = Q (mod n) with Q smaller than
+// 2 * sqrt(n) so they are far more likely to be smooth than random squares.
 package dixons
 
 import (
-	"fmt"
+	"context"
+	"math"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -12,35 +17,271 @@ import (
 // name is the name of this attack.
 const name = "dixon's factorization"
 
-var (
-	base = []*fmp.Fmpz{ln.BigTwo, ln.BigThree, ln.BigFive, ln.BigEleven,
-		fmp.NewFmpz(13), fmp.NewFmpz(17), fmp.NewFmpz(19), fmp.NewFmpz(23)}
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "dixons",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostSlow,
+		Requires:   attacks.Requirements{MaxBits: 160},
+		F:          Attack,
+	})
+
+	attacks.SupportedAttacks.RegisterAlias("cfrac", "dixons")
+}
+
+const (
+	minFactorBase = 20
+	maxFactorBase = 3000
+	// extraRelations is how many relations beyond the factor base size to collect.
+	extraRelations = 16
 )
 
-// Attack implements the Dixon's factorization method.
-func Attack(ks []*keys.RSA) error {
+// multipliers are used in turn when the continued fraction expansion of sqrt(kn) runs out.
+var multipliers = []int64{1, 3, 5, 7, 11, 13, 15, 17, 19, 21, 23, 29, 31, 33, 35, 37, 39, 41, 43, 47}
+
+// relation records u such that u^2 = (-1)^neg * the product of the factor base primes at the
+// indexes in factors, modulo n.
+type relation struct {
+	u       *fmp.Fmpz
+	neg     bool
+	factors []int
+}
+
+// factorBaseSize returns the number of primes to use in the factor base for n. When size is
+// positive it is used as given.
+func factorBaseSize(n *fmp.Fmpz, size int) int {
+	if size > 0 {
+		return size
+	}
+
+	lnN := float64(n.BitLen()) * math.Ln2
+	size = int(math.Exp(0.35 * math.Sqrt(lnN*math.Log(lnN))))
+	switch {
+	case size < minFactorBase:
+		return minFactorBase
+	case size > maxFactorBase:
+		return maxFactorBase
+	}
+
+	return size
+}
+
+// factorBase returns size primes modulo which n is a quadratic residue. If one of the primes
+// divides n it is returned as the second value.
+func factorBase(n *fmp.Fmpz, size int) ([]*fmp.Fmpz, *fmp.Fmpz) {
+	fb := []*fmp.Fmpz{ln.BigTwo}
+	if n.TstBit(0) == 0 {
+		return nil, ln.BigTwo
+	}
+
+	for limit := size * 16; ; limit *= 2 {
+		fb = fb[:1]
+		for _, p := range ln.SieveOfEratosthenesFmp(limit)[1:] {
+			switch new(fmp.Fmpz).Mod(n, p).Jacobi(p) {
+			case 0:
+				return nil, p
+			case 1:
+				fb = append(fb, p)
+			}
+
+			if len(fb) == size {
+				return fb, nil
+			}
+		}
+	}
+}
+
+// smooth returns the factor base indexes of the prime factors of q, with repeats, or false if q
+// does not factor completely over the factor base.
+func smooth(q *fmp.Fmpz, fb []*fmp.Fmpz) ([]int, bool) {
+	if q.BitLen() < 63 {
+		return smallSmooth(q.Int64(), fb)
+	}
+
 	var (
-		k = ks[0]
-		n = k.Key.PublicKey.N
-		i = new(fmp.Fmpz).Sqrt(n)
+		factors []int
+		v       = new(fmp.Fmpz).Set(q)
+		r       = new(fmp.Fmpz)
 	)
 
-	for i.Cmp(n) < 0 {
-		for _, j := range base {
-			lhs := new(fmp.Fmpz).ExpXIM(i, 2, n)
-			rhs := new(fmp.Fmpz).ExpXIM(j, 2, n)
+	for j, p := range fb {
+		for {
+			if r.Mod(v, p); !r.IsZero() {
+				break
+			}
+
+			v.Div(v, p)
+			factors = append(factors, j)
+		}
 
-			if lhs.Equals(rhs) {
-				f := new(fmp.Fmpz).GCD(new(fmp.Fmpz).Sub(i, j), n)
-				if !f.Equals(ln.BigOne) {
-					k.PackGivenP(f)
-					return nil
+		if v.Equals(ln.BigOne) {
+			return factors, true
+		}
+	}
+
+	return nil, false
+}
+
+// smallSmooth is smooth for values of q that fit in a machine word.
+func smallSmooth(q int64, fb []*fmp.Fmpz) ([]int, bool) {
+	var factors []int
+	for j, fp := range fb {
+		p := fp.Int64()
+		for q%p == 0 {
+			q /= p
+			factors = append(factors, j)
+		}
+
+		if q == 1 {
+			return factors, true
+		}
+	}
+
+	return nil, false
+}
+
+// solve finds the dependencies between the relations and returns a proper factor of n if one
+// of them gives a congruence of squares that splits n.
+func solve(n *fmp.Fmpz, fb []*fmp.Fmpz, rels []*relation) *fmp.Fmpz {
+	ncols := len(fb) + 1
+	rows := make([]ln.GF2Row, len(rels))
+	for i, r := range rels {
+		rows[i] = ln.NewGF2Row(ncols)
+		if r.neg {
+			rows[i].Flip(0)
+		}
+
+		for _, j := range r.factors {
+			rows[i].Flip(j + 1)
+		}
+	}
+
+	for _, dep := range ln.NullSpaceGF2(rows, ncols) {
+		var (
+			x      = fmp.NewFmpz(1)
+			y      = fmp.NewFmpz(1)
+			counts = make([]int64, len(fb))
+		)
+
+		for _, i := range dep {
+			x.MulZ(rels[i].u).ModZ(n)
+			for _, j := range rels[i].factors {
+				counts[j]++
+			}
+		}
+
+		for j, c := range counts {
+			if c > 0 {
+				y.MulZ(new(fmp.Fmpz).Exp(fb[j], fmp.NewFmpz(c/2), n)).ModZ(n)
+			}
+		}
+
+		g := new(fmp.Fmpz).GCD(new(fmp.Fmpz).Sub(x, y), n)
+		if !g.Equals(ln.BigOne) && !g.Equals(n) {
+			return g
+		}
+	}
+
+	return nil
+}
+
+// factor returns a proper factor of n or nil if ctx is done or no factor was found.
+func factor(ctx context.Context, n *fmp.Fmpz, size int) *fmp.Fmpz {
+	fb, p := factorBase(n, factorBaseSize(n, size))
+	if p != nil {
+		return p
+	}
+
+	var (
+		rels []*relation
+		seen = make(map[string]bool)
+		need = len(fb) + extraRelations
+	)
+
+	for _, k := range multipliers {
+		kn := new(fmp.Fmpz).Mul(n, fmp.NewFmpz(k))
+		g := new(fmp.Fmpz).Sqrt(kn)
+
+		// Q_1 = kn - g^2, if it is 0 then kn is a square.
+		qNext := new(fmp.Fmpz).Mul(g, g)
+		qNext.Sub(kn, qNext)
+		if qNext.IsZero() {
+			if f := new(fmp.Fmpz).GCD(g, n); !f.Equals(ln.BigOne) && !f.Equals(n) {
+				return f
+			}
+
+			continue
+		}
+
+		var (
+			pi    = new(fmp.Fmpz).Set(g)
+			q     = fmp.NewFmpz(1)
+			ai    = new(fmp.Fmpz).Mod(g, n)
+			aPrev = fmp.NewFmpz(1)
+		)
+
+		// A_i^2 = (-1)^(i+1) * Q_(i+1) mod n for the convergents A_i / B_i of sqrt(kn). The
+		// expansion is periodic and starts again once Q_(i+1) is 1.
+		for i := 0; !qNext.Equals(ln.BigOne); i++ {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			if factors, ok := smooth(qNext, fb); ok && !seen[ai.String()] {
+				seen[ai.String()] = true
+				rels = append(rels, &relation{u: new(fmp.Fmpz).Set(ai), neg: i%2 == 0, factors: factors})
+
+				if len(rels) >= need {
+					if f := solve(n, fb, rels); f != nil {
+						return f
+					}
+
+					need += extraRelations
 				}
 			}
+
+			// a_(i+1) = (g + P_(i+1)) / Q_(i+1)
+			a := new(fmp.Fmpz).Add(g, pi)
+			a.Div(a, qNext)
+
+			// P_(i+2) = a_(i+1) * Q_(i+1) - P_(i+1)
+			piNext := new(fmp.Fmpz).Mul(a, qNext)
+			piNext.Sub(piNext, pi)
+
+			// Q_(i+2) = Q_i + a_(i+1) * (P_(i+1) - P_(i+2))
+			qNext2 := new(fmp.Fmpz).Sub(pi, piNext)
+			qNext2.Mul(qNext2, a).Add(qNext2, q)
+
+			// A_(i+1) = a_(i+1) * A_i + A_(i-1) mod n
+			aiNext := new(fmp.Fmpz).Mul(a, ai)
+			aiNext.Add(aiNext, aPrev).ModZ(n)
+
+			q, qNext, pi = qNext, qNext2, piNext
+			aPrev, ai = ai, aiNext
+		}
+	}
+
+	return nil
+}
+
+// Attack implements Dixon's factorization method with continued fraction relations.
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
+	k := ks[0]
+	if k.Key.D != nil {
+		return attacks.Solved(k)
+	}
+
+	f := factor(ctx, k.Key.N, k.FactorBaseSize)
+	if f == nil {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
 		}
 
-		i.AddI(1)
+		return attacks.Failed("%s failed to find a factor", name)
 	}
 
-	return fmt.Errorf("%s failed to find a factor", name)
+	k.PackGivenP(f)
+
+	return attacks.Solved(k)
 }
@@ -1,6 +1,7 @@
 package dixons
 
 import (
+	"context"
 	"testing"
 
 	"github.com/sourcekris/goRsaTool/keys"
@@ -15,6 +16,7 @@ func TestAttack(t *testing.T) {
 		name string
 		n    *fmp.Fmpz
 		e    *fmp.Fmpz
+		size int
 		want *fmp.Fmpz
 	}{
 		{
@@ -23,6 +25,25 @@ func TestAttack(t *testing.T) {
 			e:    ln.FmpString("3"),
 			want: ln.FmpString("7919"),
 		},
+		{
+			name: "24 digit modulus",
+			n:    ln.FmpString("885018525533795756320081"),
+			e:    ln.FmpString("65537"),
+			want: ln.FmpString("979534298993"),
+		},
+		{
+			name: "33 digit modulus",
+			n:    ln.FmpString("223437604355710766662224889375963"),
+			e:    ln.FmpString("65537"),
+			want: ln.FmpString("15825190558182839"),
+		},
+		{
+			name: "33 digit modulus with a given factor base size",
+			n:    ln.FmpString("223437604355710766662224889375963"),
+			e:    ln.FmpString("65537"),
+			size: 60,
+			want: ln.FmpString("15825190558182839"),
+		},
 	}
 
 	for _, tc := range tt {
@@ -32,7 +53,8 @@ func TestAttack(t *testing.T) {
 		}
 
 		k, _ := keys.NewRSA(keys.PrivateFromPublic(fmpPubKey), nil, nil, "", false)
-		err := Attack([]*keys.RSA{k})
+		k.FactorBaseSize = tc.size
+		err := Attack(context.Background(), []*keys.RSA{k}).Err()
 		if err != nil {
 			t.Errorf("Attack() failed: %s expected no error got error: %v", tc.name, err)
 		}
@@ -0,0 +1,77 @@
+// Package dpleak factors n given one of the CRT exponents dp = d mod (p - 1) or dq. Since
+// e * dp = 1 mod (p - 1) then m^(e * dp) = m mod p for any m, so p divides m^(e * dp) - m.
+package dpleak
+
+import (
+	"context"
+
+	"github.com/sourcekris/goRsaTool/attacks"
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// name is the name of this attack.
+const name = "dp leak"
+
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "dpleak",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostInstant,
+		Requires:   attacks.Requirements{CRTExponent: true},
+		F:          Attack,
+	})
+}
+
+// bases are the values of m tried in turn, more than one is needed only when m^(e * dp) = m
+// mod q as well.
+var bases = []int64{2, 3, 5, 7, 11, 13}
+
+// factor returns the factor of n that divides m^(e * x) - m for a CRT exponent x or nil.
+func factor(n, e, x *fmp.Fmpz) *fmp.Fmpz {
+	ex := new(fmp.Fmpz).Mul(e, x)
+	for _, b := range bases {
+		m := fmp.NewFmpz(b)
+		v := new(fmp.Fmpz).Exp(m, ex, n)
+		v.Sub(v, m)
+
+		if g := new(fmp.Fmpz).GCD(v, n); g.Cmp(ln.BigOne) > 0 && g.Cmp(n) < 0 {
+			return g
+		}
+	}
+
+	return nil
+}
+
+// Attack implements the dp leak attack.
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
+	k := ks[0]
+	if k.Key.D != nil {
+		return attacks.Solved(k)
+	}
+
+	pc := k.Key.Precomputed
+	if pc == nil || pc.Dp == nil && pc.Dq == nil {
+		return attacks.NotApplicable("%s failed - supply dp or dq using a 'dp = ' or 'dq = ' field in the key", name)
+	}
+
+	for _, x := range []*fmp.Fmpz{pc.Dp, pc.Dq} {
+		if x == nil {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
+		if p := factor(k.Key.N, k.Key.PublicKey.E, x); p != nil {
+			k.PackGivenP(p)
+			return attacks.Solved(k)
+		}
+	}
+
+	return attacks.Failed("%s failed - the CRT exponent does not match the key", name)
+}
@@ -0,0 +1,62 @@
+package dpleak
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+	"github.com/sourcekris/goRsaTool/utils"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+func TestAttack(t *testing.T) {
+	var (
+		n = ln.FmpString("67779169991156313953976086119991464718779730349607858782745496509542441091211")
+		c = ln.FmpString("18006965463592284718183937125580237084448294800599062432536115605388753853998")
+		p = ln.FmpString("206649527463365580008337435483770722577")
+	)
+
+	tt := []struct {
+		name    string
+		pc      *keys.PrecomputedValues
+		wantErr bool
+	}{
+		{
+			name: "dp",
+			pc:   &keys.PrecomputedValues{Dp: ln.FmpString("158026109236691325888728627134648199617")},
+		},
+		{
+			name: "dq",
+			pc:   &keys.PrecomputedValues{Dq: ln.FmpString("270110174835083007976468874637424524317")},
+		},
+		{
+			name:    "wrong dp",
+			pc:      &keys.PrecomputedValues{Dp: ln.FmpString("158026109236691325888728627134648199619")},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: n, E: fmp.NewFmpz(17)}), ln.NumberToBytes(c), nil, "", false)
+		k.Key.Precomputed = tc.pc
+
+		err := Attack(context.Background(), []*keys.RSA{k}).Err()
+		if (err != nil) != tc.wantErr {
+			t.Errorf("Attack() %s: want error %v got %v", tc.name, tc.wantErr, err)
+		}
+
+		if tc.wantErr {
+			continue
+		}
+
+		if !utils.FoundP(p, k.Key.Primes) {
+			t.Errorf("Attack() %s: expected primes not found - got %v wanted %v", tc.name, k.Key.Primes, p)
+		}
+
+		if string(k.PlainText) != "dp leak" {
+			t.Errorf("Attack() %s: want plaintext %q got %q", tc.name, "dp leak", k.PlainText)
+		}
+	}
+}
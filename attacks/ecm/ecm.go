@@ -4,11 +4,13 @@
 package ecm
 
 import (
+	"context"
 	"fmt"
 	"log"
 
 	"github.com/jbarham/primegen"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -151,7 +153,7 @@ func (p point) Mul(k uint64, n, a, res *fmp.Fmpz) point {
 // }
 
 // Attack implements the ECM factorization method.
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 	var (
 		k     = ks[0]
 		res   = new(fmp.Fmpz)
@@ -164,6 +166,10 @@ func Attack(ks []*keys.RSA, ch chan error) {
 
 	pg := primegen.New()
 	for {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		a := ln.GetRand(state, k.Key.N)
 		if new(fmp.Fmpz).ExpXI(a, 3).MulI(4).AddI(27).ModZ(k.Key.N).IsZero() {
 			// n divides 4a^3+27 - curve has repeating factors, so skip it.
@@ -172,6 +178,10 @@ func Attack(ks []*keys.RSA, ch chan error) {
 
 		p := point{fmp.NewFmpz(0), fmp.NewFmpz(1)}
 		for {
+			if ctx.Err() != nil {
+				return attacks.Stopped(ctx.Err())
+			}
+
 			p = p.Mul(pg.Next(), k.Key.N, a, res)
 			if p.Zero() {
 				// this curve didn't work
@@ -180,8 +190,7 @@ func Attack(ks []*keys.RSA, ch chan error) {
 
 			if res != nil && res.Cmp(ln.BigZero) > 0 {
 				k.PackGivenP(res)
-				ch <- nil
-				return
+				return attacks.Solved(k)
 			}
 		}
 	}
@@ -1,13 +1,14 @@
 package factordb
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
 	"regexp"
 	"time"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -17,6 +18,16 @@ import (
 // name is the name of this attack.
 const name = "factordb factorization"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "factordb",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostNetwork,
+		F:          Attack,
+	})
+}
+
 var (
 	base       = "http://www.factordb.com/api?query="
 	query      = "index.php?query="
@@ -26,7 +37,7 @@ var (
 )
 
 type factorDB struct {
-	ID      int          	`json:"id"`
+	ID      int             `json:"id"`
 	Status  string          `json:"status"`
 	Factors [][]interface{} `json:"factors"`
 }
@@ -35,8 +46,13 @@ type factorDB struct {
 var asker = askFactorDB
 
 // askFactorDB abstracts out the HTTP get so we can mock factordb in unit tests.
-func askFactorDB(hc *http.Client, url string) (*http.Response, error) {
-	resp, err := hc.Get(url)
+func askFactorDB(ctx context.Context, hc *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := hc.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -45,54 +61,47 @@ func askFactorDB(hc *http.Client, url string) (*http.Response, error) {
 }
 
 // Attack factors an RSA Public Key using FactorDB API.
-func Attack(ts []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ts []*keys.RSA) *attacks.Result {
 	t := ts[0]
 	if t.Key.D != nil {
 		// Key already factored.
-		ch <- nil
-		return
+		return attacks.Solved(t)
 	}
 
 	hc := &http.Client{
 		Timeout: 15 * time.Second,
 	}
 
-	r, err := asker(hc, base+t.Key.N.String())
+	r, err := asker(ctx, hc, base+t.Key.N.String())
 	if err != nil {
-		ch <- err
-		return
+		return attacks.Failed("%s failed: %v", name, err)
 	}
 	defer r.Body.Close()
 
 	if r.StatusCode != 200 {
-		ch <- fmt.Errorf("%s failed to lookup modulus - unexpected http code: %d", name, r.StatusCode)
-		return
+		return attacks.Failed("%s failed to lookup modulus - unexpected http code: %d", name, r.StatusCode)
 	}
 
 	js, err := io.ReadAll(r.Body)
 	if err != nil {
-		ch <- err
-		return
+		return attacks.Failed("%s failed: %v", name, err)
 	}
 
 	fdb := factorDB{}
 	if err := json.Unmarshal(js, &fdb); err != nil {
-		ch <- err
-		return
+		return attacks.Failed("%s failed: %v", name, err)
 	}
 
 	if fdb.Status == "FF" {
 		if len(fdb.Factors) < 1 {
-			ch <- fmt.Errorf("%s failed due to an unknown error - modulus status is FF but primes were not found", name)
-			return
+			return attacks.Failed("%s failed due to an unknown error - modulus status is FF but primes were not found", name)
 		}
 
 		var primes []*fmp.Fmpz
 		for _, f := range fdb.Factors {
 			prime, ok := f[0].(string)
 			if !ok {
-				ch <- fmt.Errorf("%s failed asserting that the factor is a string: %v", name, f)
-				return
+				return attacks.Failed("%s failed asserting that the factor is a string: %v", name, f)
 			}
 			primes = append(primes, ln.FmpString(prime))
 		}
@@ -100,18 +109,15 @@ func Attack(ts []*keys.RSA, ch chan error) {
 		// RSA normally has 2 primes but can have more. Handle the simple case first.
 		if len(primes) == 2 {
 			t.PackGivenP(primes[0])
-			ch <- nil
-			return
+			return attacks.Solved(t)
 		}
 
 		if err := t.PackMultiPrime(primes); err != nil {
-			ch <- err
-			return
+			return attacks.Failed("%s failed: %v", name, err)
 		}
 
-		ch <- nil
-		return
+		return attacks.Solved(t)
 	}
 
-	ch <- fmt.Errorf("%s failed - the modulus is not fully factored on factordb (status = %s)", name, fdb.Status)
+	return attacks.Failed("%s failed - the modulus is not fully factored on factordb (status = %s)", name, fdb.Status)
 }
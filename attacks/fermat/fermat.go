@@ -1,8 +1,10 @@
 package fermat
 
 import (
+	"context"
 	"log"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -12,13 +14,24 @@ import (
 // name is the name of this attack.
 const name = "fermat factorization"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "fermat",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostFast,
+		F:          Attack,
+	})
+
+	attacks.SupportedAttacks.RegisterAlias("sexyprimes", "fermat")
+}
+
 // Attack implements the Fermat Factorization attack.
-func Attack(ts []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ts []*keys.RSA) *attacks.Result {
 	t := ts[0]
 	if t.Key.D != nil {
 		// Key already factored.
-		ch <- nil
-		return
+		return attacks.Solved(t)
 	}
 
 	a := new(fmp.Fmpz).Sqrt(t.Key.N)
@@ -31,6 +44,10 @@ func Attack(ts []*keys.RSA, ch chan error) {
 	}
 	c := new(fmp.Fmpz).Mul(b, b)
 	for !c.Equals(b2) {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		a.Add(a, ln.BigOne)
 		b2.Mul(a, a).Sub(b2, t.Key.N)
 		b.Sqrt(b2)
@@ -38,5 +55,5 @@ func Attack(ts []*keys.RSA, ch chan error) {
 	}
 
 	t.PackGivenP(new(fmp.Fmpz).Add(a, b))
-	ch <- nil
+	return attacks.Solved(t)
 }
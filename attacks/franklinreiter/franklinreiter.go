@@ -1,9 +1,10 @@
 package franklinreiter
 
 import (
-	"fmt"
+	"context"
 	"log"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -13,6 +14,18 @@ import (
 // name is the name of this attack.
 const name = "franklin reiter related message attack"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:          "franklinreiter",
+		SupportsMulti: true,
+		Unnatended:    true,
+		Timeout:       attacks.DefaultTimeout,
+		Cost:          attacks.CostSlow,
+		Requires:      attacks.Requirements{CipherText: true, KnownPlainText: true, MinKeys: 2, MaxKeys: 2},
+		F:             Attack,
+	})
+}
+
 type sigAttack struct {
 	cs []*fmp.Fmpz
 	ss []*fmp.Fmpz
@@ -26,36 +39,36 @@ var (
 )
 
 // attempt runs the attack attempt itself.
-func (s *sigAttack) attempt(v bool) []byte {
+func (s *sigAttack) attempt(ctx context.Context, v bool) []byte {
 	// f = (x-s1+s2)^e - c1
-	ctx := modctx(s.n)
-	f := modpoly(ctx).SetCoeffUI(1, 1)
-	f.Sub(f, modpoly(ctx).SetCoeff(0, s.ss[1])).Add(f, modpoly(ctx).SetCoeff(0, s.ss[0])).Pow(f, s.e)
-	f.Sub(f, modpoly(ctx).SetCoeff(0, s.cs[0]))
+	mctx := modctx(s.n)
+	f := modpoly(mctx).SetCoeffUI(1, 1)
+	f.Sub(f, modpoly(mctx).SetCoeff(0, s.ss[1])).Add(f, modpoly(mctx).SetCoeff(0, s.ss[0])).Pow(f, s.e)
+	f.Sub(f, modpoly(mctx).SetCoeff(0, s.cs[0]))
 
 	// g = x^e-c2
-	g := modpoly(ctx).SetCoeffUI(1, 1)
-	g.Pow(g, s.e).Sub(g, modpoly(ctx).SetCoeff(0, s.cs[1]))
+	g := modpoly(mctx).SetCoeffUI(1, 1)
+	g.Pow(g, s.e).Sub(g, modpoly(mctx).SetCoeff(0, s.cs[1]))
 
 	a := modpoly(modctx(f.GetMod())).Set(f)
 	b := modpoly(modctx(g.GetMod())).Set(g)
 
-	zero := modpoly(ctx).Zero()
-	rp := modpoly(ctx)
+	zero := modpoly(mctx).Zero()
+	rp := modpoly(mctx)
 
 	if v {
 		log.Printf("%s beginning, this can sometimes crash, try it again if it does.", name)
 	}
 
 	var r *fmp.FmpzModPoly
-	for {
+	for ctx.Err() == nil {
 		_, r = a.DivRem(b)
 
 		if r.Equal(zero) {
 			co0 := rp.GetCoeff(0)
 			co1 := rp.GetCoeff(1)
 
-			q, _ := modpoly(ctx).SetCoeff(0, ln.BigOne).DivRem(modpoly(ctx).SetCoeff(0, co1))
+			q, _ := modpoly(mctx).SetCoeff(0, ln.BigOne).DivRem(modpoly(mctx).SetCoeff(0, co1))
 			q.MulScalar(q, ln.BigNOne).MulScalar(q, co0)
 			return ln.NumberToBytes(q.GetCoeff(0))
 		}
@@ -64,23 +77,22 @@ func (s *sigAttack) attempt(v bool) []byte {
 		a.Set(b)
 		b.Set(r)
 	}
+
+	return nil
 }
 
 // Attack implements the franklin reiter related message attack against two keys.
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 	if len(ks) != 2 {
-		ch <- fmt.Errorf("%s requires exactly 2 keys to work - got %d", name, len(ks))
-		return
+		return attacks.NotApplicable("%s requires exactly 2 keys to work - got %d", name, len(ks))
 	}
 
 	if ks[0].KnownPlainText == nil || ks[1].KnownPlainText == nil {
-		ch <- fmt.Errorf("%s requires each key has a corresponding known plaintext component", name)
-		return
+		return attacks.NotApplicable("%s requires each key has a corresponding known plaintext component", name)
 	}
 
 	if ks[0].CipherText == nil || ks[1].CipherText == nil {
-		ch <- fmt.Errorf("%s requires each key has a corresponding ciphertext", name)
-		return
+		return attacks.NotApplicable("%s requires each key has a corresponding ciphertext", name)
 	}
 
 	sa := &sigAttack{n: ks[0].Key.N, e: ks[0].Key.PublicKey.E.GetInt()}
@@ -89,11 +101,14 @@ func Attack(ks []*keys.RSA, ch chan error) {
 		sa.cs = append(sa.cs, ln.BytesToNumber(ks[i].CipherText))
 	}
 
-	if res := sa.attempt(ks[0].Verbose); res != nil {
+	if res := sa.attempt(ctx, ks[0].Verbose); res != nil {
 		ks[0].PlainText = res
-		ch <- nil
-		return
+		return attacks.Solved(ks[0])
+	}
+
+	if ctx.Err() != nil {
+		return attacks.Stopped(ctx.Err())
 	}
 
-	ch <- fmt.Errorf("%s failed to recover the plaintext", name)
+	return attacks.Failed("%s failed to recover the plaintext", name)
 }
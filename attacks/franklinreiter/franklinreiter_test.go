@@ -1,6 +1,7 @@
 package franklinreiter
 
 import (
+	"context"
 	"testing"
 
 	"github.com/sourcekris/goRsaTool/keys"
@@ -38,9 +39,7 @@ func TestAttack(t *testing.T) {
 		k2, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: tc.n, E: tc.e}), ln.NumberToBytes(tc.c2), nil, "", false)
 		k1.KnownPlainText = []byte(tc.s1)
 		k2.KnownPlainText = []byte(tc.s2)
-		ch := make(chan error)
-		go Attack([]*keys.RSA{k1, k2}, ch)
-		err := <-ch
+		err := Attack(context.Background(), []*keys.RSA{k1, k2}).Err()
 		if err != nil {
 			t.Errorf("Attack() failed: %s expected no error got error: %v", tc.name, err)
 		}
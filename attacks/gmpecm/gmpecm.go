@@ -1,10 +1,13 @@
 package gmpecm
 
 import (
-	"fmt"
+	"context"
+	"log"
+	"time"
 
 	"github.com/sourcekris/goRsaTool/ln"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 
 	fmp "github.com/sourcekris/goflint"
@@ -14,36 +17,129 @@ import (
 // name is the name of this attack.
 const name = "gmp-ecm elliptic curve factorization"
 
-// Attack implements the elliptic curve factorization attack against public keys.
-func Attack(ks []*keys.RSA, ch chan error) {
-	var (
-		k   = ks[0]
-		res = new(ecm.Mpz)
-	)
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "ecm",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostSlow,
+		F:          Attack,
+	})
+}
 
-	n, ok := new(ecm.Mpz).SetString(k.Key.N.String(), 10)
-	if !ok {
-		ch <- fmt.Errorf("%s failed to construct an Mpz from modulus", name)
-		return
+// curve runs a single curve with the bound b1 and returns the factor of n it found or nil. A curve
+// runs in C and cannot be interrupted so curve stops waiting for it when ctx is done and leaves it
+// to finish in the background. Attack only starts curves expected to finish before the deadline
+// of ctx so that such a curve does not outlive the time the attack was given.
+func curve(ctx context.Context, n *ecm.Mpz, b1 uint64) *fmp.Fmpz {
+	res := make(chan *ecm.Mpz, 1)
+	go func() {
+		// Fresh params each time as a zero sigma is replaced by the random sigma of the curve.
+		f, err := ecm.NewParams().FactorGivenB1(n, b1)
+		if err != nil {
+			f = nil
+		}
+		res <- f
+	}()
+
+	var f *ecm.Mpz
+	select {
+	case f = <-res:
+	case <-ctx.Done():
+		return nil
+	}
+
+	if f == nil {
+		return nil
 	}
 
-	p := ecm.NewParams()
-	res, err := p.Factor(n)
-	if err != nil {
-		ch <- err
-		return
+	p, ok := new(fmp.Fmpz).SetString(f.String(), 10)
+	if !ok || p.Cmp(ln.BigOne) <= 0 {
+		return nil
+	}
+
+	return p
+}
+
+// fits returns true if a curve with the bound b1 is expected to finish within left, given that the
+// last curve with the bound last took took. The time a curve takes grows about linearly with B1.
+func fits(b1, last uint64, took, left time.Duration) bool {
+	if last == 0 {
+		return true
+	}
+
+	return time.Duration(float64(took)*float64(b1)/float64(last)) < left
+}
+
+// Attack implements the elliptic curve factorization attack against public keys. It runs the
+// recommended number of curves for each B1 up to the one for a factor of half the size of n and
+// then keeps running curves with that B1 until ctx is done, checking ctx between curves. When ctx
+// has a deadline B1 stops growing once a curve would not finish in time and the attack stops
+// when even a curve with the current B1 would not.
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
+	k := ks[0]
+	if k.Key.D != nil {
+		return attacks.Solved(k)
 	}
 
-	ff, ok := new(fmp.Fmpz).SetString(res.String(), 10)
+	n, ok := new(ecm.Mpz).SetString(k.Key.N.String(), 10)
 	if !ok {
-		ch <- fmt.Errorf("%s failed to parse the factor: %q", name, res.String())
-		return
+		return attacks.Failed("%s failed to construct an Mpz from modulus", name)
+	}
+
+	var (
+		opt    = n.OptimalB1()
+		levels []ecm.B1
+	)
+
+	for _, b := range ecm.OptimalB1s {
+		levels = append(levels, b)
+		if b.Bits >= opt.Bits {
+			break
+		}
 	}
-	if ff.Cmp(ln.BigZero) > 0 {
-		k.PackGivenP(ff)
-		ch <- nil
-		return
+
+	var (
+		deadline, hasDeadline = ctx.Deadline()
+		last                  uint64
+		took                  time.Duration
+	)
+
+	// inTime returns true if a curve with the bound b1 is expected to finish before the deadline.
+	inTime := func(b1 uint64) bool {
+		return !hasDeadline || fits(b1, last, took, time.Until(deadline))
 	}
 
-	ch <- fmt.Errorf("%s was unable to factor the key", name)
+	for l := 0; ; {
+		b := levels[l]
+		if k.Verbose {
+			log.Printf("%s: running %d curves with B1 = %d", name, b.Curves, b.B1)
+		}
+
+		for c := 0; c < b.Curves; c++ {
+			if !inTime(b.B1) {
+				if k.Verbose {
+					log.Printf("%s: stopping as a curve with B1 = %d would not finish in time", name, b.B1)
+				}
+
+				return attacks.Stopped(context.DeadlineExceeded)
+			}
+
+			start := time.Now()
+			p := curve(ctx, n, b.B1)
+			if ctx.Err() != nil {
+				return attacks.Stopped(ctx.Err())
+			}
+
+			last, took = b.B1, time.Since(start)
+			if p != nil && p.Cmp(k.Key.N) < 0 {
+				k.PackGivenP(p)
+				return attacks.Solved(k)
+			}
+		}
+
+		if l+1 < len(levels) && inTime(levels[l+1].B1) {
+			l++
+		}
+	}
 }
@@ -1,8 +1,11 @@
 package gmpecm
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 	"github.com/sourcekris/goRsaTool/utils"
@@ -30,9 +33,11 @@ func TestAttack(t *testing.T) {
 		}
 
 		k, _ := keys.NewRSA(keys.PrivateFromPublic(fmpPubKey), nil, nil, "", false)
-		ch := make(chan error)
-		go Attack([]*keys.RSA{k}, ch)
-		err := <-ch
+
+		// ecm runs until it succeeds or ctx is done.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		err := Attack(ctx, []*keys.RSA{k}).Err()
+		cancel()
 		if err != nil {
 			t.Errorf("Attack() failed: %s expected no error got error: %v", tc.name, err)
 		}
@@ -42,3 +47,56 @@ func TestAttack(t *testing.T) {
 		}
 	}
 }
+
+func TestFits(t *testing.T) {
+	tt := []struct {
+		name string
+		b1   uint64
+		last uint64
+		took time.Duration
+		left time.Duration
+		want bool
+	}{
+		{
+			name: "no curve run yet",
+			b1:   1000000,
+			left: time.Second,
+			want: true,
+		},
+		{
+			name: "same B1 with time to spare",
+			b1:   1000,
+			last: 1000,
+			took: time.Second,
+			left: 2 * time.Second,
+			want: true,
+		},
+		{
+			name: "ten times the B1 does not fit",
+			b1:   10000,
+			last: 1000,
+			took: time.Second,
+			left: 5 * time.Second,
+		},
+	}
+
+	for _, tc := range tt {
+		if got := fits(tc.b1, tc.last, tc.took, tc.left); got != tc.want {
+			t.Errorf("fits() %s: want %v got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestAttackStopped(t *testing.T) {
+	k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{
+		N: ln.FmpString("67779169991156313953976086119991464718779730349607858782745496509542441091211"),
+		E: fmp.NewFmpz(65537),
+	}), nil, nil, "", false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if res := Attack(ctx, []*keys.RSA{k}); res.Status != attacks.StatusTimedOut {
+		t.Errorf("Attack() want a stopped result for a cancelled context got %v", res)
+	}
+}
@@ -1,9 +1,10 @@
 package hastads
 
 import (
-	"fmt"
+	"context"
 	"log"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -13,17 +14,32 @@ import (
 // name is the name of this attack.
 const name = "hastads"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "hastads",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostInstant,
+		Requires:   attacks.Requirements{CipherText: true, MaxE: 11},
+		F:          Attack,
+	})
+
+	attacks.SupportedAttacks.RegisterAlias("smalle", "hastads")
+}
+
 // Attack implements the Hastads attack.
-func Attack(ts []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ts []*keys.RSA) *attacks.Result {
 	t := ts[0]
-	if t.Key.D != nil || t.Key.PublicKey.E.Cmp(ln.BigEleven) > 0 {
-		ch <- nil
-		return
+	if t.Key.D != nil {
+		return attacks.Solved(t)
+	}
+
+	if t.Key.PublicKey.E.Cmp(ln.BigEleven) > 0 {
+		return attacks.NotApplicable("%s failed - e is too large for this attack: %v", name, t.Key.PublicKey.E)
 	}
 
 	if t.CipherText == nil {
-		ch <- fmt.Errorf("%s failed - ciphertext needs to be provided for this attack", name)
-		return
+		return attacks.NotApplicable("%s failed - ciphertext needs to be provided for this attack", name)
 	}
 
 	c := ln.BytesToNumber(t.CipherText)
@@ -37,6 +53,10 @@ func Attack(ts []*keys.RSA, ch chan error) {
 	pow := new(fmp.Fmpz)
 	original := new(fmp.Fmpz).Set(c)
 	for {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		m.Root(c, int32(t.Key.PublicKey.E.Int64()))
 		pow.Exp(m, t.Key.PublicKey.E, t.Key.N)
 
@@ -48,5 +68,5 @@ func Attack(ts []*keys.RSA, ch chan error) {
 	}
 
 	t.PlainText = ln.NumberToBytes(pt)
-	ch <- nil
+	return attacks.Solved(t)
 }
@@ -1,8 +1,9 @@
 package hastadsbroadcast
 
 import (
-	"fmt"
+	"context"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -12,23 +13,32 @@ import (
 // name is the name of this attack.
 const name = "hastads broadcast"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:          "hastadsbroadcast",
+		SupportsMulti: true,
+		Unnatended:    true,
+		Timeout:       attacks.DefaultTimeout,
+		Cost:          attacks.CostInstant,
+		Requires:      attacks.Requirements{CipherText: true, MinKeys: 2, MinE: 3, MaxE: 3},
+		F:             Attack,
+	})
+}
+
 // Attack implements the hastads broadcast attack against three keys and their ciphertexts.
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 	// Check key parameters are compatible with the attack.
 	if len(ks) < 2 {
-		ch <- fmt.Errorf("%s attack requires 2+ public keys, got: %d", name, len(ks))
-		return
+		return attacks.NotApplicable("%s attack requires 2+ public keys, got: %d", name, len(ks))
 	}
 	for _, k := range ks {
 		if k.CipherText == nil {
-			ch <- fmt.Errorf("%s failed - supply ciphertext for each key", name)
-			return
+			return attacks.NotApplicable("%s failed - supply ciphertext for each key", name)
 		}
 
 		// It's possible this works for other small primes though.
 		if k.Key.PublicKey.E.Cmp(ln.BigThree) > 0 {
-			ch <- fmt.Errorf("%s failed - exponents should be 3 but key exponent is: %v", name, k.Key.PublicKey.E)
-			return
+			return attacks.NotApplicable("%s failed - exponents should be 3 but key exponent is: %v", name, k.Key.PublicKey.E)
 		}
 	}
 
@@ -47,9 +57,8 @@ func Attack(ks []*keys.RSA, ch chan error) {
 	test := new(fmp.Fmpz).ExpXIM(solution, k, ks[0].Key.N)
 	if test.Equals(ln.BytesToNumber(ks[0].CipherText)) {
 		ks[0].PlainText = ln.NumberToBytes(solution)
-		ch <- nil
-		return
+		return attacks.Solved(ks[0])
 	}
 
-	ch <- fmt.Errorf("%s attack failed", name)
+	return attacks.Failed("%s attack failed", name)
 }
@@ -1,6 +1,7 @@
 package hastadsbroadcast
 
 import (
+	"context"
 	"testing"
 
 	"github.com/sourcekris/goRsaTool/keys"
@@ -54,9 +55,7 @@ func TestAttack(t *testing.T) {
 			}),
 		})
 
-		ch := make(chan error)
-		go Attack(ks, ch)
-		err := <-ch
+		err := Attack(context.Background(), ks).Err()
 		if err != nil {
 			t.Errorf("%s failed - got unexpected error: %v", tc.name, err)
 		}
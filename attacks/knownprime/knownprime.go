@@ -1,9 +1,10 @@
 package knownprime
 
 import (
-	"fmt"
+	"context"
 	"log"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 	fmp "github.com/sourcekris/goflint"
@@ -12,24 +13,31 @@ import (
 // name is the name of this attack.
 const name = "knownprime"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:     "knownprime",
+		Timeout:  attacks.DefaultTimeout,
+		Cost:     attacks.CostInstant,
+		Requires: attacks.Requirements{KnownPrime: true},
+		F:        Attack,
+	})
+}
+
 // Attack implements the knownprime attack.
-func Attack(ts []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ts []*keys.RSA) *attacks.Result {
 	t := ts[0]
 	if t.Key.D != nil {
-		ch <- nil
-		return
+		return attacks.Solved(t)
 	}
 
 	if t.Key.Primes == nil {
-		ch <- fmt.Errorf("%s attack failed, no prime provided. Use the -p flag or provide a 'p = ' field in the key", name)
-		return
+		return attacks.NotApplicable("%s attack failed, no prime provided. Use the -p flag or provide a 'p = ' field in the key", name)
 	}
 
 	// Sanity check the prime is actually a factor of n.
 	f := new(fmp.Fmpz).Mod(t.Key.N, t.Key.Primes[0])
 	if f.Cmp(ln.BigZero) != 0 {
-		ch <- fmt.Errorf("provided prime is not a factor of n: p %% n = %v", f)
-		return
+		return attacks.Failed("provided prime is not a factor of n: p %% n = %v", f)
 	}
 
 	if t.Verbose {
@@ -37,5 +45,5 @@ func Attack(ts []*keys.RSA, ch chan error) {
 	}
 
 	t.PackGivenP(t.Key.Primes[0])
-	ch <- nil
+	return attacks.Solved(t)
 }
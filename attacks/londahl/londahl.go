@@ -4,10 +4,11 @@
 package londahl
 
 import (
-	"fmt"
+	"context"
 	"hash/fnv"
 	"log"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -17,6 +18,16 @@ import (
 // name is the name of this attack.
 const name = "londahl"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "londahl",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostFast,
+		F:          Attack,
+	})
+}
+
 func factorizeNPhi(n, phi *fmp.Fmpz) (*fmp.Fmpz, *fmp.Fmpz) {
 	m := new(fmp.Fmpz).Sub(n, phi).AddI(1)
 	i := new(fmp.Fmpz).Root(new(fmp.Fmpz).Sub(new(fmp.Fmpz).ExpXI(m, 2), new(fmp.Fmpz).Mul(n, ln.BigFour)), 2)
@@ -37,11 +48,10 @@ func storeInt(z, n *fmp.Fmpz, m map[uint64]int64, i int64) {
 }
 
 // Attack implements the Londahl attack.
-func Attack(ts []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ts []*keys.RSA) *attacks.Result {
 	t := ts[0]
 	if t.Key.D != nil {
-		ch <- nil
-		return
+		return attacks.Solved(t)
 	}
 	// Create a pointer where we can store the result.
 	p := new(fmp.Fmpz)
@@ -58,6 +68,10 @@ func Attack(ts []*keys.RSA, ch chan error) {
 	// Generate a lookup table, store just the fnv hash of the integer to save memory.
 	z := fmp.NewFmpz(1)
 	for i := int64(0); i <= b; i++ {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		storeInt(z, t.Key.N, lookup, i)
 		z = z.Lsh(1).ModZ(t.Key.N)
 	}
@@ -66,6 +80,10 @@ func Attack(ts []*keys.RSA, ch chan error) {
 	fac := new(fmp.Fmpz).ExpXIM(ln.BigTwo, int(b), t.Key.N)
 
 	for i := int64(0); i <= b; i++ {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		h := fnv.New64()
 		h.Write(mu.Bytes())
 		if v, ok := lookup[h.Sum64()]; ok {
@@ -74,13 +92,12 @@ func Attack(ts []*keys.RSA, ch chan error) {
 			if r1 != nil {
 				p.Set(r1)
 				t.PackGivenP(p)
-				ch <- nil
-				return
+				return attacks.Solved(t)
 			}
 		}
 
 		mu = mu.Mul(mu, fac).ModZ(t.Key.N)
 	}
 
-	ch <- fmt.Errorf("%s failed to recover the private key", name)
+	return attacks.Failed("%s failed to recover the private key", name)
 }
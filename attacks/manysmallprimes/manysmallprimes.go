@@ -1,9 +1,11 @@
 package manysmallprimes
 
 import (
+	"context"
 	"log"
 
 	"github.com/jbarham/primegen"
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -13,8 +15,18 @@ import (
 // name is the name of this attack.
 const name = "manysmallprimes"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "manysmallprimes",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostFast,
+		F:          Attack,
+	})
+}
+
 // Attack iterates small primes until we timeout and test them as factors of N.
-func Attack(ts []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ts []*keys.RSA) *attacks.Result {
 
 	var (
 		p         = primegen.New()
@@ -23,8 +35,7 @@ func Attack(ts []*keys.RSA, ch chan error) {
 	)
 
 	if t.Key.D != nil {
-		ch <- nil
-		return
+		return attacks.Solved(t)
 	}
 
 	if t.Verbose {
@@ -35,6 +46,10 @@ func Attack(ts []*keys.RSA, ch chan error) {
 		pc := new(fmp.Fmpz)
 		modp := new(fmp.Fmpz)
 		for {
+			if ctx.Err() != nil {
+				return attacks.Stopped(ctx.Err())
+			}
+
 			pc.SetUint64(p.Next())
 			if modp.Mod(t.Key.N, pc).Equals(ln.BigZero) {
 				primeList = append(primeList, new(fmp.Fmpz).Set(pc))
@@ -44,11 +59,9 @@ func Attack(ts []*keys.RSA, ch chan error) {
 					}
 					if err := t.PackMultiPrime(primeList); err != nil {
 
-						ch <- err
-						return
+						return attacks.Failed("%s failed: %v", name, err)
 					}
-					ch <- nil
-					return
+					return attacks.Solved(t)
 				}
 			}
 		}
@@ -1,9 +1,10 @@
 package notableprimes
 
 import (
-	"fmt"
+	"context"
 	"strings"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -13,6 +14,20 @@ import (
 // name is the name of this attack.
 const name = "notable primes"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "notableprimes",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostInstant,
+		F:          Attack,
+	})
+
+	attacks.SupportedAttacks.RegisterAlias("mersenne", "notableprimes")
+	attacks.SupportedAttacks.RegisterAlias("lucas", "notableprimes")
+	attacks.SupportedAttacks.RegisterAlias("novelty", "notableprimes")
+}
+
 // maxnoveltylen is the maximum number of digits to test for a 31337 prime.
 const maxnoveltylen = 2000
 
@@ -48,11 +63,15 @@ func lucasNumber(n int) *fmp.Fmpz {
 // TODO(kris): Add phi, GF and other notable primes.
 
 // Attack checks the key modulus to see if it factors with any notable primes.
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 	k := ks[0]
 
 	// Test for primes of the form 313333337.
 	for i := 0; i < (maxnoveltylen - 4); i++ {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		p, _ := new(fmp.Fmpz).SetString("3133"+strings.Repeat("3", i)+"7", 10)
 		if p.Cmp(k.Key.N) > 0 {
 			break
@@ -60,13 +79,16 @@ func Attack(ks []*keys.RSA, ch chan error) {
 
 		if new(fmp.Fmpz).Mod(k.Key.N, p).Equals(ln.BigZero) {
 			k.PackGivenP(p)
-			ch <- nil
-			return
+			return attacks.Solved(k)
 		}
 	}
 
 	// Test for primes of the form 133333337.
 	for i := 0; i < (maxnoveltylen - 4); i++ {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		p, _ := new(fmp.Fmpz).SetString("133"+strings.Repeat("3", i)+"7", 10)
 		if p.Cmp(k.Key.N) > 0 {
 			break
@@ -74,13 +96,16 @@ func Attack(ks []*keys.RSA, ch chan error) {
 
 		if new(fmp.Fmpz).Mod(k.Key.N, p).Equals(ln.BigZero) {
 			k.PackGivenP(p)
-			ch <- nil
-			return
+			return attacks.Solved(k)
 		}
 	}
 
 	// Test for primes that are mersenne primes.
 	for _, me := range mersenneExponents {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		// mp = 2^me - 1
 		mp := new(fmp.Fmpz).ExpXI(ln.BigTwo, me).SubZ(ln.BigOne)
 		if mp.Cmp(k.Key.N) > 0 {
@@ -89,13 +114,16 @@ func Attack(ks []*keys.RSA, ch chan error) {
 
 		if new(fmp.Fmpz).Mod(k.Key.N, mp).Equals(ln.BigZero) {
 			k.PackGivenP(mp)
-			ch <- nil
-			return
+			return attacks.Solved(k)
 		}
 	}
 
 	// Test for primes that are Lucas numbers.
 	for _, lp := range lucasPrimes {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		lnum := lucasNumber(lp)
 		if lnum.Cmp(k.Key.N) > 0 {
 			break
@@ -103,10 +131,9 @@ func Attack(ks []*keys.RSA, ch chan error) {
 
 		if new(fmp.Fmpz).Mod(k.Key.N, lnum).Equals(ln.BigZero) {
 			k.PackGivenP(lnum)
-			ch <- nil
-			return
+			return attacks.Solved(k)
 		}
 	}
 
-	ch <- fmt.Errorf("%s was unable to factor the key", name)
+	return attacks.Failed("%s was unable to factor the key", name)
 }
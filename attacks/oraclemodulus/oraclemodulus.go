@@ -2,8 +2,10 @@
 package oraclemodulus
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	fmp "github.com/sourcekris/goflint"
 )
@@ -11,8 +13,19 @@ import (
 // name is the name of this attack.
 const name = "modulus recovery via encryption oracle"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "oraclemodulus",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostInstant,
+		Requires:   attacks.Requirements{OracleCiphertexts: true},
+		F:          Attack,
+	})
+}
+
 // Attack calculates an RSA modulus when we know the ciphertext of 2, 3, 4 and 9.
-func Attack(ts []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ts []*keys.RSA) *attacks.Result {
 	var (
 		e2, e3, e4, e9 *fmp.Fmpz
 		ok             bool
@@ -20,33 +33,27 @@ func Attack(ts []*keys.RSA, ch chan error) {
 
 	t := ts[0]
 	if t.OracleCiphertexts == nil {
-		ch <- fmt.Errorf("%s failed, input requires the ciphertext of integers 2, 3, 4, and 9", name)
-		return
+		return attacks.NotApplicable("%s failed, input requires the ciphertext of integers 2, 3, 4, and 9", name)
 	}
 
 	if len(t.OracleCiphertexts) != 4 {
-		ch <- fmt.Errorf("%s failed, this attack requires the ciphertext of integers 2, 3, 4, and 9", name)
-		return
+		return attacks.NotApplicable("%s failed, this attack requires the ciphertext of integers 2, 3, 4, and 9", name)
 	}
 
 	if e2, ok = t.OracleCiphertexts[2]; !ok {
-		ch <- fmt.Errorf("%s failed, missing the ciphertext of 2", name)
-		return
+		return attacks.NotApplicable("%s failed, missing the ciphertext of 2", name)
 	}
 
 	if e3, ok = t.OracleCiphertexts[3]; !ok {
-		ch <- fmt.Errorf("%s failed, missing the ciphertext of 3", name)
-		return
+		return attacks.NotApplicable("%s failed, missing the ciphertext of 3", name)
 	}
 
 	if e4, ok = t.OracleCiphertexts[4]; !ok {
-		ch <- fmt.Errorf("%s failed, missing the ciphertext of 4", name)
-		return
+		return attacks.NotApplicable("%s failed, missing the ciphertext of 4", name)
 	}
 
 	if e9, ok = t.OracleCiphertexts[9]; !ok {
-		ch <- fmt.Errorf("%s failed, missing the ciphertext of 9", name)
-		return
+		return attacks.NotApplicable("%s failed, missing the ciphertext of 9", name)
 	}
 
 	// n = GCD(e2**2 - e4, e3**2 - e9)
@@ -61,5 +68,5 @@ func Attack(ts []*keys.RSA, ch chan error) {
 		fmt.Printf("n = %v", n)
 	}
 
-	ch <- nil
+	return attacks.Solved(t)
 }
@@ -1,6 +1,7 @@
 package oraclemodulus
 
 import (
+	"context"
 	"testing"
 
 	"github.com/sourcekris/goRsaTool/keys"
@@ -57,9 +58,7 @@ func TestAttack(t *testing.T) {
 			k.OracleCiphertexts[9] = ln.FmpString(tc.e9)
 		}
 
-		ch := make(chan error)
-		go Attack([]*keys.RSA{k}, ch)
-		err := <-ch
+		err := Attack(context.Background(), []*keys.RSA{k}).Err()
 		if err != nil && !tc.wantErr {
 			t.Errorf("Attack() failed: %s expected no error got error: %v", tc.name, err)
 		}
@@ -1,10 +1,14 @@
-// Package partiald implements an attack against the private exponent of RSA when >= 50% of the LSB of d are known.
+// Package partiald implements partial key exposure attacks against the private exponent of RSA. It
+// recovers the key when >= 50% of the LSB of d are known, when the MSB of d are known and e is
+// small, or when the MSB or LSB of the CRT exponent dp are known.
 package partiald
 
 import (
-	"fmt"
+	"context"
 	"log"
+	"math"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 	fmp "github.com/sourcekris/goflint"
@@ -13,25 +17,101 @@ import (
 // name is the name of this attack.
 const name = "partiald"
 
-// Attack implements the Partial D attack.
-func Attack(ts []*keys.RSA, ch chan error) {
+// maxK bounds the number of candidates for k in ed = 1 + k * phi(n) tried for each size of d.
+const maxK = 1 << 12
 
-	// Validate all the parameters are sane.
-	t := ts[0]
-	if t.Key.D != nil {
-		ch <- nil
-		return
+// maxDpEBits bounds the size of e for the dp attacks which try every k < e, 17 bits allows for
+// the usual e = 65537.
+const maxDpEBits = 17
+
+// maxGuessBits is the most unknown bits of p that are guessed instead of found with a lattice.
+const maxGuessBits = 8
+
+// maxDim bounds the dimension of the lattices, roots that need larger lattices are not tried.
+const maxDim = 24
+
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:     "partiald",
+		Timeout:  attacks.DefaultTimeout,
+		Cost:     attacks.CostFast,
+		Requires: attacks.Requirements{PartialD: true},
+		F:        Attack,
+	})
+}
+
+// linearLattice returns the parameters of the smallest lattice with which SmallRoots finds the
+// roots |x0| <= x of a linear polynomial modulo a prime of a balanced n, or false if no lattice up
+// to maxDim does. The lattice of m + t shift polynomials works when its determinant
+// N^(m(m+1)/2) * X^(dim(dim-1)/2) is below p^(m * dim) less the LLL approximation factor.
+func linearLattice(n, x *fmp.Fmpz) (ln.SmallRootsParams, bool) {
+	var (
+		nbits = float64(n.BitLen())
+		xbits = float64(x.BitLen())
+		beta  = float64(n.BitLen()/2-1) / nbits
+	)
+
+	for dim := 2; dim <= maxDim; dim++ {
+		d := float64(dim)
+		for m := 1; m < dim; m++ {
+			det := float64(m*(m+1)/2)*nbits + d*(d-1)/2*xbits
+			if det < d*(beta*float64(m)*nbits-math.Log2(d)/2-(d-1)/4) {
+				return ln.SmallRootsParams{Beta: beta, X: x, M: m, T: dim - m}, true
+			}
+		}
 	}
 
-	if t.DLSB == nil {
-		ch <- fmt.Errorf("%s failed - supply the LSB of 'd' using the -partiald flag or a 'd0 = ' field in the key", name)
-		return
+	return ln.SmallRootsParams{}, false
+}
+
+// coppersmith returns the factor of n that divides f(x0) for a small root x0 of the linear
+// polynomial f modulo a prime of a balanced n found with the lattice sp, or nil if there is none.
+func coppersmith(n *fmp.Fmpz, f *fmp.FmpzPoly, sp ln.SmallRootsParams) *fmp.Fmpz {
+	roots, err := ln.SmallRoots(f, n, sp)
+	if err != nil {
+		return nil
 	}
 
-	if t.Verbose {
-		log.Printf("%s attempt beginning for e = %v", name, t.Key.PublicKey.E)
+	for _, r := range roots {
+		v := new(fmp.Fmpz).Mul(f.GetCoeff(1), r)
+		v.Add(v, f.GetCoeff(0))
+
+		if g := new(fmp.Fmpz).GCD(v, n); g.Cmp(ln.BigOne) > 0 && g.Cmp(n) < 0 {
+			return g
+		}
 	}
 
+	return nil
+}
+
+// guessHigh returns the factor p of n with p = p0 mod 2^bits by trying every value of the bits
+// of a pbits bit p above the known ones, or nil if none divide n.
+func guessHigh(n, p0 *fmp.Fmpz, bits, pbits int) *fmp.Fmpz {
+	var (
+		step = fmp.NewFmpz(1).Lsh(bits)
+		end  = fmp.NewFmpz(1).Lsh(pbits)
+	)
+
+	for p := new(fmp.Fmpz).Set(p0); p.Cmp(end) < 0; p.Add(p, step) {
+		if p.Cmp(ln.BigOne) > 0 && p.Cmp(n) < 0 && new(fmp.Fmpz).Mod(n, p).IsZero() {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// knownBits returns the number of known bits in v which is the size of v unless given.
+func knownBits(v *fmp.Fmpz, given int) int {
+	if given > 0 {
+		return given
+	}
+
+	return v.BitLen()
+}
+
+// lsbD recovers the key given at least half of the LSB of d.
+func lsbD(ctx context.Context, t *keys.RSA) *attacks.Result {
 	if t.Key.PublicKey.E.Cmp(fmp.NewFmpz(65537)) > 0 {
 		log.Printf("%s warning - e > 65537 (%v) this might be a slow attack...", name, t.Key.PublicKey.E)
 	}
@@ -57,6 +137,10 @@ func Attack(ts []*keys.RSA, ch chan error) {
 
 		// do we have enough bits in our approximation to try?
 		for i := 0; fmp.NewFmpz(int64(i)).BitLen() <= abits; i++ {
+			if ctx.Err() != nil {
+				return attacks.Stopped(ctx.Err())
+			}
+
 			// brute force uncertain bits.
 			bf := fmp.NewFmpz(int64(i)).Lsh(d0bits)
 			d = d.Xor(d, bf)
@@ -64,11 +148,288 @@ func Attack(ts []*keys.RSA, ch chan error) {
 			m := new(fmp.Fmpz).Pow(new(fmp.Fmpz).Pow(ln.BigTwo, t.Key.PublicKey.E, t.Key.N), d, t.Key.N)
 			if m.Cmp(ln.BigTwo) == 0 {
 				t.PackGivenP(ln.FindPGivenD(d, t.Key.PublicKey.E, t.Key.N))
-				ch <- nil
-				return
+				return attacks.Solved(t)
 			}
 		}
 	}
 
-	ch <- fmt.Errorf("%s failed to recover the private key", name)
+	return attacks.Failed("%s failed to recover the private key from the LSB of d", name)
+}
+
+// msbD recovers the key given the MSB of d for a small e. The MSB of d fix k in ed = 1 + k * phi(n)
+// and give an approximation of p + q = n + 1 - phi(n), when it is within about N^(1/4) of p + q
+// the approximation of p that follows is refined with Coppersmith's method.
+func msbD(ctx context.Context, t *keys.RSA) *attacks.Result {
+	var (
+		n     = t.Key.N
+		e     = t.Key.PublicKey.E
+		known = knownBits(t.DHigh, t.DKnownBits)
+	)
+
+	// d = (1 + k * phi(n)) / e for 0 < k < e so it is between n / e and n in size.
+	for dbits := n.BitLen(); dbits > known && dbits >= n.BitLen()-e.BitLen(); dbits-- {
+		var (
+			u    = dbits - known
+			dMin = new(fmp.Fmpz).Set(t.DHigh).Lsh(u)
+			dMax = new(fmp.Fmpz).Add(dMin, fmp.NewFmpz(1).Lsh(u))
+			kMin = new(fmp.Fmpz).Mul(e, dMin)
+			kMax = new(fmp.Fmpz).Mul(e, dMax)
+		)
+
+		kMin.Div(kMin, n)
+		kMax.Div(kMax, n).AddI(1)
+		if kMin.IsZero() {
+			kMin.Set(ln.BigOne)
+		}
+
+		if kMax.Cmp(e) >= 0 {
+			kMax.Sub(e, ln.BigOne)
+		}
+
+		if new(fmp.Fmpz).Sub(kMax, kMin).Cmp(fmp.NewFmpz(maxK)) > 0 {
+			continue
+		}
+
+		if t.Verbose {
+			log.Printf("%s: trying %d unknown low bits of a %d bit d with k in [%v, %v]", name, u, dbits, kMin, kMax)
+		}
+
+		// Take the middle of the range of d to halve the error.
+		d := new(fmp.Fmpz).Add(dMin, fmp.NewFmpz(1).Lsh(u-1))
+		ed := new(fmp.Fmpz).Mul(e, d)
+		ed.Sub(ed, ln.BigOne)
+
+		for k := new(fmp.Fmpz).Set(kMin); k.Cmp(kMax) <= 0; k.AddI(1) {
+			if ctx.Err() != nil {
+				return attacks.Stopped(ctx.Err())
+			}
+
+			// s ~ p + q = n + 1 - (ed - 1) / k with an error of about e * 2^(u-1) / k.
+			s := new(fmp.Fmpz).Div(ed, k)
+			s.Sub(n, s).AddI(1)
+
+			disc := new(fmp.Fmpz).Mul(s, s)
+			disc.Sub(disc, new(fmp.Fmpz).Mul(n, ln.BigFour))
+			if s.Sign() <= 0 || disc.Sign() < 0 {
+				continue
+			}
+
+			// p ~ (s + sqrt(s^2 - 4n)) / 2, allow for the error in s growing as p and q get closer.
+			p := new(fmp.Fmpz).Sqrt(disc)
+			p.Add(p, s).Div(p, ln.BigTwo)
+
+			x := new(fmp.Fmpz).Mul(e, fmp.NewFmpz(1).Lsh(u+2))
+			x.Div(x, k)
+
+			sp, ok := linearLattice(n, x)
+			if !ok {
+				continue
+			}
+
+			f := fmp.NewFmpzPoly().SetCoeff(0, p).SetCoeffUI(1, 1)
+			if g := coppersmith(n, f, sp); g != nil {
+				t.PackGivenP(g)
+				return attacks.Solved(t)
+			}
+		}
+	}
+
+	return attacks.Failed("%s failed to recover the private key from the MSB of d", name)
+}
+
+// lsbDp recovers the key given the LSB of dp. Since e * dp = 1 + k * (p - 1) for 0 < k < e each
+// k gives the LSB of p and the rest of p is recovered with Coppersmith's method.
+func lsbDp(ctx context.Context, t *keys.RSA) *attacks.Result {
+	var (
+		n     = t.Key.N
+		e     = t.Key.PublicKey.E
+		known = knownBits(t.DpLow, t.DKnownBits)
+		pbits = (n.BitLen() + 1) / 2
+	)
+
+	if e.BitLen() > maxDpEBits {
+		return attacks.NotApplicable("%s attack on the LSB of dp needs e below 2^%d", name, maxDpEBits)
+	}
+
+	if t.Verbose {
+		log.Printf("%s: trying %d known low bits of dp", name, known)
+	}
+
+	// The lattice only depends on how many powers of 2 k has so it is worked out once for each.
+	lattices := make(map[int]*ln.SmallRootsParams)
+
+	for k := fmp.NewFmpz(1); k.Cmp(e) < 0; k.AddI(1) {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
+		// k * p = e * dp - 1 + k mod 2^known, divide out the powers of 2 in k.
+		var (
+			a    = new(fmp.Fmpz).Mul(e, t.DpLow)
+			kOdd = new(fmp.Fmpz).Set(k)
+			bits = known
+		)
+
+		a.Sub(a, ln.BigOne).Add(a, k)
+		for kOdd.TstBit(0) == 0 {
+			if a.TstBit(0) != 0 {
+				break
+			}
+
+			kOdd.Rsh(1)
+			a.Rsh(1)
+			bits--
+		}
+
+		// p is odd so k * p and k have the same powers of 2.
+		if kOdd.TstBit(0) == 0 || a.TstBit(0) == 0 {
+			continue
+		}
+
+		mod := fmp.NewFmpz(1).Lsh(bits)
+		p0 := new(fmp.Fmpz).ModInverse(kOdd, mod)
+		p0.Mul(p0, a).ModZ(mod)
+
+		// A few unknown bits of p are quicker to try than to build a lattice for.
+		if bits+maxGuessBits >= pbits {
+			if g := guessHigh(n, p0, bits, pbits); g != nil {
+				t.PackGivenP(g)
+				return attacks.Solved(t)
+			}
+
+			continue
+		}
+
+		sp, ok := lattices[bits]
+		if !ok {
+			if l, ok := linearLattice(n, fmp.NewFmpz(1).Lsh(pbits-bits)); ok {
+				sp = &l
+			}
+			lattices[bits] = sp
+		}
+
+		if sp == nil {
+			continue
+		}
+
+		// f(x) = 2^bits * x + p0
+		f := fmp.NewFmpzPoly().SetCoeff(0, p0).SetCoeff(1, mod)
+		if g := coppersmith(n, f, *sp); g != nil {
+			t.PackGivenP(g)
+			return attacks.Solved(t)
+		}
+	}
+
+	return attacks.Failed("%s failed to recover the private key from the LSB of dp", name)
+}
+
+// msbDp recovers the key given the MSB of dp. Since e * dp = 1 + k * (p - 1) for 0 < k < e the
+// unknown LSB x0 of dp are a small root of e * (dp_high * 2^u + x) - 1 + k modulo p. For primes
+// of the same size n / 2^pbits <= p < 2^pbits which with the known bits of dp bounds k to a range
+// far smaller than e for each size of dp.
+func msbDp(ctx context.Context, t *keys.RSA) *attacks.Result {
+	var (
+		n     = t.Key.N
+		e     = t.Key.PublicKey.E
+		known = knownBits(t.DpHigh, t.DKnownBits)
+		pMax  = fmp.NewFmpz(1).Lsh((n.BitLen() + 1) / 2)
+		pMin  = new(fmp.Fmpz).Div(n, pMax)
+		eMax  = new(fmp.Fmpz).Sub(e, ln.BigOne)
+	)
+
+	if e.BitLen() > maxDpEBits {
+		return attacks.NotApplicable("%s attack on the MSB of dp needs e below 2^%d", name, maxDpEBits)
+	}
+
+	// dp < p so it is at most half the size of n, a smaller dp gives a smaller range of k.
+	for dpbits := (n.BitLen() + 1) / 2; dpbits > known; dpbits-- {
+		u := dpbits - known
+
+		// Too few known bits for any lattice to reach the rest, try a smaller dp.
+		sp, ok := linearLattice(n, fmp.NewFmpz(1).Lsh(u))
+		if !ok {
+			continue
+		}
+
+		// k = (e * dp - 1) / (p - 1) for dp_high * 2^u <= dp < (dp_high + 1) * 2^u.
+		var (
+			c    = new(fmp.Fmpz).Set(t.DpHigh).Lsh(u)
+			kMin = new(fmp.Fmpz).Mul(e, c)
+			kMax = new(fmp.Fmpz).Add(c, fmp.NewFmpz(1).Lsh(u))
+		)
+
+		kMin.Sub(kMin, ln.BigOne).Div(kMin, new(fmp.Fmpz).Sub(pMax, ln.BigOne))
+		kMax.Mul(kMax, e).Div(kMax, new(fmp.Fmpz).Sub(pMin, ln.BigOne)).AddI(1)
+		if kMin.IsZero() {
+			kMin.Set(ln.BigOne)
+		}
+
+		if kMax.Cmp(eMax) > 0 {
+			kMax.Set(eMax)
+		}
+
+		if kMin.Cmp(kMax) > 0 {
+			continue
+		}
+
+		if t.Verbose {
+			log.Printf("%s: trying %d unknown low bits of a %d bit dp with k in [%v, %v]", name, u, dpbits, kMin, kMax)
+		}
+
+		c.Mul(c, e).Sub(c, ln.BigOne)
+
+		for k := kMin; k.Cmp(kMax) <= 0; k.AddI(1) {
+			if ctx.Err() != nil {
+				return attacks.Stopped(ctx.Err())
+			}
+
+			// f(x) = e * x + e * dp_high * 2^u - 1 + k
+			f := fmp.NewFmpzPoly().SetCoeff(0, new(fmp.Fmpz).Add(c, k)).SetCoeff(1, e)
+			if g := coppersmith(n, f, sp); g != nil {
+				t.PackGivenP(g)
+				return attacks.Solved(t)
+			}
+		}
+	}
+
+	return attacks.Failed("%s failed to recover the private key from the MSB of dp", name)
+}
+
+// Attack implements the Partial D attack.
+func Attack(ctx context.Context, ts []*keys.RSA) *attacks.Result {
+
+	// Validate all the parameters are sane.
+	t := ts[0]
+	if t.Key.D != nil {
+		return attacks.Solved(t)
+	}
+
+	if t.DLSB == nil && t.DHigh == nil && t.DpHigh == nil && t.DpLow == nil {
+		return attacks.NotApplicable("%s failed - supply the LSB of 'd' using the -d0 flag or a 'd0 = ' field in the key, or a 'd_high = ', 'dp_high = ' or 'dp_low = ' field in the key", name)
+	}
+
+	if t.Verbose {
+		log.Printf("%s attempt beginning for e = %v", name, t.Key.PublicKey.E)
+	}
+
+	var r *attacks.Result
+	for _, a := range []struct {
+		known bool
+		f     func(context.Context, *keys.RSA) *attacks.Result
+	}{
+		{t.DLSB != nil, lsbD},
+		{t.DHigh != nil, msbD},
+		{t.DpLow != nil, lsbDp},
+		{t.DpHigh != nil, msbDp},
+	} {
+		if !a.known {
+			continue
+		}
+
+		if r = a.f(ctx, t); r.Err() == nil || ctx.Err() != nil {
+			return r
+		}
+	}
+
+	return r
 }
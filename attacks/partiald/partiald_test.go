@@ -1,8 +1,10 @@
 package partiald
 
 import (
+	"context"
 	"testing"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -11,11 +13,15 @@ import (
 
 func TestAttack(t *testing.T) {
 	tt := []struct {
-		name string
-		n    *fmp.Fmpz
-		e    *fmp.Fmpz
-		d0   *fmp.Fmpz
-		want *fmp.Fmpz
+		name   string
+		n      *fmp.Fmpz
+		e      *fmp.Fmpz
+		d0     *fmp.Fmpz
+		dHigh  *fmp.Fmpz
+		dpHigh *fmp.Fmpz
+		dpLow  *fmp.Fmpz
+		dBits  int
+		want   *fmp.Fmpz
 	}{
 		{
 			name: "vulnerable key expected to recover d",
@@ -24,14 +30,60 @@ func TestAttack(t *testing.T) {
 			d0:   ln.FmpString("16236907796808096199887378190370937897641878399225383583505805325470329321872928979418905097533867340283374729663939981270801679402647440066523530248353305"),
 			want: ln.FmpString("45670619466005375897058785557902805270736267703908936346209338347475675522866834506191956944839000243844683203420288252550775505662516013027069778653639554961706933147715435907948764515279991518139128492527575917958505935582118594306121265491344326720446163086737712339775332722701484222617246376883923744281"),
 		},
+		{
+			name:  "msb of d",
+			n:     ln.FmpString("67779169991156313953976086119991464718779730349607858782745496509542441091211"),
+			e:     fmp.NewFmpz(17),
+			dHigh: ln.FmpString("290093157635403853329138596275072609518868171998384552735917567632"),
+			want:  ln.FmpString("19935049997398915868816495917644548446542673498693833901317652440503890089233"),
+		},
+		{
+			name:   "msb of dp",
+			n:      ln.FmpString("67779169991156313953976086119991464718779730349607858782745496509542441091211"),
+			e:      fmp.NewFmpz(17),
+			dpHigh: ln.FmpString("143723909092560757825348107"),
+			want:   ln.FmpString("19935049997398915868816495917644548446542673498693833901317652440503890089233"),
+		},
+		{
+			name:  "lsb of dp",
+			n:     ln.FmpString("67779169991156313953976086119991464718779730349607858782745496509542441091211"),
+			e:     fmp.NewFmpz(17),
+			dpLow: ln.FmpString("1203562870703099424188865"),
+			want:  ln.FmpString("19935049997398915868816495917644548446542673498693833901317652440503890089233"),
+		},
+		{
+			name:   "msb of dp with e = 65537 and k = 39366",
+			n:      ln.FmpString("84340454924550874645264340568322826689304180033374376090084756811290150775509"),
+			e:      fmp.NewFmpz(65537),
+			dpHigh: ln.FmpString("2968680940217017811668216747"),
+			want:   ln.FmpString("42002285240818033273908441757616619885962432801242111677976070045752322246057"),
+		},
+		{
+			name:  "lsb of dp with e = 65537",
+			n:     ln.FmpString("57150419750376926550042512224374114604781132674383616100297985997793734183657"),
+			e:     fmp.NewFmpz(65537),
+			dpLow: ln.FmpString("623361722017379729643823361"),
+			dBits: 90,
+			want:  ln.FmpString("16078542492904004939647585949814759984038780719827268733012851540548476741633"),
+		},
+		{
+			name:  "every bit of dp with e = 65537 and k = 62310",
+			n:     ln.FmpString("83579735834537981677881189166801211206856750494685634763187664396388910824031"),
+			e:     fmp.NewFmpz(65537),
+			dpLow: ln.FmpString("254956731465526787495921058726735399053"),
+			want:  ln.FmpString("50257262764048900345788514925695441219722607819804047674013417532683132814721"),
+		},
 	}
 
 	for _, tc := range tt {
 		k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: tc.n, E: tc.e}), nil, nil, "", false)
-		k.DLSB = tc.d0.Bytes()
-		ch := make(chan error)
-		go Attack([]*keys.RSA{k}, ch)
-		err := <-ch
+		if tc.d0 != nil {
+			k.DLSB = tc.d0.Bytes()
+		}
+
+		k.DHigh, k.DpHigh, k.DpLow = tc.dHigh, tc.dpHigh, tc.dpLow
+		k.DKnownBits = tc.dBits
+		err := Attack(context.Background(), []*keys.RSA{k}).Err()
 		if err != nil {
 			t.Errorf("Attack() failed: %s expected no error got error: %v", tc.name, err)
 		}
@@ -43,3 +95,15 @@ func TestAttack(t *testing.T) {
 
 	}
 }
+
+func TestAttackDpLargeE(t *testing.T) {
+	k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{
+		N: ln.FmpString("67779169991156313953976086119991464718779730349607858782745496509542441091211"),
+		E: fmp.NewFmpz(1<<17 + 1),
+	}), nil, nil, "", false)
+	k.DpHigh = ln.FmpString("143723909092560757825348107")
+
+	if res := Attack(context.Background(), []*keys.RSA{k}); res.Status != attacks.StatusNotApplicable {
+		t.Errorf("Attack() want a not applicable result for a large e got %v", res)
+	}
+}
@@ -0,0 +1,153 @@
+// Package partialp implements Coppersmith's factorization of N when about half of the most or
+// least significant bits of p are known. The unknown bits x0 are a small root of
+// p_high * 2^u + x or of 2^k * x + p_low modulo p, which is a divisor of N of known size.
+package partialp
+
+import (
+	"context"
+	"log"
+
+	"github.com/sourcekris/goRsaTool/attacks"
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// name is the name of this attack.
+const name = "partial p"
+
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "partialp",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostFast,
+		Requires:   attacks.Requirements{PartialP: true},
+		F:          Attack,
+	})
+}
+
+// factor returns p given f(x0) = p for the small root x0 < 2^unknown of f modulo a pbits sized
+// divisor of n, or nil if there is none.
+func factor(n *fmp.Fmpz, f *fmp.FmpzPoly, pbits, unknown int) *fmp.Fmpz {
+	var (
+		x    = fmp.NewFmpz(1).Lsh(unknown)
+		beta = float64(pbits-1) / float64(n.BitLen())
+	)
+
+	// The method reaches roots up to N^(beta^2), beyond that the lattice is built for nothing.
+	if float64(unknown) >= beta*beta*float64(n.BitLen()) {
+		return nil
+	}
+
+	roots, err := ln.SmallRoots(f, n, ln.SmallRootsParams{
+		Beta:    beta,
+		Epsilon: ln.SmallRootsEpsilon(n, x, beta, 1),
+		X:       x,
+	})
+	if err != nil {
+		return nil
+	}
+
+	for _, r := range roots {
+		p := new(fmp.Fmpz).Mul(f.GetCoeff(1), r)
+		p.Add(p, f.GetCoeff(0))
+		if p.Cmp(ln.BigOne) > 0 && p.Cmp(n) < 0 && new(fmp.Fmpz).Mod(n, p).IsZero() {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// highBits returns the known high bits of p in v and how many there are. v is usually p shifted
+// right by the unknown bits but may be p with them zeroed, which is told apart by v being about
+// as large as p. Then the trailing zeros, or the bits past given when it is set, are stripped.
+func highBits(v *fmp.Fmpz, given, pbits int) (*fmp.Fmpz, int) {
+	if v.BitLen() < pbits-1 {
+		if given > 0 {
+			return v, given
+		}
+
+		return v, v.BitLen()
+	}
+
+	shift := 0
+	if given > 0 && given < v.BitLen() {
+		shift = v.BitLen() - given
+	} else {
+		for shift < v.BitLen() && v.TstBit(shift) == 0 {
+			shift++
+		}
+	}
+
+	return new(fmp.Fmpz).Set(v).Rsh(shift), v.BitLen() - shift
+}
+
+// Attack implements the partial p attack.
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
+	k := ks[0]
+	if k.Key.D != nil {
+		return attacks.Solved(k)
+	}
+
+	if k.PHigh == nil && k.PLow == nil {
+		return attacks.NotApplicable("%s failed - supply the MSBs or LSBs of p using a 'p_high = ' or 'p_low = ' field in the key", name)
+	}
+
+	n := k.Key.N
+
+	var (
+		pHigh *fmp.Fmpz
+		known int
+	)
+
+	if k.PHigh != nil {
+		pHigh, known = highBits(k.PHigh, k.PKnownBits, n.BitLen()/2)
+	}
+
+	// p is expected to be about half the size of n but may be a bit either way.
+	for pbits := n.BitLen() / 2; pbits <= n.BitLen()/2+1; pbits++ {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
+		if pHigh != nil {
+			if unknown := pbits - known; unknown > 0 {
+				if k.Verbose {
+					log.Printf("%s: trying %d unknown low bits of a %d bit p", name, unknown, pbits)
+				}
+
+				// f(x) = p_high * 2^u + x
+				f := fmp.NewFmpzPoly().SetCoeff(0, new(fmp.Fmpz).Set(pHigh).Lsh(unknown)).SetCoeffUI(1, 1)
+				if p := factor(n, f, pbits, unknown); p != nil {
+					k.PackGivenP(p)
+					return attacks.Solved(k)
+				}
+			}
+		}
+
+		if k.PLow != nil {
+			known := k.PLow.BitLen()
+			if k.PKnownBits > 0 {
+				known = k.PKnownBits
+			}
+
+			if unknown := pbits - known; unknown > 0 {
+				if k.Verbose {
+					log.Printf("%s: trying %d unknown high bits of a %d bit p", name, unknown, pbits)
+				}
+
+				// f(x) = 2^k * x + p_low
+				f := fmp.NewFmpzPoly().SetCoeff(0, k.PLow).SetCoeff(1, fmp.NewFmpz(1).Lsh(known))
+				if p := factor(n, f, pbits, unknown); p != nil {
+					k.PackGivenP(p)
+					return attacks.Solved(k)
+				}
+			}
+		}
+	}
+
+	return attacks.Failed("%s failed to recover p from the known bits", name)
+}
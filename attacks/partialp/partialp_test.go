@@ -0,0 +1,78 @@
+package partialp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+	"github.com/sourcekris/goRsaTool/utils"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+func TestAttack(t *testing.T) {
+	var (
+		n = ln.FmpString("67779169991156313953976086119991464718779730349607858782745496509542441091211")
+		p = ln.FmpString("206649527463365580008337435483770722577")
+		q = ln.FmpString("327990926585457938257140776345444065243")
+	)
+
+	tt := []struct {
+		name      string
+		pHigh     *fmp.Fmpz
+		pLow      *fmp.Fmpz
+		knownBits int
+		wantP     *fmp.Fmpz
+		wantErr   bool
+	}{
+		{
+			name:  "80 high bits of the smaller prime",
+			pHigh: ln.FmpString("734166602936758678795347"),
+			wantP: p,
+		},
+		{
+			name:  "80 high bits of the larger prime",
+			pHigh: ln.FmpString("1165257851402607294691693"),
+			wantP: q,
+		},
+		{
+			name:  "80 high bits of p with the low bits zeroed",
+			pHigh: ln.FmpString("206649527463365580008337181374658117632"),
+			wantP: p,
+		},
+		{
+			name:      "80 high bits of p with the low bits zeroed and a known bit count",
+			pHigh:     ln.FmpString("206649527463365580008337181374658117632"),
+			knownBits: 80,
+			wantP:     p,
+		},
+		{
+			name:      "80 low bits",
+			pLow:      ln.FmpString("550952675860905329957137"),
+			knownBits: 80,
+			wantP:     p,
+		},
+		{
+			name:    "too few high bits",
+			pHigh:   ln.FmpString("2608283732"),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: n, E: fmp.NewFmpz(65537)}), nil, nil, "", false)
+		k.PHigh = tc.pHigh
+		k.PLow = tc.pLow
+		k.PKnownBits = tc.knownBits
+
+		err := Attack(context.Background(), []*keys.RSA{k}).Err()
+		if (err != nil) != tc.wantErr {
+			t.Errorf("Attack() %s: want error %v got %v", tc.name, tc.wantErr, err)
+		}
+
+		if !tc.wantErr && !utils.FoundP(tc.wantP, k.Key.Primes) {
+			t.Errorf("Attack() %s: expected primes not found - got %v wanted %v", tc.name, k.Key.Primes, tc.wantP)
+		}
+	}
+}
@@ -2,10 +2,11 @@ package pastctfprimes
 
 import (
 	"bufio"
-	"fmt"
+	"context"
 	"os"
 	"strings"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -15,20 +16,31 @@ import (
 // name is the name of this attack.
 const name = "past ctf primes"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "pastctf",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostInstant,
+		F:          Attack,
+	})
+
+	attacks.SupportedAttacks.RegisterAlias("pastprimes", "pastctf")
+	attacks.SupportedAttacks.RegisterAlias("pastctfprimes", "pastctf")
+}
+
 // Attack implements the PastCTFPrimes attack.
-func Attack(ts []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ts []*keys.RSA) *attacks.Result {
 	t := ts[0]
 	if t.Key.D != nil {
-		ch <- nil
-		return
+		return attacks.Solved(t)
 	}
 
 	var primes []fmp.Fmpz
 
 	file, err := os.Open(t.PastPrimesFile)
 	if err != nil {
-		ch <- err
-		return
+		return attacks.Failed("%s failed: %v", name, err)
 	}
 
 	defer file.Close()
@@ -46,13 +58,16 @@ func Attack(ts []*keys.RSA, ch chan error) {
 	modp := new(fmp.Fmpz)
 
 	for _, p := range primes {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		modp = modp.Mod(t.Key.N, &p)
 		if modp.Equals(ln.BigZero) {
 			t.PackGivenP(&p)
-			ch <- nil
-			return
+			return attacks.Solved(t)
 		}
 	}
 
-	ch <- fmt.Errorf("%s attack failed", name)
+	return attacks.Failed("%s attack failed", name)
 }
@@ -0,0 +1,155 @@
+package attacks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sourcekris/goRsaTool/keys"
+)
+
+// Cost is a rough measure of how long an attack takes to give an answer. The planner runs
+// cheaper attacks first in unnatended mode.
+type Cost int
+
+const (
+	// CostInstant attacks are simple checks that finish in well under a second.
+	CostInstant Cost = iota
+	// CostFast attacks only succeed against keys with special structure but find out quickly.
+	CostFast
+	// CostSlow attacks are general purpose factoring algorithms that run until they time out.
+	CostSlow
+	// CostNetwork attacks ask an external service and are only tried when everything else failed.
+	CostNetwork
+)
+
+func (c Cost) String() string {
+	switch c {
+	case CostInstant:
+		return "instant"
+	case CostFast:
+		return "fast"
+	case CostSlow:
+		return "slow"
+	default:
+		return "network"
+	}
+}
+
+// stageBudget is the longest each attack may run for in the stage for that cost. Attacks without a
+// budget and attacks given a timeout by the user get their full timeout.
+var stageBudget = map[Cost]time.Duration{
+	CostInstant: 10 * time.Second,
+	CostFast:    60 * time.Second,
+}
+
+const (
+	// smallModulusBits is the modulus size below which general factoring is cheap enough to run
+	// alongside the fast attacks.
+	smallModulusBits = 256
+	// smallExponentBits is the size of the largest exponent considered small, 65537.
+	smallExponentBits = 17
+)
+
+// Step is a single attack scheduled by the planner and the time it is allowed to run for.
+type Step struct {
+	Attack  *Attack
+	Cost    Cost
+	Timeout time.Duration
+}
+
+// profile holds the properties of the keys that affect which attacks are likely to succeed.
+type profile struct {
+	bits   int
+	nkeys  int
+	smallE bool
+	largeE bool
+}
+
+func newProfile(ks []*keys.RSA) profile {
+	p := profile{nkeys: len(ks)}
+	if len(ks) == 0 {
+		return p
+	}
+
+	k := ks[0].Key
+	if k.N != nil {
+		p.bits = k.N.BitLen()
+	}
+
+	if e := k.PublicKey.E; e != nil {
+		p.smallE = e.BitLen() <= smallExponentBits
+		// A public exponent of similar size to the modulus suggests the private exponent is small.
+		p.largeE = p.bits > 0 && e.BitLen() > p.bits/2
+	}
+
+	return p
+}
+
+// cost returns the cost of running at against keys with profile p.
+func (p profile) cost(at *Attack) Cost {
+	c := at.Cost
+
+	switch {
+	case at.LargeE && p.largeE:
+		c = CostInstant
+	case at.LargeE && p.smallE:
+		// A small e means a small d is very unlikely so leave these until the end.
+		c = CostSlow
+	case c == CostSlow && p.bits > 0 && p.bits <= smallModulusBits:
+		c = CostFast
+	}
+
+	// Several keys given together usually means a multi key attack is the intended solution.
+	if at.SupportsMulti && p.nkeys > 1 && c > CostInstant && c < CostNetwork {
+		c--
+	}
+
+	return c
+}
+
+// Plan returns the applicable unnatended attacks for ks grouped into stages of increasing cost
+// along with a not applicable Result for each attack that was left out.
+func (a *Attacks) Plan(ks []*keys.RSA) ([][]*Step, []*Result) {
+	if a == nil {
+		return nil, nil
+	}
+
+	var (
+		p       = newProfile(ks)
+		stages  = make([][]*Step, CostNetwork+1)
+		skipped []*Result
+	)
+
+	for _, at := range a.Supported {
+		if !at.Unnatended {
+			continue
+		}
+
+		err := at.Applicable(ks)
+		if err == nil {
+			err = at.UnnatendedRequires.Check(ks, at.SupportsMulti)
+		}
+
+		if err != nil {
+			skipped = append(skipped, &Result{Name: at.Name, Status: StatusNotApplicable, Message: fmt.Sprintf("%s: %v", at.Name, err)})
+			continue
+		}
+
+		c := p.cost(at)
+		timeout := at.Timeout
+		if b, ok := stageBudget[c]; ok && b < timeout && !at.userTimeout {
+			timeout = b
+		}
+
+		stages[c] = append(stages[c], &Step{Attack: at, Cost: c, Timeout: timeout})
+	}
+
+	var plan [][]*Step
+	for _, s := range stages {
+		if len(s) > 0 {
+			plan = append(plan, s)
+		}
+	}
+
+	return plan, skipped
+}
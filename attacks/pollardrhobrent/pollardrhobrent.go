@@ -1,6 +1,10 @@
 package pollardrhobrent
 
 import (
+	"context"
+	"time"
+
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -10,9 +14,19 @@ import (
 // name is the name of this attack.
 const name = "brents variant of pollard rho factorization"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "pollardrhobrent",
+		Unnatended: true,
+		Timeout:    5 * time.Minute,
+		Cost:       attacks.CostSlow,
+		F:          Attack,
+	})
+}
+
 // Attack conducts Pollard's Rho method Richard Brent variant for factoring
 // large composites. See: https://maths-people.anu.edu.au/~brent/pd/rpb051i.pdf
-func Attack(kks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, kks []*keys.RSA) *attacks.Result {
 	kk := kks[0]
 
 	var (
@@ -23,6 +37,10 @@ func Attack(kks []*keys.RSA, ch chan error) {
 	)
 
 	for g.Equals(kk.Key.N) {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		y := ln.GetRand(state, kk.Key.N)
 		c := ln.GetRand(state, kk.Key.N)
 		m := ln.GetRand(state, kk.Key.N)
@@ -31,6 +49,10 @@ func Attack(kks []*keys.RSA, ch chan error) {
 		g.SetInt64(1)
 
 		for g.Equals(ln.BigOne) {
+			if ctx.Err() != nil {
+				return attacks.Stopped(ctx.Err())
+			}
+
 			x.Set(y)
 			k := fmp.NewFmpz(0)
 			counter := fmp.NewFmpz(0)
@@ -39,6 +61,10 @@ func Attack(kks []*keys.RSA, ch chan error) {
 				counter.Add(counter, ln.BigOne)
 			}
 			for k.Cmp(r) < 0 && g.Equals(ln.BigOne) {
+				if ctx.Err() != nil {
+					return attacks.Stopped(ctx.Err())
+				}
+
 				ys = new(fmp.Fmpz).Set(y)
 				min := ln.FmpzMin(m, new(fmp.Fmpz).Sub(r, k))
 				counter.Set(ln.BigZero)
@@ -55,6 +81,10 @@ func Attack(kks []*keys.RSA, ch chan error) {
 
 		if g.Equals(kk.Key.N) {
 			for {
+				if ctx.Err() != nil {
+					return attacks.Stopped(ctx.Err())
+				}
+
 				ys.Mul(ys, ys).Add(ys, c).Mod(ys, kk.Key.N)
 				g = ln.FindGcd(new(fmp.Fmpz).Abs(new(fmp.Fmpz).Sub(x, ys)), kk.Key.N)
 				if g.Cmp(ln.BigOne) > 0 {
@@ -65,5 +95,5 @@ func Attack(kks []*keys.RSA, ch chan error) {
 	}
 
 	kk.PackGivenP(g)
-	ch <- nil
+	return attacks.Solved(kk)
 }
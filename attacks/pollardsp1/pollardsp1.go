@@ -1,8 +1,9 @@
 package pollardsp1
 
 import (
-	"fmt"
+	"context"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -12,6 +13,16 @@ import (
 // name is the name of this attack.
 const name = "pollard's p-1 factorization"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "pollardsp1",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostSlow,
+		F:          Attack,
+	})
+}
+
 const (
 	startA = 7
 	startB = 65536
@@ -22,7 +33,7 @@ var primeSieve = ln.SieveOfEratosthenesFmp
 
 // Attack implements the Pollards P minus 1 factorization technique. This technique was used in
 // BostonKeyParty 2017 challenge "RSA Buffet".
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 	k := ks[0]
 	// Solution based on https://github.com/HackThisSite/ Python solution.
 	// Solution is derived from the work here: https://math.berkeley.edu/~sagrawal/su14_math55/notes_pollard.pdf
@@ -34,6 +45,10 @@ func Attack(ks []*keys.RSA, ch chan error) {
 	b := fmp.NewFmpz(int64(startB))
 
 	for _, x := range primes {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		tmp := fmp.NewFmpz(int64(1))
 		for tmp.Cmp(b) < 0 {
 			a.Exp(a, x, n)
@@ -42,16 +57,14 @@ func Attack(ks []*keys.RSA, ch chan error) {
 	}
 	d := ln.FindGcd(a.Sub(a, ln.BigOne), n)
 	if d.Equals(n) {
-		ch <- fmt.Errorf("%s failed - unable to factor key with a of: %d (try another a?)", name, startA)
-		return
+		return attacks.Failed("%s failed - unable to factor key with a of: %d (try another a?)", name, startA)
 	}
 
 	if d.Cmp(ln.BigOne) > 0 {
 		// Success
 		k.PackGivenP(d)
-		ch <- nil
-		return
+		return attacks.Solved(k)
 	}
 
-	ch <- fmt.Errorf("%s attack failed - unable to factor key with b of: %d", name, startB)
+	return attacks.Failed("%s attack failed - unable to factor key with b of: %d", name, startB)
 }
@@ -1,6 +1,10 @@
 package pollardsrho
 
 import (
+	"context"
+	"time"
+
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -10,8 +14,18 @@ import (
 // name is the name of this attack.
 const name = "pollard's rho"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "pollardsrho",
+		Unnatended: true,
+		Timeout:    5 * time.Minute,
+		Cost:       attacks.CostSlow,
+		F:          Attack,
+	})
+}
+
 // Attack uses Pollard's Rho factorization method.
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 	k := ks[0]
 	var (
 		state = new(fmp.FlintRandT)
@@ -22,6 +36,10 @@ func Attack(ks []*keys.RSA, ch chan error) {
 	)
 
 	for g.Equals(ln.BigOne) {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		x.Mul(x, x).Mod(x, k.Key.N).Add(x, c).Mod(x, k.Key.N)
 		y.Mul(y, y).Mod(y, k.Key.N).Add(y, c).Mod(y, k.Key.N)
 		y.Mul(y, y).Mod(y, k.Key.N).Add(y, c).Mod(y, k.Key.N)
@@ -30,5 +48,5 @@ func Attack(ks []*keys.RSA, ch chan error) {
 
 	k.PackGivenP(g)
 
-	ch <- nil
+	return attacks.Solved(k)
 }
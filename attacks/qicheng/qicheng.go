@@ -1,8 +1,9 @@
 package qicheng
 
 import (
-	"fmt"
+	"context"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 	fmp "github.com/sourcekris/goflint"
@@ -11,6 +12,16 @@ import (
 // name is the name of this attack.
 const name = "qicheng factorization"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "qicheng",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostSlow,
+		F:          Attack,
+	})
+}
+
 var (
 	state = new(fmp.FlintRandT)
 	nTwo  = fmp.NewFmpz(-2)
@@ -183,7 +194,7 @@ func (e *Curve) Poly(n, x *fmp.Fmpz, r *Integers) *fmp.Fmpz {
 }
 
 // Attack implements the Qi Cheng attack.
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 	k := ks[0]
 	js := []*fmp.Fmpz{
 		fmp.NewFmpz(0),
@@ -198,6 +209,10 @@ func Attack(ks []*keys.RSA, ch chan error) {
 
 	for i := 0; i < attempts; i++ {
 		for _, j := range js {
+			if ctx.Err() != nil {
+				return attacks.Stopped(ctx.Err())
+			}
+
 			var E *Curve
 			if j.Equals(ln.BigZero) {
 				a := R.RandomElement()
@@ -217,11 +232,10 @@ func Attack(ks []*keys.RSA, ch chan error) {
 
 			if g.Cmp(ln.BigOne) > 0 {
 				k.PackGivenP(g)
-				ch <- nil
-				return
+				return attacks.Solved(k)
 			}
 		}
 	}
 
-	ch <- fmt.Errorf("%s attack failed - no factors found", name)
+	return attacks.Failed("%s attack failed - no factors found", name)
 }
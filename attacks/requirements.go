@@ -0,0 +1,201 @@
+package attacks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sourcekris/goRsaTool/keys"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// Requirements declares the inputs an attack needs before it is worth running. The zero value
+// means the attack only needs a public key.
+type Requirements struct {
+	CipherText        bool
+	KnownPlainText    bool
+	UnknownBytes      bool
+	Hints             int
+	PartialD          bool
+	PartialP          bool
+	KnownPrime        bool
+	Precomputed       bool
+	CRTExponent       bool
+	OracleCiphertexts bool
+	// MinKeys and MaxKeys bound the number of keys the attack works on, 0 means no bound.
+	MinKeys int
+	MaxKeys int
+	// MinE and MaxE bound the public exponent of every key, 0 means no bound.
+	MinE int64
+	MaxE int64
+	// LargeE requires a public exponent close to the modulus in size, at least 7/8 of its bits.
+	LargeE bool
+	// MinBits and MaxBits bound the size of the modulus of every key, 0 means no bound.
+	MinBits int
+	MaxBits int
+}
+
+// Check returns an error describing the first requirement that ks does not meet. When multi is
+// false only the first key is checked since that is the only key the attack uses.
+func (r Requirements) Check(ks []*keys.RSA, multi bool) error {
+	if len(ks) == 0 {
+		return fmt.Errorf("no keys provided")
+	}
+
+	if r.MinKeys > 0 && len(ks) < r.MinKeys {
+		return fmt.Errorf("requires at least %d keys, got %d", r.MinKeys, len(ks))
+	}
+
+	if r.MaxKeys > 0 && len(ks) > r.MaxKeys {
+		return fmt.Errorf("requires at most %d keys, got %d", r.MaxKeys, len(ks))
+	}
+
+	if !multi {
+		ks = ks[:1]
+	}
+
+	for _, k := range ks {
+		switch {
+		case r.CipherText && k.CipherText == nil:
+			return fmt.Errorf("requires a ciphertext for key %s", k.KeyFilename)
+		case r.KnownPlainText && k.KnownPlainText == nil:
+			return fmt.Errorf("requires a known plaintext for key %s", k.KeyFilename)
+		case r.UnknownBytes && k.UnknownBytes == 0:
+			return fmt.Errorf("requires the number of unknown plaintext bytes (-unknownbytes) for key %s", k.KeyFilename)
+		case len(k.Hints) < r.Hints:
+			return fmt.Errorf("requires %d hints, got %d", r.Hints, len(k.Hints))
+		case r.PartialD && k.DLSB == nil && k.DHigh == nil && k.DpHigh == nil && k.DpLow == nil:
+			return fmt.Errorf("requires partial bits of d or dp (d0, d_high, dp_high, dp_low) for key %s", k.KeyFilename)
+		case r.PartialP && k.PHigh == nil && k.PLow == nil:
+			return fmt.Errorf("requires the high or low bits of p (p_high, p_low) for key %s", k.KeyFilename)
+		case r.KnownPrime && len(k.Key.Primes) == 0:
+			return fmt.Errorf("requires a known prime for key %s", k.KeyFilename)
+		case r.Precomputed && (k.Key.Precomputed == nil || k.Key.Precomputed.Dp == nil || k.Key.Precomputed.Dq == nil):
+			return fmt.Errorf("requires the precomputed CRT values (dp, dq) for key %s", k.KeyFilename)
+		case r.CRTExponent && (k.Key.Precomputed == nil || k.Key.Precomputed.Dp == nil && k.Key.Precomputed.Dq == nil):
+			return fmt.Errorf("requires a CRT exponent (dp or dq) for key %s", k.KeyFilename)
+		case r.OracleCiphertexts && len(k.OracleCiphertexts) == 0:
+			return fmt.Errorf("requires oracle ciphertexts for key %s", k.KeyFilename)
+		}
+
+		if n := k.Key.N; n != nil {
+			if r.MinBits > 0 && n.BitLen() < r.MinBits {
+				return fmt.Errorf("requires a modulus of at least %d bits, got %d", r.MinBits, n.BitLen())
+			}
+
+			if r.MaxBits > 0 && n.BitLen() > r.MaxBits {
+				return fmt.Errorf("requires a modulus of at most %d bits, got %d", r.MaxBits, n.BitLen())
+			}
+		}
+
+		e := k.Key.PublicKey.E
+		if e == nil {
+			continue
+		}
+
+		if n := k.Key.N; r.LargeE && n != nil && e.BitLen() < n.BitLen()-n.BitLen()/8 {
+			return fmt.Errorf("requires e close to n in size, got a %d bit e for a %d bit n", e.BitLen(), n.BitLen())
+		}
+
+		if r.MinE > 0 && e.Cmp(fmp.NewFmpz(r.MinE)) < 0 {
+			return fmt.Errorf("requires e >= %d, got %v", r.MinE, e)
+		}
+
+		if r.MaxE > 0 && e.Cmp(fmp.NewFmpz(r.MaxE)) > 0 {
+			return fmt.Errorf("requires e <= %d, got %v", r.MaxE, e)
+		}
+	}
+
+	return nil
+}
+
+// String returns a short human readable summary of the requirements.
+func (r Requirements) String() string {
+	var s []string
+	if r.CipherText {
+		s = append(s, "ciphertext")
+	}
+
+	if r.KnownPlainText {
+		s = append(s, "known plaintext")
+	}
+
+	if r.UnknownBytes {
+		s = append(s, "unknown bytes")
+	}
+
+	if r.Hints > 0 {
+		s = append(s, fmt.Sprintf("%d hints", r.Hints))
+	}
+
+	if r.PartialD {
+		s = append(s, "partial d")
+	}
+
+	if r.PartialP {
+		s = append(s, "partial p")
+	}
+
+	if r.KnownPrime {
+		s = append(s, "known prime")
+	}
+
+	if r.Precomputed {
+		s = append(s, "dp/dq")
+	}
+
+	if r.CRTExponent {
+		s = append(s, "dp or dq")
+	}
+
+	if r.OracleCiphertexts {
+		s = append(s, "oracle ciphertexts")
+	}
+
+	switch {
+	case r.MinKeys > 0 && r.MinKeys == r.MaxKeys:
+		s = append(s, fmt.Sprintf("%d keys", r.MinKeys))
+	case r.MinKeys > 0 && r.MaxKeys > 0:
+		s = append(s, fmt.Sprintf("%d-%d keys", r.MinKeys, r.MaxKeys))
+	case r.MinKeys > 0:
+		s = append(s, fmt.Sprintf("%d+ keys", r.MinKeys))
+	case r.MaxKeys > 0:
+		s = append(s, fmt.Sprintf("up to %d keys", r.MaxKeys))
+	}
+
+	switch {
+	case r.MinE > 0 && r.MinE == r.MaxE:
+		s = append(s, fmt.Sprintf("e = %d", r.MinE))
+	case r.MinE > 0 && r.MaxE > 0:
+		s = append(s, fmt.Sprintf("%d <= e <= %d", r.MinE, r.MaxE))
+	case r.MinE > 0:
+		s = append(s, fmt.Sprintf("e >= %d", r.MinE))
+	case r.MaxE > 0:
+		s = append(s, fmt.Sprintf("e <= %d", r.MaxE))
+	}
+
+	if r.LargeE {
+		s = append(s, "e close to n")
+	}
+
+	switch {
+	case r.MinBits > 0 && r.MaxBits > 0:
+		s = append(s, fmt.Sprintf("%d-%d bit n", r.MinBits, r.MaxBits))
+	case r.MinBits > 0:
+		s = append(s, fmt.Sprintf("n >= %d bits", r.MinBits))
+	case r.MaxBits > 0:
+		s = append(s, fmt.Sprintf("n <= %d bits", r.MaxBits))
+	}
+
+	if len(s) == 0 {
+		return "-"
+	}
+
+	return strings.Join(s, ", ")
+}
+
+// Applicable returns nil if ks meets the requirements of the attack or an error explaining why
+// the attack cannot be used.
+func (at *Attack) Applicable(ks []*keys.RSA) error {
+	return at.Requires.Check(ks, at.SupportsMulti)
+}
@@ -0,0 +1,104 @@
+package attacks
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sourcekris/goRsaTool/keys"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// Status describes the outcome of an attack.
+type Status int
+
+const (
+	// StatusFailed means the attack ran but did not recover anything.
+	StatusFailed Status = iota
+	// StatusSucceeded means the attack recovered the factors, private exponent or plaintext.
+	StatusSucceeded
+	// StatusNotApplicable means the keys do not meet the requirements of the attack.
+	StatusNotApplicable
+	// StatusTimedOut means the attack was stopped before it finished.
+	StatusTimedOut
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusSucceeded:
+		return "succeeded"
+	case StatusNotApplicable:
+		return "not applicable"
+	case StatusTimedOut:
+		return "timed out"
+	default:
+		return "failed"
+	}
+}
+
+// Result is the outcome of running an attack against one or more keys.
+type Result struct {
+	// Name is the registered name of the attack, filled in by Execute.
+	Name      string
+	Status    Status
+	Factors   []*fmp.Fmpz
+	D         *fmp.Fmpz
+	PlainText []byte
+	// Duration is how long the attack ran for, filled in by Execute.
+	Duration time.Duration
+	Message  string
+}
+
+// Solved returns a succeeded Result holding the values recovered into k.
+func Solved(k *keys.RSA) *Result {
+	return &Result{
+		Status:    StatusSucceeded,
+		Factors:   k.Key.Primes,
+		D:         k.Key.D,
+		PlainText: k.PlainText,
+	}
+}
+
+// NotApplicable returns a Result explaining why the attack cannot be used against the keys.
+func NotApplicable(format string, a ...interface{}) *Result {
+	return &Result{Status: StatusNotApplicable, Message: fmt.Sprintf(format, a...)}
+}
+
+// Failed returns a Result explaining why the attack did not succeed.
+func Failed(format string, a ...interface{}) *Result {
+	return &Result{Status: StatusFailed, Message: fmt.Sprintf(format, a...)}
+}
+
+// Stopped returns a Result for an attack that gave up because err, normally ctx.Err(), occurred.
+func Stopped(err error) *Result {
+	return &Result{Status: StatusTimedOut, Message: err.Error()}
+}
+
+// Succeeded returns true if the attack recovered something.
+func (r *Result) Succeeded() bool {
+	return r != nil && r.Status == StatusSucceeded
+}
+
+// Err returns nil if the attack succeeded or an error describing the outcome otherwise.
+func (r *Result) Err() error {
+	switch {
+	case r == nil:
+		return errors.New("attack returned no result")
+	case r.Status == StatusSucceeded:
+		return nil
+	case r.Message != "":
+		return errors.New(r.Message)
+	default:
+		return fmt.Errorf("%s %v", r.Name, r.Status)
+	}
+}
+
+func (r *Result) String() string {
+	s := fmt.Sprintf("%s: %v in %v", r.Name, r.Status, r.Duration.Round(time.Millisecond))
+	if r.Message != "" {
+		s = fmt.Sprintf("%s - %s", s, r.Message)
+	}
+
+	return s
+}
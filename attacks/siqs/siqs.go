@@ -0,0 +1,431 @@
+// Package siqs implements the self-initialising quadratic sieve factorization method which
+// factors balanced moduli of up to around 100 digits.
+package siqs
+
+import (
+	"context"
+	"log"
+	"math"
+	"math/bits"
+	"math/rand"
+	"sync"
+
+	"github.com/sourcekris/goRsaTool/attacks"
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// name is the name of this attack.
+const name = "self-initialising quadratic sieve"
+
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "siqs",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostSlow,
+		Requires:   attacks.Requirements{MinBits: 64, MaxBits: 350},
+		F:          Attack,
+	})
+}
+
+// params are the factor base size and sieve half width to use for moduli up to digits long.
+var params = []struct {
+	digits int
+	fbSize int
+	m      int
+}{
+	{24, 100, 4096},
+	{30, 200, 8192},
+	{36, 400, 16384},
+	{42, 600, 32768},
+	{48, 1000, 32768},
+	{54, 1500, 65536},
+	{60, 2500, 65536},
+	{66, 4000, 65536},
+	{72, 6000, 98304},
+	{80, 10000, 131072},
+	{90, 18000, 196608},
+	{1000, 30000, 262144},
+}
+
+// multipliers are the small square free values of k considered for sieving over kn.
+var multipliers = []int64{1, 3, 5, 7, 11, 13, 15, 17, 19, 21, 23, 29, 31, 33, 35, 37, 39, 41, 43, 47}
+
+const (
+	// minAPrime is the smallest prime used as a factor of a, larger than any multiplier.
+	minAPrime = 50
+	// minSievePrime is the smallest prime that is sieved, smaller primes are only trial divided.
+	minSievePrime = 30
+	// largePrimeMultiplier bounds the large prime allowed in partial relations as a multiple of
+	// the largest prime in the factor base.
+	largePrimeMultiplier = 64
+	// extraRelations is how many relations beyond the factor base size to collect.
+	extraRelations = 32
+)
+
+// siqs holds the state shared by each sieving worker.
+type siqs struct {
+	n, kn    *fmp.Fmpz
+	fb       []fbPrime
+	m        int
+	targetA  *fmp.Fmpz
+	afactors int
+	// poolLo and poolHi bound the factor base indexes that the factors of a are chosen from.
+	poolLo, poolHi int
+	firstA         int
+	firstSieve     int
+	threshold      byte
+	largeMax       int64
+
+	mu    sync.Mutex
+	seenA map[string]bool
+}
+
+// chooseMultiplier returns the multiplier k that gives kn the most small quadratic residues
+// using the Knuth-Schroeppel function.
+func chooseMultiplier(n *fmp.Fmpz, primes []int) int64 {
+	var (
+		best      = int64(1)
+		bestScore = math.Inf(-1)
+		n8        = modSmall(n, fmp.NewFmpz(8))
+	)
+
+	for _, k := range multipliers {
+		score := -0.5 * math.Log(float64(k))
+
+		switch k * n8 % 8 {
+		case 1:
+			score += 2 * math.Ln2
+		case 5:
+			score += math.Ln2
+		case 3, 7:
+			score += 0.5 * math.Ln2
+		}
+
+		for _, p := range primes {
+			if p == 2 {
+				continue
+			}
+
+			pp := int64(p)
+			switch {
+			case k%pp == 0:
+				score += math.Log(float64(p)) / float64(p)
+			case powMod(k%pp*modSmall(n, fmp.NewFmpz(pp))%pp, (pp-1)/2, pp) == 1:
+				score += 2 * math.Log(float64(p)) / float64(p-1)
+			}
+		}
+
+		if score > bestScore {
+			best, bestScore = k, score
+		}
+	}
+
+	return best
+}
+
+// newSiqs builds the factor base and polynomial parameters for n. If a factor of n turns up
+// while doing so it is returned instead.
+func newSiqs(n *fmp.Fmpz) (*siqs, *fmp.Fmpz) {
+	digits := len(n.String())
+	fbSize, m := params[len(params)-1].fbSize, params[len(params)-1].m
+	for _, p := range params {
+		if digits <= p.digits {
+			fbSize, m = p.fbSize, p.m
+			break
+		}
+	}
+
+	k := chooseMultiplier(n, ln.SieveOfEratosthenes(1000))
+	if g := new(fmp.Fmpz).GCD(n, fmp.NewFmpz(k)); !g.Equals(ln.BigOne) {
+		return nil, g
+	}
+
+	s := &siqs{
+		n:     n,
+		kn:    new(fmp.Fmpz).Mul(n, fmp.NewFmpz(k)),
+		m:     m,
+		seenA: make(map[string]bool),
+	}
+
+	// Build the factor base from the primes for which kn is a quadratic residue.
+	s.fb = append(s.fb, fbPrime{p: 2, fp: fmp.NewFmpz(2), logp: 1, t: 1})
+	for limit := fbSize * 16; len(s.fb) < fbSize; limit *= 2 {
+		s.fb = s.fb[:1]
+		for _, p := range ln.SieveOfEratosthenes(limit) {
+			if p == 2 {
+				continue
+			}
+
+			fp := fmp.NewFmpz(int64(p))
+			r := modSmall(s.kn, fp)
+			switch {
+			case r == 0 && k%int64(p) != 0:
+				return nil, fp
+			case r != 0 && powMod(r, int64(p-1)/2, int64(p)) != 1:
+				continue
+			}
+
+			s.fb = append(s.fb, fbPrime{
+				p:    p,
+				fp:   fp,
+				logp: byte(math.Round(math.Log2(float64(p)))),
+				t:    int(sqrtMod(r, int64(p))),
+			})
+
+			if len(s.fb) == fbSize {
+				break
+			}
+		}
+	}
+
+	for s.firstA = 0; s.fb[s.firstA].p < minAPrime; s.firstA++ {
+	}
+
+	for s.firstSieve = 0; s.fb[s.firstSieve].p < minSievePrime; s.firstSieve++ {
+	}
+
+	// The best a is close to sqrt(2kn)/m so that Q(x) is as small as possible over the interval.
+	s.targetA = new(fmp.Fmpz).Sqrt(new(fmp.Fmpz).Mul(s.kn, ln.BigTwo))
+	s.targetA.Div(s.targetA, fmp.NewFmpz(int64(m)))
+
+	pmax := s.fb[len(s.fb)-1].p
+	qbits := bitLen(pmax) - 1
+	if qbits > 11 {
+		qbits = 11
+	}
+
+	s.afactors = int(math.Round(float64(s.targetA.BitLen()) / float64(qbits)))
+	if s.afactors < 2 {
+		s.afactors = 2
+	}
+
+	s.poolLo, s.poolHi = s.firstA, len(s.fb)
+	for j := s.firstA; j < len(s.fb); j++ {
+		switch b := bitLen(s.fb[j].p); {
+		case b < qbits-1:
+			s.poolLo = j + 1
+		case b > qbits+1 && s.poolHi == len(s.fb):
+			s.poolHi = j
+		}
+	}
+
+	if s.poolHi-s.poolLo < 2*s.afactors {
+		s.poolLo, s.poolHi = s.firstA, len(s.fb)
+	}
+
+	// Q(x) is at most around m * sqrt(kn/2). Allow for a large prime and the primes not sieved.
+	logQ := math.Log2(float64(m)) + float64(s.kn.BitLen())/2 - 0.5
+	s.largeMax = int64(pmax) * largePrimeMultiplier
+	s.threshold = byte(logQ - math.Log2(float64(s.largeMax)) - math.Log2(minSievePrime))
+
+	return s, nil
+}
+
+func bitLen(x int) int {
+	return bits.Len(uint(x))
+}
+
+// worker sieves polynomials with new a values until ctx is done.
+func (s *siqs) worker(ctx context.Context, seed int64, out chan<- *relation) {
+	var (
+		rng = rand.New(rand.NewSource(seed))
+		sv  = make([]byte, 2*s.m)
+		nb  = 1 << uint(s.afactors-1)
+	)
+
+	for ctx.Err() == nil {
+		q := s.choosePolyA(rng)
+		if q == nil {
+			continue
+		}
+
+		for i := 0; i < nb && ctx.Err() == nil; i++ {
+			if i > 0 {
+				s.nextPolyB(q, i)
+			}
+
+			s.sieve(q, sv)
+			s.scan(ctx, q, sv, out)
+		}
+	}
+}
+
+// combine returns the full relation made from two partial relations with the same large prime.
+func (s *siqs) combine(r1, r2 *relation) *relation {
+	sq := fmp.NewFmpz(r1.large)
+	if r1.sq != nil {
+		sq.MulZ(r1.sq)
+	}
+
+	if r2.sq != nil {
+		sq.MulZ(r2.sq)
+	}
+
+	return &relation{
+		u:       new(fmp.Fmpz).Mul(r1.u, r2.u).ModZ(s.n),
+		neg:     r1.neg != r2.neg,
+		factors: append(append([]int{}, r1.factors...), r2.factors...),
+		large:   1,
+		sq:      sq.ModZ(s.n),
+	}
+}
+
+// solve finds the dependencies between the relations and returns a proper factor of n if one
+// of them gives a congruence of squares that splits n.
+func (s *siqs) solve(ctx context.Context, rels []*relation) *fmp.Fmpz {
+	ncols := len(s.fb) + 1
+	rows := make([]ln.GF2Row, len(rels))
+	for i, r := range rels {
+		rows[i] = ln.NewGF2Row(ncols)
+		if r.neg {
+			rows[i].Flip(0)
+		}
+
+		for _, j := range r.factors {
+			rows[i].Flip(j + 1)
+		}
+	}
+
+	for _, dep := range ln.NullSpaceGF2(rows, ncols) {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		var (
+			x      = fmp.NewFmpz(1)
+			y      = fmp.NewFmpz(1)
+			counts = make([]int, len(s.fb))
+		)
+
+		for _, i := range dep {
+			x.MulZ(rels[i].u).ModZ(s.n)
+			if rels[i].sq != nil {
+				y.MulZ(rels[i].sq).ModZ(s.n)
+			}
+
+			for _, j := range rels[i].factors {
+				counts[j]++
+			}
+		}
+
+		for j, c := range counts {
+			if c > 0 {
+				y.MulZ(new(fmp.Fmpz).Exp(s.fb[j].fp, fmp.NewFmpz(int64(c/2)), s.n)).ModZ(s.n)
+			}
+		}
+
+		g := new(fmp.Fmpz).GCD(new(fmp.Fmpz).Sub(x, y), s.n)
+		if !g.Equals(ln.BigOne) && !g.Equals(s.n) {
+			return g
+		}
+	}
+
+	return nil
+}
+
+// factor returns a proper factor of n or nil if ctx is done first.
+func factor(ctx context.Context, n *fmp.Fmpz, workers int) *fmp.Fmpz {
+	if r := new(fmp.Fmpz).Sqrt(n); new(fmp.Fmpz).Mul(r, r).Equals(n) {
+		return r
+	}
+
+	s, f := newSiqs(n)
+	if f != nil {
+		return f
+	}
+
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		out  = make(chan *relation, 256)
+		seed = rand.Int63()
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.worker(sctx, seed+int64(i), out)
+		}(i)
+	}
+
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	var (
+		full    []*relation
+		partial = make(map[int64]*relation)
+		seen    = make(map[string]bool)
+		need    = len(s.fb) + extraRelations
+	)
+
+	for {
+		var rel *relation
+		select {
+		case rel = <-out:
+		case <-ctx.Done():
+			return nil
+		}
+
+		key := rel.u.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if rel.large != 1 {
+			other, ok := partial[rel.large]
+			if !ok {
+				partial[rel.large] = rel
+				continue
+			}
+
+			rel = s.combine(other, rel)
+		}
+
+		full = append(full, rel)
+		if len(full) < need {
+			continue
+		}
+
+		if f := s.solve(ctx, full); f != nil {
+			return f
+		}
+
+		// Every dependency was trivial, collect some more relations and try again.
+		need += extraRelations
+	}
+}
+
+// Attack factors the modulus using the self-initialising quadratic sieve.
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
+	k := ks[0]
+	if k.Key.D != nil {
+		return attacks.Solved(k)
+	}
+
+	if k.Verbose {
+		log.Printf("%s attempt beginning with %d workers", name, k.NumWorkers())
+	}
+
+	p := factor(ctx, k.Key.N, k.NumWorkers())
+	if p == nil {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
+		return attacks.Failed("%s failed to find a factor", name)
+	}
+
+	k.PackGivenP(p)
+
+	return attacks.Solved(k)
+}
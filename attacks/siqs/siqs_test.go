@@ -0,0 +1,74 @@
+package siqs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+	"github.com/sourcekris/goRsaTool/utils"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+func TestAttack(t *testing.T) {
+	tt := []struct {
+		name  string
+		n     *fmp.Fmpz
+		wantP *fmp.Fmpz
+		wantQ *fmp.Fmpz
+	}{
+		{
+			name:  "24 digit modulus",
+			n:     ln.FmpString("885018525533795756320081"),
+			wantP: ln.FmpString("979534298993"),
+			wantQ: ln.FmpString("903509480417"),
+		},
+		{
+			name:  "33 digit modulus",
+			n:     ln.FmpString("223437604355710766662224889375963"),
+			wantP: ln.FmpString("15825190558182839"),
+			wantQ: ln.FmpString("14119109879544317"),
+		},
+		{
+			name:  "40 digit modulus",
+			n:     ln.FmpString("4878002507295970894800811002770539307189"),
+			wantP: ln.FmpString("71992556730578705929"),
+			wantQ: ln.FmpString("67757039461053732941"),
+		},
+	}
+
+	for _, tc := range tt {
+		fmpPubKey := &keys.FMPPublicKey{
+			N: tc.n,
+			E: fmp.NewFmpz(65537),
+		}
+
+		k, _ := keys.NewRSA(keys.PrivateFromPublic(fmpPubKey), nil, nil, "", false)
+		if err := Attack(context.Background(), []*keys.RSA{k}).Err(); err != nil {
+			t.Errorf("Attack() failed: %s expected no error got error: %v", tc.name, err)
+		}
+
+		if k.Key.D == nil {
+			t.Errorf("Attack() failed: %s d not found", tc.name)
+		}
+
+		if !utils.FoundP(tc.wantP, k.Key.Primes) || !utils.FoundP(tc.wantQ, k.Key.Primes) {
+			t.Errorf("Attack() failed: %s expected primes not found - got %v wanted %v, %v", tc.name, k.Key.Primes, tc.wantP, tc.wantQ)
+		}
+	}
+}
+
+func TestSqrtMod(t *testing.T) {
+	for _, p := range []int64{3, 5, 13, 17, 41, 97, 65537, 1000003} {
+		for n := int64(1); n < 50; n++ {
+			if powMod(n, (p-1)/2, p) != 1 {
+				continue
+			}
+
+			if r := sqrtMod(n, p); r*r%p != n%p {
+				t.Errorf("sqrtMod(%d, %d) = %d is not a square root", n, p, r)
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Package smallcrt factors n when one of the CRT exponents dp = d mod (p - 1) or dq is small even
+// though d itself is not. For g = m^e mod n, g^dp = m mod p so writing dp = i * L + j, p divides
+// F(g^(i * L)) where F(x) is the product of x - m * g^-j for 0 <= j < L. F is evaluated at the L
+// points g^(i * L) with a subproduct tree which takes about sqrt(dp) operations instead of dp.
+package smallcrt
+
+import (
+	"context"
+	"log"
+
+	"github.com/sourcekris/goRsaTool/attacks"
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// name is the name of this attack.
+const name = "small crt exponent"
+
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "smallcrt",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostSlow,
+		F:          Attack,
+	})
+}
+
+const (
+	// defaultMaxBits is the size of the largest CRT exponent searched for unless the key sets
+	// CRTMaxBits. The search takes about 2^(bits/2) operations on polynomials with coefficients
+	// the size of n.
+	defaultMaxBits = 32
+	// minBits is the size of the CRT exponents searched for first, the bound grows by stepBits
+	// up to the largest size so that small exponents are found quickly.
+	minBits  = 16
+	stepBits = 4
+	// chunk is the number of points evaluated together to bound the size of the subproduct tree.
+	chunk = 1 << 12
+)
+
+// factor returns a factor of n if dp or dq is below 2^(2 * half) or nil. It returns true when
+// both primes were found together and another m is needed to separate them.
+func factor(ctx context.Context, n, e, m *fmp.Fmpz, half int) (*fmp.Fmpz, bool) {
+	var (
+		l     = 1 << half
+		g     = new(fmp.Fmpz).Exp(m, e, n)
+		gInv  = new(fmp.Fmpz).ModInverse(g, n)
+		gL    = new(fmp.Fmpz).Exp(g, fmp.NewFmpz(int64(l)), n)
+		roots = make([]*fmp.Fmpz, l)
+	)
+
+	if gInv.IsZero() {
+		return new(fmp.Fmpz).GCD(g, n), false
+	}
+
+	// The baby steps m * g^-j.
+	r := new(fmp.Fmpz).Set(m)
+	for j := range roots {
+		roots[j] = new(fmp.Fmpz).Set(r)
+		r.Mul(r, gInv).ModZ(n)
+	}
+
+	f := ln.PolyFromRoots(roots, n)
+
+	// The giant steps g^(i * L).
+	var (
+		x   = fmp.NewFmpz(1)
+		acc = fmp.NewFmpz(1)
+		xs  = make([]*fmp.Fmpz, 0, chunk)
+	)
+
+	for i := 0; i < l; i++ {
+		xs = append(xs, new(fmp.Fmpz).Set(x))
+		x.Mul(x, gL).ModZ(n)
+
+		if len(xs) < chunk && i < l-1 {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return nil, false
+		}
+
+		vals := ln.MultipointEval(f, xs)
+		for _, v := range vals {
+			acc.Mul(acc, v).ModZ(n)
+		}
+
+		switch g := new(fmp.Fmpz).GCD(acc, n); {
+		case g.Equals(n):
+			// Both primes divide the product, look at the values one at a time.
+			for _, v := range vals {
+				if g := new(fmp.Fmpz).GCD(v, n); g.Cmp(ln.BigOne) > 0 && g.Cmp(n) < 0 {
+					return g, false
+				}
+			}
+
+			return nil, true
+		case g.Cmp(ln.BigOne) > 0:
+			return g, false
+		}
+
+		xs = xs[:0]
+	}
+
+	return nil, false
+}
+
+// Attack implements the small CRT exponent attack.
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
+	k := ks[0]
+	if k.Key.D != nil {
+		return attacks.Solved(k)
+	}
+
+	maxBits := defaultMaxBits
+	if k.CRTMaxBits > 0 {
+		maxBits = k.CRTMaxBits
+	}
+
+	for bits := minBits; bits <= maxBits; bits += stepBits {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
+		if k.Verbose {
+			log.Printf("%s: searching for dp or dq below 2^%d", name, bits)
+		}
+
+		// When both primes are found together another m separates them.
+		for m := int64(2); m < 8; m++ {
+			p, both := factor(ctx, k.Key.N, k.Key.PublicKey.E, fmp.NewFmpz(m), (bits+1)/2)
+			if p != nil {
+				k.PackGivenP(p)
+				return attacks.Solved(k)
+			}
+
+			if !both {
+				break
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		return attacks.Stopped(ctx.Err())
+	}
+
+	return attacks.Failed("%s found no dp or dq below 2^%d", name, maxBits)
+}
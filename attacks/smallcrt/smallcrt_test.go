@@ -0,0 +1,51 @@
+package smallcrt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+	"github.com/sourcekris/goRsaTool/utils"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+func TestAttack(t *testing.T) {
+	n := ln.FmpString("67779169991156313953976086119991464718779730349607858782745496509542441091211")
+
+	tt := []struct {
+		name    string
+		e       *fmp.Fmpz
+		maxBits int
+		wantP   *fmp.Fmpz
+		wantErr bool
+	}{
+		{
+			name:    "19 bit dp",
+			e:       ln.FmpString("104756406782478660604448783048540202107"),
+			maxBits: 20,
+			wantP:   ln.FmpString("206649527463365580008337435483770722577"),
+		},
+		{
+			name:    "ordinary crt exponents",
+			e:       fmp.NewFmpz(65537),
+			maxBits: 16,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: n, E: tc.e}), nil, nil, "", false)
+		k.CRTMaxBits = tc.maxBits
+
+		err := Attack(context.Background(), []*keys.RSA{k}).Err()
+		if (err != nil) != tc.wantErr {
+			t.Errorf("Attack() %s: want error %v got %v", tc.name, tc.wantErr, err)
+		}
+
+		if !tc.wantErr && !utils.FoundP(tc.wantP, k.Key.Primes) {
+			t.Errorf("Attack() %s: expected primes not found - got %v wanted %v", tc.name, k.Key.Primes, tc.wantP)
+		}
+	}
+}
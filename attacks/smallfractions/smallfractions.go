@@ -1,8 +1,9 @@
 package smallfractions
 
 import (
-	"fmt"
+	"context"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -12,6 +13,16 @@ import (
 // name is the name of this attack.
 const name = "small fractions"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "smallfractions",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostFast,
+		F:          Attack,
+	})
+}
+
 // depth is the max size of the numerator and denominator to test to.
 const depth int64 = 50
 
@@ -25,18 +36,22 @@ var (
 )
 
 // Attack implements SmallFractions attack.
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 	k := ks[0]
 	if k.Key.D != nil {
-		ch <- nil
+		return attacks.Solved(k)
 	}
 
 	var num, den int64
 
 	n := fmpz(0).Set(k.Key.N)
-	ctx := fmp.NewFmpzModCtx(n)
+	mctx := fmp.NewFmpzModCtx(n)
 	for den = 2; den < depth+1; den++ {
 		for num = 1; num < den; num++ {
+			if ctx.Err() != nil {
+				return attacks.Stopped(ctx.Err())
+			}
+
 			g := fmpz(0).GCD(fmpz(num), fmpz(den))
 
 			if g.Equals(ln.BigOne) {
@@ -47,8 +62,8 @@ func Attack(ks []*keys.RSA, ch chan error) {
 				X.Div(X, ln.BigTwo)
 
 				// f = x - phint
-				f := modpoly(ctx).SetCoeffUI(1, 1)
-				f.Sub(f, modpoly(ctx).SetCoeff(0, phint))
+				f := modpoly(mctx).SetCoeffUI(1, 1)
+				f.Sub(f, modpoly(mctx).SetCoeff(0, phint))
 
 				// Copy f to type FmpzPoly.
 				fp := poly()
@@ -121,8 +136,7 @@ func Attack(ks []*keys.RSA, ch chan error) {
 							}
 							if fmpz(0).Mod(k.Key.N, pp).Equals(ln.BigZero) {
 								k.PackGivenP(pp)
-								ch <- nil
-								return
+								return attacks.Solved(k)
 							}
 						}
 					}
@@ -131,5 +145,5 @@ func Attack(ks []*keys.RSA, ch chan error) {
 		}
 	}
 
-	ch <- fmt.Errorf("%s did not find the factors", name)
+	return attacks.Failed("%s did not find the factors", name)
 }
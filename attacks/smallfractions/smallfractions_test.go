@@ -1,6 +1,7 @@
 package smallfractions
 
 import (
+	"context"
 	"testing"
 
 	"github.com/sourcekris/goRsaTool/keys"
@@ -15,9 +16,7 @@ func TestAttack(t *testing.T) {
 	}
 
 	k, _ := keys.NewRSA(keys.PrivateFromPublic(fmpPubKey), nil, nil, "", false)
-	ch := make(chan error)
-	go Attack([]*keys.RSA{k}, ch)
-	err := <-ch
+	err := Attack(context.Background(), []*keys.RSA{k}).Err()
 	if err != nil {
 		t.Errorf("attack")
 	}
@@ -1,9 +1,11 @@
 package smallq
 
 import (
+	"context"
 	"log"
 
 	"github.com/jbarham/primegen"
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -13,6 +15,16 @@ import (
 // name is the name of this attack.
 const name = "small q"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "smallq",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostInstant,
+		F:          Attack,
+	})
+}
+
 func chk(p, n *fmp.Fmpz) (bool, *fmp.Fmpz) {
 	zz := new(fmp.Fmpz).Set(p)
 	if new(fmp.Fmpz).Mod(n, zz).Equals(ln.BigZero) {
@@ -22,11 +34,10 @@ func chk(p, n *fmp.Fmpz) (bool, *fmp.Fmpz) {
 }
 
 // Attack iterate small primes until we timeout and test them as factors of N.
-func Attack(ts []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ts []*keys.RSA) *attacks.Result {
 	t := ts[0]
 	if t.Key.D != nil {
-		ch <- nil
-		return
+		return attacks.Solved(t)
 	}
 
 	if t.Verbose {
@@ -36,11 +47,14 @@ func Attack(ts []*keys.RSA, ch chan error) {
 	pc := new(fmp.Fmpz)
 	pr := primegen.New()
 	for {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		pc.SetUint64(pr.Next())
 		if res, pp := chk(pc, t.Key.N); res {
 			t.PackGivenP(pp)
-			ch <- nil
-			return
+			return attacks.Solved(t)
 		}
 	}
 }
@@ -1,8 +1,9 @@
 package squaren
 
 import (
-	"fmt"
+	"context"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -12,24 +13,32 @@ import (
 // name is the name of this attack.
 const name = "square n"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "squaren",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostInstant,
+		F:          Attack,
+	})
+}
+
 // Attack recovers the private key when N is square.
-func Attack(ts []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ts []*keys.RSA) *attacks.Result {
 	t := ts[0]
 	if t.Key.D != nil {
-		ch <- nil
-		return
+		return attacks.Solved(t)
 	}
 
 	p := new(fmp.Fmpz).Root(t.Key.N, 2)
 
 	if new(fmp.Fmpz).Mul(p, p).Cmp(t.Key.N) != 0 {
-		ch <- fmt.Errorf("%s failed - n is not square", name)
-		return
+		return attacks.Failed("%s failed - n is not square", name)
 	}
 
 	t.Key.Primes = append(t.Key.Primes, p, p)
 	phin := new(fmp.Fmpz).Mul(p, new(fmp.Fmpz).Sub(p, ln.BigOne))
 	t.PackGivenD(new(fmp.Fmpz).ModInverse(t.Key.PublicKey.E, phin))
 
-	ch <- nil
+	return attacks.Solved(t)
 }
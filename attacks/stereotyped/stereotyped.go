@@ -0,0 +1,122 @@
+// Package stereotyped implements Coppersmith's stereotyped message attack. When a small public
+// exponent is used and all but the last few bytes of the plaintext are known, the unknown suffix
+// x0 is a small root of (prefix * 256^L + x)^e - c modulo N even when m^e is larger than N.
+package stereotyped
+
+import (
+	"context"
+	"log"
+
+	"github.com/sourcekris/goRsaTool/attacks"
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// name is the name of this attack.
+const name = "stereotyped message"
+
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "stereotyped",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostFast,
+		Requires:   attacks.Requirements{CipherText: true, KnownPlainText: true, MaxE: 11},
+		// Trying every length builds a lattice per length so that only happens when the attack
+		// is selected by name.
+		UnnatendedRequires: attacks.Requirements{UnknownBytes: true},
+		F:                  Attack,
+	})
+}
+
+// maxUnknownBytes returns the largest number of unknown bytes that can be recovered for a
+// modulus of the given size and exponent e, which is a little under 1/e of the modulus.
+func maxUnknownBytes(bits int, e int) int {
+	return bits / (e * 8)
+}
+
+// unknownSuffix returns the unknown suffix of length l bytes of the plaintext of c that starts with
+// prefix, or nil if there is none.
+func unknownSuffix(n, e, c *fmp.Fmpz, prefix []byte, l int) *fmp.Fmpz {
+	var (
+		bound = fmp.NewFmpz(1).Lsh(8 * l)
+		known = ln.BytesToNumber(prefix)
+	)
+
+	known.Mul(known, bound).ModZ(n)
+
+	// f(x) = (known + x)^e - c
+	f := fmp.NewFmpzPoly().SetCoeff(0, known).SetCoeffUI(1, 1)
+	f.Pow(f, int(e.Int64()))
+	f.SetCoeff(0, new(fmp.Fmpz).Sub(f.GetCoeff(0), c))
+
+	roots, err := ln.SmallRoots(f, n, ln.SmallRootsParams{
+		Epsilon: ln.SmallRootsEpsilon(n, bound, 1, f.Len()-1),
+		X:       bound,
+	})
+	if err != nil {
+		return nil
+	}
+
+	for _, x := range roots {
+		if x.Sign() < 0 || x.Cmp(bound) >= 0 {
+			continue
+		}
+
+		m := new(fmp.Fmpz).Add(known, x)
+		if new(fmp.Fmpz).Exp(m, e, n).Equals(c) {
+			return x
+		}
+	}
+
+	return nil
+}
+
+// Attack implements the stereotyped message attack.
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
+	k := ks[0]
+	if k.CipherText == nil || k.KnownPlainText == nil {
+		return attacks.NotApplicable("%s requires a ciphertext and a known plaintext prefix", name)
+	}
+
+	var (
+		n     = k.Key.N
+		e     = k.Key.PublicKey.E
+		c     = ln.BytesToNumber(k.CipherText)
+		limit = maxUnknownBytes(n.BitLen(), int(e.Int64()))
+		first = 1
+	)
+
+	if k.UnknownBytes > 0 {
+		if k.UnknownBytes > limit {
+			return attacks.NotApplicable("%s can recover at most %d unknown bytes for this key, got %d", name, limit, k.UnknownBytes)
+		}
+
+		first, limit = k.UnknownBytes, k.UnknownBytes
+	}
+
+	for l := first; l <= limit; l++ {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
+		if k.Verbose {
+			log.Printf("%s: trying %d unknown bytes", name, l)
+		}
+
+		x := unknownSuffix(n, e, c, k.KnownPlainText, l)
+		if x == nil {
+			continue
+		}
+
+		suffix := ln.NumberToBytes(x)
+		pt := append(append([]byte{}, k.KnownPlainText...), make([]byte, l-len(suffix))...)
+		k.PlainText = append(pt, suffix...)
+
+		return attacks.Solved(k)
+	}
+
+	return attacks.Failed("%s did not find the unknown bytes of the plaintext", name)
+}
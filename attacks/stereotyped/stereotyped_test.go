@@ -0,0 +1,60 @@
+package stereotyped
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sourcekris/goRsaTool/keys"
+	"github.com/sourcekris/goRsaTool/ln"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+func TestAttack(t *testing.T) {
+	// m^3 is larger than n so the plain cube root of the hastads attack does not work.
+	var (
+		n = ln.FmpString("67779169991156313953976086119991464718779730349607858782745496509542441091211")
+		c = ln.FmpString("23497133671483919648805008668379353435775429802488914468091139036975717087315")
+	)
+
+	tt := []struct {
+		name         string
+		kpt          string
+		unknownBytes int
+		want         string
+		wantErr      bool
+	}{
+		{
+			name:         "known number of unknown bytes",
+			kpt:          "pin is: ",
+			unknownBytes: 6,
+			want:         "pin is: s3cr3t",
+		},
+		{
+			name: "unknown number of unknown bytes",
+			kpt:  "pin is: ",
+			want: "pin is: s3cr3t",
+		},
+		{
+			name:         "wrong prefix",
+			kpt:          "pan is: ",
+			unknownBytes: 5,
+			wantErr:      true,
+		},
+	}
+
+	for _, tc := range tt {
+		k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: n, E: fmp.NewFmpz(3)}), ln.NumberToBytes(c), nil, "", false)
+		k.KnownPlainText = []byte(tc.kpt)
+		k.UnknownBytes = tc.unknownBytes
+
+		err := Attack(context.Background(), []*keys.RSA{k}).Err()
+		if (err != nil) != tc.wantErr {
+			t.Errorf("Attack() %s: want error %v got %v", tc.name, tc.wantErr, err)
+		}
+
+		if !tc.wantErr && string(k.PlainText) != tc.want {
+			t.Errorf("Attack() %s: want plaintext %q got %q", tc.name, tc.want, k.PlainText)
+		}
+	}
+}
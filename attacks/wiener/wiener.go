@@ -1,8 +1,10 @@
 package wiener
 
 import (
+	"context"
 	"log"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/attacks/wiener2"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
@@ -12,15 +14,25 @@ import (
 
 const name = "wiener"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "wiener",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostFast,
+		LargeE:     true,
+		F:          Attack,
+	})
+}
+
 // Attack implements the Wiener attack on an RSA public key and this implementation is based on the
 // python implementation of the algorithm by Pablo Celayes:
 // https://github.com/pablocelayes/rsa-wiener-attack
-func Attack(ts []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ts []*keys.RSA) *attacks.Result {
 	t := ts[0]
 	if t.Key.D != nil {
 		// Key already factored.
-		ch <- nil
-		return
+		return attacks.Solved(t)
 	}
 
 	frac := ln.RationalToContfract(t.Key.PublicKey.E, t.Key.N)
@@ -29,6 +41,10 @@ func Attack(ts []*keys.RSA, ch chan error) {
 	z := new(fmp.Fmpz)
 
 	for _, g := range convergants {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		k := g[0]
 		d := g[1]
 
@@ -41,8 +57,7 @@ func Attack(ts []*keys.RSA, ch chan error) {
 				if !ts.Equals(ln.BigNOne) && z.Add(s, ts).Mod(z, ln.BigTwo).Equals(ln.BigZero) {
 					// We found d, pack the private key.
 					t.PackGivenP(ln.FindPGivenD(d, t.Key.PublicKey.E, t.Key.N))
-					ch <- nil
-					return
+					return attacks.Solved(t)
 				}
 			}
 		}
@@ -53,5 +68,5 @@ func Attack(ts []*keys.RSA, ch chan error) {
 	}
 
 	// Try the variant approach.
-	wiener2.Attack(ts, ch)
+	return wiener2.Attack(ctx, ts)
 }
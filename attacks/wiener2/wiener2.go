@@ -1,8 +1,10 @@
 package wiener2
 
 import (
+	"context"
 	"log"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/attacks/wienervariant"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
@@ -68,11 +70,10 @@ func fullReverse(n, e *fmp.Fmpz, c [2]*fmp.Fmpz) *fmp.Fmpz {
 
 // Attack performs a variant of the wiener attack ported from the python version here:
 // https://github.com/MxRy/rsa-attacks/blob/master/wiener-attack.py
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 	k := ks[0]
 	if k.Key.D != nil {
-		ch <- nil
-		return
+		return attacks.Solved(k)
 	}
 
 	ts := fmp.NewFmpz(42)
@@ -80,15 +81,17 @@ func Attack(ks []*keys.RSA, ch chan error) {
 	convergants := ln.ConvergantsFromContfract(ln.RationalToContfract(k.Key.PublicKey.E, k.Key.N))
 
 	for _, c := range convergants {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		if squareAndMultiply(newc, c[1], k.Key.N).Equals(ts) {
 			if pp := fullReverse(k.Key.N, k.Key.PublicKey.E, c); pp != nil {
 				k.PackGivenP(pp)
-				ch <- nil
-				return
+				return attacks.Solved(k)
 			}
 			k.PackGivenP(ln.FindPGivenD(c[1], k.Key.PublicKey.E, k.Key.N))
-			ch <- nil
-			return
+			return attacks.Solved(k)
 		}
 	}
 
@@ -97,5 +100,5 @@ func Attack(ks []*keys.RSA, ch chan error) {
 	}
 
 	// Try the next variant approach.
-	wienervariant.Attack(ks, ch)
+	return wienervariant.Attack(ctx, ks)
 }
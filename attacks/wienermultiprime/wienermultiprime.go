@@ -1,8 +1,9 @@
 package wienermultiprime
 
 import (
-	"fmt"
+	"context"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -11,14 +12,24 @@ import (
 
 const name = "wiener multiprime"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "wienermultiprime",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostFast,
+		LargeE:     true,
+		F:          Attack,
+	})
+}
+
 // Attack implements the Wiener attack on an RSA public key where the modulus is composed of
 // more than 2 primes.
-func Attack(ts []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ts []*keys.RSA) *attacks.Result {
 	t := ts[0]
 	if t.Key.D != nil {
 		// Key already factored.
-		ch <- nil
-		return
+		return attacks.Solved(t)
 	}
 
 	// Encrypt something simple to validate our decryption later.
@@ -32,6 +43,10 @@ func Attack(ts []*keys.RSA, ch chan error) {
 
 	var r, s int64
 	for _, g := range convergants {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		q1 := g[1] // denominator
 
 		for r = 0; r < 20; r++ {
@@ -44,13 +59,12 @@ func Attack(ts []*keys.RSA, ch chan error) {
 				m1 := new(fmp.Fmpz).Pow(c, d, t.Key.N)
 				if m1.Cmp(m) == 0 {
 					t.PackGivenD(d)
-					ch <- nil
-					return
+					return attacks.Solved(t)
 				}
 			}
 			q0 = q0.Set(q1)
 		}
 	}
 
-	ch <- fmt.Errorf("%s attack failed", name)
+	return attacks.Failed("%s attack failed", name)
 }
@@ -3,8 +3,9 @@
 package wienervariant
 
 import (
-	"fmt"
+	"context"
 
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -15,11 +16,10 @@ import (
 const name = "wiener variant"
 
 // Attack performs a variant of the wiener attack by Andrej Dujella.
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 	k := ks[0]
 	if k.Key.D != nil {
-		ch <- nil
-		return
+		return attacks.Solved(k)
 	}
 
 	var (
@@ -31,6 +31,10 @@ func Attack(ks []*keys.RSA, ch chan error) {
 	convergants := ln.ConvergantsFromContfract(ln.RationalToContfract(k.Key.PublicKey.E, k.Key.N))
 
 	for _, c := range convergants {
+		if ctx.Err() != nil {
+			return attacks.Stopped(ctx.Err())
+		}
+
 		q1 := c[1]
 
 		for r := 0; r <= 30; r++ {
@@ -39,8 +43,7 @@ func Attack(ks []*keys.RSA, ch chan error) {
 				mMaybe := new(fmp.Fmpz).Exp(fakeC, d, k.Key.N)
 				if mMaybe.Equals(fakeM) {
 					k.PackGivenP(ln.FindPGivenD(d, k.Key.PublicKey.E, k.Key.N))
-					ch <- nil
-					return
+					return attacks.Solved(k)
 				}
 			}
 		}
@@ -48,5 +51,5 @@ func Attack(ks []*keys.RSA, ch chan error) {
 		q0.Set(q1)
 	}
 
-	ch <- fmt.Errorf("%s attack failed", name)
+	return attacks.Failed("%s attack failed", name)
 }
@@ -1,7 +1,9 @@
 package williamsp1
 
 import (
+	"context"
 	"github.com/jbarham/primegen"
+	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
 
@@ -11,14 +13,28 @@ import (
 // name is the name of this attack.
 const name = "william's p+1"
 
+func init() {
+	attacks.SupportedAttacks.Register(&attacks.Attack{
+		Name:       "williamsp1",
+		Unnatended: true,
+		Timeout:    attacks.DefaultTimeout,
+		Cost:       attacks.CostSlow,
+		F:          Attack,
+	})
+}
+
 // Attack performs williams P+1 factorization.
-func Attack(ks []*keys.RSA, ch chan error) {
+func Attack(ctx context.Context, ks []*keys.RSA) *attacks.Result {
 	k := ks[0]
 	p := primegen.New()
 	v := fmp.NewFmpz(0)
 	for {
 		v.Add(v, ln.BigOne)
 		for {
+			if ctx.Err() != nil {
+				return attacks.Stopped(ctx.Err())
+			}
+
 			pcursor := fmp.NewFmpz(int64(p.Next()))
 			e := ln.ILog(new(fmp.Fmpz).Set(k.Key.N).Root(k.Key.N, 2), pcursor)
 			if e.Equals(ln.BigZero) {
@@ -34,7 +50,7 @@ func Attack(ks []*keys.RSA, ch chan error) {
 			if g.Cmp(ln.BigOne) > 0 && g.Cmp(k.Key.N) < 0 {
 				// Found P.
 				k.PackGivenP(g)
-				ch <- nil
+				return attacks.Solved(k)
 			}
 
 			if g.Equals(k.Key.N) {
@@ -0,0 +1,162 @@
+package keys
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Certificate describes the X.509 certificate or PKCS#10 certificate request a key was taken from
+// so that reports can say which one was broken.
+type Certificate struct {
+	Subject string
+	// Issuer and Serial are empty for certificate requests.
+	Issuer  string
+	Serial  string
+	Request bool
+}
+
+// String returns a one line description of the certificate.
+func (c *Certificate) String() string {
+	if c.Request {
+		return fmt.Sprintf("certificate request subject=%q", c.Subject)
+	}
+
+	return fmt.Sprintf("certificate subject=%q issuer=%q serial=%s", c.Subject, c.Issuer, c.Serial)
+}
+
+// The structures below only go as deep as the public key so that keys the standard library
+// refuses, such as those with very large exponents, can still be read.
+
+type certificate struct {
+	TBSCertificate     tbsCertificate
+	SignatureAlgorithm asn1.RawValue
+	SignatureValue     asn1.BitString
+}
+
+type tbsCertificate struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm asn1.RawValue
+	Issuer             asn1.RawValue
+	Validity           asn1.RawValue
+	Subject            asn1.RawValue
+	PublicKey          asn1.RawValue
+	UniqueID           asn1.BitString `asn1:"optional,tag:1"`
+	SubjectUniqueID    asn1.BitString `asn1:"optional,tag:2"`
+	Extensions         asn1.RawValue  `asn1:"optional,explicit,tag:3"`
+}
+
+type certificateRequest struct {
+	Info               certificateRequestInfo
+	SignatureAlgorithm asn1.RawValue
+	SignatureValue     asn1.BitString
+}
+
+type certificateRequestInfo struct {
+	Raw        asn1.RawContent
+	Version    int
+	Subject    asn1.RawValue
+	PublicKey  asn1.RawValue
+	Attributes []asn1.RawValue `asn1:"tag:0"`
+}
+
+// parseName returns the string form of a DER encoded distinguished name.
+func parseName(der []byte) string {
+	var rdns pkix.RDNSequence
+	if _, err := asn1.Unmarshal(der, &rdns); err != nil {
+		return ""
+	}
+
+	var name pkix.Name
+	name.FillFromRDNSequence(&rdns)
+	return name.String()
+}
+
+// parseCertificate returns the RSA public key of the DER encoded certificate or certificate
+// request der.
+func parseCertificate(der []byte) (*RSA, error) {
+	var (
+		spki []byte
+		meta *Certificate
+	)
+
+	var cert certificate
+	if rest, err := asn1.Unmarshal(der, &cert); err == nil && len(rest) == 0 {
+		tbs := cert.TBSCertificate
+		spki = tbs.PublicKey.FullBytes
+		meta = &Certificate{
+			Subject: parseName(tbs.Subject.FullBytes),
+			Issuer:  parseName(tbs.Issuer.FullBytes),
+			Serial:  fmt.Sprintf("%x", tbs.SerialNumber),
+		}
+	} else {
+		var csr certificateRequest
+		if rest, err := asn1.Unmarshal(der, &csr); err != nil || len(rest) != 0 {
+			return nil, errors.New("not an X.509 certificate or certificate request")
+		}
+
+		spki = csr.Info.PublicKey.FullBytes
+		meta = &Certificate{Subject: parseName(csr.Info.Subject.FullBytes), Request: true}
+	}
+
+	pub, err := parsePublicRsaKey(spki)
+	if err != nil {
+		return nil, fmt.Errorf("%v does not hold an RSA key: %v", meta, err)
+	}
+
+	k, err := NewRSA(PrivateFromPublic(pub), nil, nil, "", false)
+	if err != nil {
+		return nil, err
+	}
+
+	k.Certificate = meta
+	return k, nil
+}
+
+// ImportCertificates imports the RSA public keys of X.509 certificates and PKCS#10 certificate
+// requests. kb is either DER or PEM which may hold a whole certificate chain, certificates with
+// other key types are skipped. The subject, issuer and serial number are kept in
+// RSA.Certificate.
+func ImportCertificates(kb []byte) ([]*RSA, error) {
+	block, rest := pem.Decode(kb)
+	if block == nil {
+		k, err := parseCertificate(kb)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*RSA{k}, nil
+	}
+
+	var (
+		ks   []*RSA
+		errs []error
+	)
+
+	for ; block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "CERTIFICATE", "CERTIFICATE REQUEST", "NEW CERTIFICATE REQUEST":
+		default:
+			continue
+		}
+
+		k, err := parseCertificate(block.Bytes)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ks = append(ks, k)
+	}
+
+	if len(ks) == 0 {
+		return nil, fmt.Errorf("no certificates with RSA keys found: %v", errs)
+	}
+
+	return ks, nil
+}
@@ -0,0 +1,174 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// ImportOptions holds the inputs that Import adds to every key it reads from a key file.
+type ImportOptions struct {
+	// Password decrypts an encrypted PEM or PKCS#8 private key.
+	Password []byte
+	// WordList is a file of candidate passwords, one per line, tried when an encrypted private key
+	// is given without a password.
+	WordList string
+	// KeyFilename names the keys in reports, numbered when the file holds more than one key.
+	KeyFilename string
+	// CipherText is the ciphertext for the keys. An OpenPGP message is replaced by the ciphertext
+	// of the session key encrypted to each key.
+	CipherText     []byte
+	DLSB           []byte
+	Prime          *fmp.Fmpz
+	Hints          []*fmp.Fmpz
+	BruteMax       int64
+	UnknownBytes   int
+	DKnownBits     int
+	PKnownBits     int
+	CRTMaxBits     int
+	FactorBaseSize int
+	NumPrimes      int
+	Workers        int
+	PastPrimesFile string
+	Verbose        bool
+	Log            *log.Logger
+}
+
+// Import returns every key in the key file kb with opts applied and true if kb was not a PEM or
+// DER private key, in which case the caller might want a PEM dump of the public key.
+func Import(kb []byte, opts *ImportOptions) ([]*RSA, bool, error) {
+	ks, nonPemKey, err := opts.importKeys(kb)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return opts.Apply(ks), nonPemKey, nil
+}
+
+// importKeys reads every key in kb trying each supported key format in turn.
+func (o *ImportOptions) importKeys(kb []byte) ([]*RSA, bool, error) {
+	k, err := ImportKeyWithPassword(kb, o.Password)
+	switch {
+	case err == nil:
+		return []*RSA{k}, false, nil
+	case errors.Is(err, ErrPasswordRequired) && o.WordList != "":
+		return o.crackKey(kb)
+	case errors.Is(err, ErrPasswordRequired), errors.Is(err, ErrIncorrectPassword), errors.Is(err, ErrOpenSSHKey):
+		// The key format was recognised so the other formats would only hide the real error.
+		return nil, false, err
+	}
+
+	// X.509 certificates, certificate chains and certificate requests.
+	if ks, err := ImportCertificates(kb); err == nil {
+		return ks, true, nil
+	}
+
+	// JSON Web Keys and JWK Sets.
+	if ks, err := ImportJWKs(kb); err == nil {
+		return ks, true, nil
+	}
+
+	// OpenPGP primary keys and subkeys.
+	if ks, err := ImportPGPKeys(kb); err == nil {
+		return ks, true, nil
+	}
+
+	// OpenSSH public keys, one or many in an authorized_keys file.
+	if ks, err := ImportAuthorizedKeys(kb); err == nil {
+		return ks, true, nil
+	}
+
+	// Failed to read a valid PEM or OpenSSH key. Maybe it is an integer list type key?
+	k, err = ImportIntegerList(kb)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return []*RSA{k}, true, nil
+}
+
+// crackKey brute forces the password of the encrypted private key kb with the WordList file.
+func (o *ImportOptions) crackKey(kb []byte) ([]*RSA, bool, error) {
+	f, err := os.Open(o.WordList)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed opening wordlist: %w", err)
+	}
+	defer f.Close()
+
+	k, pw, err := CrackKeyPassword(kb, f)
+	if err != nil {
+		return nil, false, err
+	}
+
+	o.logf("found the private key password: %q", pw)
+	return []*RSA{k}, false, nil
+}
+
+// Apply adds the options to each of ks, the keys read from one key file, and returns ks.
+func (o *ImportOptions) Apply(ks []*RSA) []*RSA {
+	for j, k := range ks {
+		k.KeyFilename = o.KeyFilename
+		if len(ks) > 1 {
+			k.KeyFilename = fmt.Sprintf("%s:%d", o.KeyFilename, j+1)
+		}
+
+		if o.CipherText != nil {
+			k.CipherText = o.cipherText(k)
+		}
+
+		if o.DLSB != nil {
+			k.DLSB = o.DLSB
+		}
+
+		if o.Prime != nil {
+			k.Key.Primes = append(k.Key.Primes, new(fmp.Fmpz).Set(o.Prime))
+		}
+
+		k.Hints = append(k.Hints, o.Hints...)
+		k.BruteMax = o.BruteMax
+		k.UnknownBytes = o.UnknownBytes
+		k.DKnownBits = o.DKnownBits
+		k.PKnownBits = o.PKnownBits
+		k.CRTMaxBits = o.CRTMaxBits
+		k.FactorBaseSize = o.FactorBaseSize
+		k.NumPrimes = o.NumPrimes
+		k.Workers = o.Workers
+		k.PastPrimesFile = o.PastPrimesFile
+		k.Verbose = o.Verbose
+		k.Log = o.Log
+	}
+
+	return ks
+}
+
+// cipherText returns the ciphertext for k, which is the ciphertext of the session key encrypted
+// to k when the ciphertext is an OpenPGP message or nil if the message has none for k.
+func (o *ImportOptions) cipherText(k *RSA) []byte {
+	if !IsPGPMessage(o.CipherText) {
+		return o.CipherText
+	}
+
+	var keyID string
+	if k.PGP != nil {
+		keyID = k.PGP.KeyID
+	}
+
+	// The message is not an RSA ciphertext so its raw bytes are no use to the attacks.
+	c, err := PGPCipherText(o.CipherText, keyID)
+	if err != nil {
+		o.logf("%s: no ciphertext for this key: %v", k.KeyFilename, err)
+		return nil
+	}
+
+	return c
+}
+
+// logf logs to Log when it is set.
+func (o *ImportOptions) logf(format string, v ...interface{}) {
+	if o.Log != nil {
+		o.Log.Printf(format, v...)
+	}
+}
@@ -0,0 +1,211 @@
+package keys
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sourcekris/goRsaTool/ln"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// jwkRSA is the key type of RSA JSON Web Keys.
+const jwkRSA = "RSA"
+
+// jwkPrime is one of the additional primes of a multi-prime JWK from RFC 7518 section 6.3.2.7.
+type jwkPrime struct {
+	R string `json:"r"`
+	D string `json:"d"`
+	T string `json:"t"`
+}
+
+// jwk holds the members of an RSA JSON Web Key from RFC 7517 and RFC 7518, every integer is
+// encoded as unpadded base64url big endian bytes.
+type jwk struct {
+	Kty string     `json:"kty"`
+	Kid string     `json:"kid,omitempty"`
+	Use string     `json:"use,omitempty"`
+	Alg string     `json:"alg,omitempty"`
+	N   string     `json:"n"`
+	E   string     `json:"e"`
+	D   string     `json:"d,omitempty"`
+	P   string     `json:"p,omitempty"`
+	Q   string     `json:"q,omitempty"`
+	Dp  string     `json:"dp,omitempty"`
+	Dq  string     `json:"dq,omitempty"`
+	Qi  string     `json:"qi,omitempty"`
+	Oth []jwkPrime `json:"oth,omitempty"`
+}
+
+// jwkSet is a JWK Set such as a /.well-known/jwks.json document.
+type jwkSet struct {
+	Keys []json.RawMessage `json:"keys"`
+}
+
+// jwkInt decodes the base64url integer s, padding is tolerated although the RFC forbids it.
+func jwkInt(s string) (*fmp.Fmpz, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding jwk integer: %v", err)
+	}
+
+	return new(fmp.Fmpz).SetBytes(b), nil
+}
+
+// jwkEncode encodes z as an unpadded base64url integer.
+func jwkEncode(z *fmp.Fmpz) string {
+	return base64.RawURLEncoding.EncodeToString(z.Bytes())
+}
+
+// parseJWK returns the RSA key held in the JWK j including the private key when it has one.
+func parseJWK(j *jwk) (*RSA, error) {
+	if j.N == "" || j.E == "" {
+		return nil, errors.New("jwk is missing the modulus or exponent")
+	}
+
+	n, err := jwkInt(j.N)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := jwkInt(j.E)
+	if err != nil {
+		return nil, err
+	}
+
+	key := PrivateFromPublic(&FMPPublicKey{N: n, E: e})
+	if j.D != "" {
+		if key.D, err = jwkInt(j.D); err != nil {
+			return nil, err
+		}
+
+		ps := []string{j.P, j.Q}
+		for _, o := range j.Oth {
+			ps = append(ps, o.R)
+		}
+
+		for _, s := range ps {
+			if s == "" {
+				continue
+			}
+
+			p, err := jwkInt(s)
+			if err != nil {
+				return nil, err
+			}
+
+			key.Primes = append(key.Primes, p)
+		}
+	}
+
+	k, err := NewRSA(key, nil, nil, "", false)
+	if err != nil {
+		return nil, err
+	}
+
+	k.KeyID = j.Kid
+	return k, nil
+}
+
+// ImportJWKs imports the RSA keys of a JSON Web Key or of every key in a JWK Set, keys of other
+// types are skipped. The key id is kept in RSA.KeyID and private keys are imported with their
+// primes.
+func ImportJWKs(kb []byte) ([]*RSA, error) {
+	var set jwkSet
+	if err := json.Unmarshal(kb, &set); err != nil {
+		return nil, fmt.Errorf("failed decoding jwk: %v", err)
+	}
+
+	raw := set.Keys
+	if raw == nil {
+		raw = []json.RawMessage{kb}
+	}
+
+	var ks []*RSA
+	for _, r := range raw {
+		var j jwk
+		if err := json.Unmarshal(r, &j); err != nil {
+			return nil, fmt.Errorf("failed decoding jwk: %v", err)
+		}
+
+		if j.Kty != jwkRSA {
+			continue
+		}
+
+		k, err := parseJWK(&j)
+		if err != nil {
+			return nil, err
+		}
+
+		ks = append(ks, k)
+	}
+
+	if len(ks) == 0 {
+		return nil, errors.New("no RSA json web keys found")
+	}
+
+	return ks, nil
+}
+
+// EncodeFMPPrivateKeyJWK marshalls an RSA private key using FMP types into a JWK with the key id
+// kid, which may be empty. The CRT members dp, dq and qi are computed from d and the primes and
+// primes beyond the second are written to the oth member.
+func EncodeFMPPrivateKeyJWK(priv *FMPPrivateKey, kid string) (string, error) {
+	if priv.D == nil || len(priv.Primes) < 2 {
+		return "", errors.New("a jwk private key needs d and at least two primes")
+	}
+
+	// The CRT exponents are reduced modulo each prime less one so the primes must be sound.
+	n := fmp.NewFmpz(1)
+	for _, pr := range priv.Primes {
+		if pr.Cmp(ln.BigOne) <= 0 {
+			return "", fmt.Errorf("a jwk private key needs primes greater than 1, got %v", pr)
+		}
+
+		n.Mul(n, pr)
+	}
+
+	if !n.Equals(priv.PublicKey.N) {
+		return "", errors.New("a jwk private key needs primes that multiply to the modulus")
+	}
+
+	var (
+		p, q = priv.Primes[0], priv.Primes[1]
+		crt  = func(x *fmp.Fmpz) *fmp.Fmpz {
+			return new(fmp.Fmpz).Mod(priv.D, new(fmp.Fmpz).Sub(x, ln.BigOne))
+		}
+	)
+
+	j := &jwk{
+		Kty: jwkRSA,
+		Kid: kid,
+		N:   jwkEncode(priv.PublicKey.N),
+		E:   jwkEncode(priv.PublicKey.E),
+		D:   jwkEncode(priv.D),
+		P:   jwkEncode(p),
+		Q:   jwkEncode(q),
+		Dp:  jwkEncode(crt(p)),
+		Dq:  jwkEncode(crt(q)),
+		Qi:  jwkEncode(new(fmp.Fmpz).ModInverse(q, p)),
+	}
+
+	r := new(fmp.Fmpz).Mul(p, q)
+	for _, pr := range priv.Primes[2:] {
+		j.Oth = append(j.Oth, jwkPrime{
+			R: jwkEncode(pr),
+			D: jwkEncode(crt(pr)),
+			T: jwkEncode(new(fmp.Fmpz).ModInverse(r, pr)),
+		})
+		r.Mul(r, pr)
+	}
+
+	b, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
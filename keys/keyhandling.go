@@ -16,7 +16,7 @@ import (
 
 var (
 	// lineRE is a regexp that should match interesting integers on lines.
-	lineRE = regexp.MustCompile(`(?i)^([necpqdk][pq02349]?t?|)\s*[:=]\s*((?:0x)?[0-9a-f]+)`)
+	lineRE = regexp.MustCompile(`(?i)^([necpqdk][pq02349]?t?|(?:p|dp?)_?(?:high|low)|)\s*[:=]\s*((?:0x)?[0-9a-f]+)`)
 	// numRE matches numbers in base 10 or hex.
 	numRE = regexp.MustCompile(`[0-9a-f]+`)
 	// modRE, expRE, ctRE matches 'n', 'e', 'c' case insensitively.
@@ -28,7 +28,16 @@ var (
 	kptRE = regexp.MustCompile(`(?i)^kpt`)
 
 	// d0RE is the LSB of d regexp.
-	d0RE = regexp.MustCompile(`(?i)^d0`)
+	d0RE = regexp.MustCompile(`(?i)^(?:d0|d_?low)`)
+
+	// dHighRE, dpHighRE and dpLowRE are the known MSBs of d and the MSBs and LSBs of dp regexps.
+	dHighRE  = regexp.MustCompile(`(?i)^d_?high`)
+	dpHighRE = regexp.MustCompile(`(?i)^dp_?high`)
+	dpLowRE  = regexp.MustCompile(`(?i)^dp_?low`)
+
+	// pHighRE and pLowRE are the known MSBs and LSBs of p regexps.
+	pHighRE = regexp.MustCompile(`(?i)^p_?high`)
+	pLowRE  = regexp.MustCompile(`(?i)^p_?low`)
 
 	// CRT components regexps.
 	pRE  = regexp.MustCompile(`(?i)^p`)
@@ -70,13 +79,29 @@ func parsePublicRsaKey(keyBytes []byte) (*FMPPublicKey, error) {
 	return nil, fmt.Errorf("parsePublicRsaKey failed: %v", errs)
 }
 
+type skParser func([]byte) (*x509big.BigPrivateKey, error)
+
+// parseBigPrivateRsaKey attempts to parse the given PKCS#1 or PKCS#8 private key yielding a
+// FMPPrivateKey or an error.
 func parseBigPrivateRsaKey(keyBytes []byte) (*FMPPrivateKey, error) {
-	key, err := x509big.ParseBigPKCS1PrivateKey(keyBytes)
-	if err != nil {
-		return nil, fmt.Errorf("parseBigPrivateRsaKey: failed to parse the DER key after decoding: %v", err)
+	var (
+		parsers = []skParser{
+			x509big.ParseBigPKCS1PrivateKey,
+			parseBigPKCS8PrivateKey,
+		}
+		errs []error
+	)
+
+	for _, p := range parsers {
+		if key, err := p(keyBytes); err != nil {
+			errs = append(errs, err)
+		} else {
+			k := BigtoFMPPrivateKey(key)
+			return &k, nil
+		}
 	}
-	k := BigtoFMPPrivateKey(key)
-	return &k, nil
+
+	return nil, fmt.Errorf("parseBigPrivateRsaKey: failed to parse the DER key after decoding: %v", errs)
 }
 
 // PrivateFromPublic takes a Public Key and return a Private Key with the public components packed.
@@ -117,6 +142,8 @@ func getBase(s string) (string, int) {
 func ImportIntegerList(kb []byte) (*RSA, error) {
 	var (
 		n, e, c, p, q, dp, dq, d0 string
+		pHigh, pLow               string
+		dHigh, dpHigh, dpLow      string
 		ct, kpt                   []byte
 		crt                       bool
 		os                        map[int]*fmp.Fmpz
@@ -139,10 +166,20 @@ func ImportIntegerList(kb []byte) (*RSA, error) {
 					e = sm[2]
 				case ctRE.MatchString(sm[1]) && numRE.MatchString(sm[2]):
 					c = sm[2]
+				case pHighRE.MatchString(sm[1]) && numRE.MatchString(sm[2]):
+					pHigh = sm[2]
+				case pLowRE.MatchString(sm[1]) && numRE.MatchString(sm[2]):
+					pLow = sm[2]
 				case pRE.MatchString(sm[1]) && numRE.MatchString(sm[2]):
 					p = sm[2]
 				case qRE.MatchString(sm[1]) && numRE.MatchString(sm[2]):
 					q = sm[2]
+				case dHighRE.MatchString(sm[1]) && numRE.MatchString(sm[2]):
+					dHigh = sm[2]
+				case dpHighRE.MatchString(sm[1]) && numRE.MatchString(sm[2]):
+					dpHigh = sm[2]
+				case dpLowRE.MatchString(sm[1]) && numRE.MatchString(sm[2]):
+					dpLow = sm[2]
 				case dpRE.MatchString(sm[1]) && numRE.MatchString(sm[2]):
 					dp = sm[2]
 				case dqRE.MatchString(sm[1]) && numRE.MatchString(sm[2]):
@@ -256,6 +293,30 @@ func ImportIntegerList(kb []byte) (*RSA, error) {
 		k.KnownPlainText = kpt
 	}
 
+	// Keep the CRT exponents when we got some but not enough for the CRT solution.
+	if dp != "" || dq != "" {
+		k.Key.Precomputed = &PrecomputedValues{}
+		for _, f := range []struct {
+			name string
+			val  string
+			dst  **fmp.Fmpz
+		}{
+			{"dp", dp, &k.Key.Precomputed.Dp},
+			{"dq", dq, &k.Key.Precomputed.Dq},
+		} {
+			if f.val == "" {
+				continue
+			}
+
+			v, ok := new(fmp.Fmpz).SetString(getBase(f.val))
+			if !ok {
+				return nil, fmt.Errorf("failed decoding %s from keyfile", f.name)
+			}
+
+			*f.dst = v
+		}
+	}
+
 	if len(os) == 4 {
 		k.OracleCiphertexts = os
 	}
@@ -270,6 +331,47 @@ func ImportIntegerList(kb []byte) (*RSA, error) {
 		k.DLSB = ln.NumberToBytes(fd0)
 	}
 
+	// Place the known MSBs of d and the MSBs or LSBs of dp into their fields.
+	for _, f := range []struct {
+		name string
+		val  string
+		dst  **fmp.Fmpz
+	}{
+		{"d_high", dHigh, &k.DHigh},
+		{"dp_high", dpHigh, &k.DpHigh},
+		{"dp_low", dpLow, &k.DpLow},
+	} {
+		if f.val == "" {
+			continue
+		}
+
+		v, ok := new(fmp.Fmpz).SetString(getBase(f.val))
+		if !ok {
+			return nil, fmt.Errorf("failed decoding %s from keyfile", f.name)
+		}
+
+		*f.dst = v
+	}
+
+	// Place the known MSBs or LSBs of p into the k.PHigh and k.PLow fields.
+	if pHigh != "" {
+		fPHigh, ok := new(fmp.Fmpz).SetString(getBase(pHigh))
+		if !ok {
+			return nil, errors.New("failed decoding p_high from keyfile")
+		}
+
+		k.PHigh = fPHigh
+	}
+
+	if pLow != "" {
+		fPLow, ok := new(fmp.Fmpz).SetString(getBase(pLow))
+		if !ok {
+			return nil, errors.New("failed decoding p_low from keyfile")
+		}
+
+		k.PLow = fPLow
+	}
+
 	// Add the primes if we got any.
 	if p != "" {
 		fP, ok := new(fmp.Fmpz).SetString(getBase(p))
@@ -292,21 +394,47 @@ func ImportIntegerList(kb []byte) (*RSA, error) {
 
 // ImportKey imports a PEM key file and returns a RSA object or error.
 func ImportKey(kb []byte) (*RSA, error) {
+	return ImportKeyWithPassword(kb, nil)
+}
+
+// ImportKeyWithPassword imports a PEM key file which may be an encrypted PKCS#8 or legacy
+// Proc-Type encrypted private key, decrypting it with password. ErrPasswordRequired is returned
+// for encrypted keys when the password is empty.
+func ImportKeyWithPassword(kb, password []byte) (*RSA, error) {
 	// Decode the PEM data to extract the DER format key.
 	block, _ := pem.Decode(kb)
 	if block == nil {
 		return nil, errors.New("failed to decode PEM key")
 	}
 
+	if block.Type == opensshPEMType {
+		priv, err := parseOpenSSHPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("ImportKey: %w: %v", ErrOpenSSHKey, err)
+		}
+
+		return NewRSA(priv, nil, nil, "", false)
+	}
+
+	der, err := decryptBlock(block, password)
+	if err != nil {
+		return nil, err
+	}
+
 	// Try as a private key first.
-	priv, err := parseBigPrivateRsaKey(block.Bytes)
+	priv, err := parseBigPrivateRsaKey(der)
 	if err == nil {
 		// If there was an error, try to parse it an alternative way below.
 		return NewRSA(priv, nil, nil, "", false)
 	}
 
+	if isEncrypted(block) {
+		// Legacy encryption has no integrity check so a wrong password may decrypt to garbage.
+		return nil, ErrIncorrectPassword
+	}
+
 	// Extract a FMPPublicKey from the DER decoded data and pack a private key struct.
-	key, err := parsePublicRsaKey(block.Bytes)
+	key, err := parsePublicRsaKey(der)
 	if err != nil {
 		return nil, fmt.Errorf("ImportKey: failed to parse the key as either a public or private key: %v", err)
 	}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"math/big"
+	"runtime"
 
 	"github.com/sourcekris/goRsaTool/ln"
 	"github.com/sourcekris/x509big"
@@ -18,14 +19,29 @@ type RSA struct {
 	Key               FMPPrivateKey
 	CipherText        []byte
 	PlainText         []byte
+	PlainTexts        [][]byte
 	KnownPlainText    []byte
 	DLSB              []byte
+	DHigh             *fmp.Fmpz
+	DpHigh            *fmp.Fmpz
+	DpLow             *fmp.Fmpz
+	DKnownBits        int
+	PHigh             *fmp.Fmpz
+	PLow              *fmp.Fmpz
+	PKnownBits        int
+	CRTMaxBits        int
+	FactorBaseSize    int
 	OracleCiphertexts map[int]*fmp.Fmpz
 	Hints             []*fmp.Fmpz
 	BruteMax          int64
+	UnknownBytes      int
 	KeyFilename       string
+	Certificate       *Certificate
+	KeyID             string
+	PGP               *PGPKey
 	PastPrimesFile    string
 	NumPrimes         int
+	Workers           int
 	Verbose           bool
 	Log               *log.Logger
 }
@@ -51,12 +67,92 @@ func NewRSA(key *FMPPrivateKey, c []byte, m []byte, pf string, v bool) (*RSA, er
 	}, nil
 }
 
+// NumWorkers returns how many goroutines an attack that splits up its work may use, which is
+// Workers or one per CPU if Workers is less than 1.
+func (t *RSA) NumWorkers() int {
+	if t.Workers < 1 {
+		return runtime.NumCPU()
+	}
+
+	return t.Workers
+}
+
+// Copy returns a deep copy of t so that it can be attacked independently of the original.
+func (t *RSA) Copy() *RSA {
+	c := *t
+	c.Key = t.Key.copy()
+	c.CipherText = copyBytes(t.CipherText)
+	c.PlainText = copyBytes(t.PlainText)
+	c.KnownPlainText = copyBytes(t.KnownPlainText)
+	c.DLSB = copyBytes(t.DLSB)
+	c.DHigh = copyFmpz(t.DHigh)
+	c.DpHigh = copyFmpz(t.DpHigh)
+	c.DpLow = copyFmpz(t.DpLow)
+	c.PHigh = copyFmpz(t.PHigh)
+	c.PLow = copyFmpz(t.PLow)
+	c.Hints = copyFmpzs(t.Hints)
+
+	if t.PlainTexts != nil {
+		c.PlainTexts = make([][]byte, len(t.PlainTexts))
+		for i, m := range t.PlainTexts {
+			c.PlainTexts[i] = copyBytes(m)
+		}
+	}
+
+	if t.Certificate != nil {
+		cert := *t.Certificate
+		c.Certificate = &cert
+	}
+
+	if t.PGP != nil {
+		pk := *t.PGP
+		c.PGP = &pk
+	}
+
+	if t.OracleCiphertexts != nil {
+		c.OracleCiphertexts = make(map[int]*fmp.Fmpz, len(t.OracleCiphertexts))
+		for k, v := range t.OracleCiphertexts {
+			c.OracleCiphertexts[k] = copyFmpz(v)
+		}
+	}
+
+	return &c
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+
+	return append([]byte{}, b...)
+}
+
+func copyFmpz(z *fmp.Fmpz) *fmp.Fmpz {
+	if z == nil {
+		return nil
+	}
+
+	return new(fmp.Fmpz).Set(z)
+}
+
+func copyFmpzs(zs []*fmp.Fmpz) []*fmp.Fmpz {
+	if zs == nil {
+		return nil
+	}
+
+	c := make([]*fmp.Fmpz, len(zs))
+	for i, z := range zs {
+		c[i] = copyFmpz(z)
+	}
+
+	return c
+}
+
 // PackGivenP takes one prime p and packs the Key member of the RSA struct with the private
 // key values, p, q & d as well as the Plaintext if a Ciphertext was given.
 func (t *RSA) PackGivenP(p *fmp.Fmpz) {
 	q := new(fmp.Fmpz).Div(t.Key.N, p)
 	t.Key.Primes = []*fmp.Fmpz{p, q}
-	t.Key.D = ln.SolveforD(p, q, t.Key.PublicKey.E)
 
 	if t.Verbose {
 		for i, prime := range t.Key.Primes {
@@ -64,6 +160,13 @@ func (t *RSA) PackGivenP(p *fmp.Fmpz) {
 		}
 	}
 
+	if t.IsRabin() {
+		t.packRabin(t.Key.Primes)
+		return
+	}
+
+	t.Key.D = ln.SolveforD(p, q, t.Key.PublicKey.E)
+
 	// Pack the Plaintext if a Ciphertext was provided.
 	if t.CipherText != nil && t.PlainText == nil {
 		t.PlainText = ln.NumberToBytes(new(fmp.Fmpz).Exp(ln.BytesToNumber(t.CipherText), t.Key.D, t.Key.PublicKey.N))
@@ -87,6 +190,11 @@ func (t *RSA) PackMultiPrime(primes []*fmp.Fmpz) error {
 		return fmt.Errorf("product of primes does not equal N")
 	}
 
+	if t.IsRabin() {
+		t.packRabin(primes)
+		return nil
+	}
+
 	t.Key.Primes = primes
 	t.Key.D = new(fmp.Fmpz).ModInverse(t.Key.PublicKey.E, cp)
 
@@ -114,6 +222,18 @@ func (t *RSA) String() string {
 	res = fmt.Sprintf("%s:\nn = %s\n", t.KeyFilename, t.Key.PublicKey.N)
 	res = fmt.Sprintf("%se = %s\n", res, t.Key.PublicKey.E)
 
+	if t.Certificate != nil {
+		res = fmt.Sprintf("%s%v\n", res, t.Certificate)
+	}
+
+	if t.KeyID != "" {
+		res = fmt.Sprintf("%skid = %s\n", res, t.KeyID)
+	}
+
+	if t.PGP != nil {
+		res = fmt.Sprintf("%s%v\n", res, t.PGP)
+	}
+
 	if t.Key.D != nil {
 		res = fmt.Sprintf("%sd = %s\n", res, t.Key.D)
 		if len(t.Key.Primes) == 2 {
@@ -174,6 +294,40 @@ type FMPPrivateKey struct {
 	Precomputed *PrecomputedValues
 }
 
+// copy returns a deep copy of k.
+func (k FMPPrivateKey) copy() FMPPrivateKey {
+	c := FMPPrivateKey{
+		D:      copyFmpz(k.D),
+		Primes: copyFmpzs(k.Primes),
+		N:      copyFmpz(k.N),
+	}
+
+	if k.PublicKey != nil {
+		c.PublicKey = &FMPPublicKey{N: copyFmpz(k.PublicKey.N), E: copyFmpz(k.PublicKey.E)}
+		// Keep N shared between the private and public key as PrivateFromPublic does.
+		if k.N == k.PublicKey.N {
+			c.N = c.PublicKey.N
+		}
+	}
+
+	if k.Precomputed != nil {
+		c.Precomputed = &PrecomputedValues{
+			Dp:   copyFmpz(k.Precomputed.Dp),
+			Dq:   copyFmpz(k.Precomputed.Dq),
+			Qinv: copyFmpz(k.Precomputed.Qinv),
+		}
+		for _, v := range k.Precomputed.CRTValues {
+			c.Precomputed.CRTValues = append(c.Precomputed.CRTValues, CRTValue{
+				Exp:   copyFmpz(v.Exp),
+				Coeff: copyFmpz(v.Coeff),
+				R:     copyFmpz(v.R),
+			})
+		}
+	}
+
+	return c
+}
+
 // BigtoFMPPrivateKey takes a x509big.BigPrivateKey and returns a FMPPrivateKey that uses fmp.Fmpz types
 func BigtoFMPPrivateKey(key *x509big.BigPrivateKey) FMPPrivateKey {
 	fmpPubKey := &FMPPublicKey{
@@ -185,6 +339,7 @@ func BigtoFMPPrivateKey(key *x509big.BigPrivateKey) FMPPrivateKey {
 	if key.D != nil {
 		fmpPrivateKey = &FMPPrivateKey{
 			PublicKey: fmpPubKey,
+			N:         fmpPubKey.N,
 			D:         new(fmp.Fmpz).SetBytes(key.D.Bytes()),
 		}
 
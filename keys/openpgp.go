@@ -0,0 +1,630 @@
+package keys
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// OpenPGP packet tags and public key algorithms from RFC 4880.
+const (
+	pgpTagPKESK        = 1
+	pgpTagSecretKey    = 5
+	pgpTagPublicKey    = 6
+	pgpTagSecretSubkey = 7
+	pgpTagUserID       = 13
+	pgpTagPublicSubkey = 14
+
+	pgpAlgoRSA        = 1
+	pgpAlgoRSAEncrypt = 2
+	pgpAlgoRSASign    = 3
+)
+
+// pgpDummyS2K marks a secret key packet without the secret parts, the GnuPG extension used by
+// gpg --export-secret-subkeys. It is the s2k usage, cipher, s2k mode 101, hash and "GNU" mode 1.
+var pgpDummyS2K = []byte{0xff, 0, 101, 0, 'G', 'N', 'U', 1}
+
+// pgpSessionKeySizes are the key sizes of the symmetric algorithms a session key may be for.
+var pgpSessionKeySizes = map[int]int{
+	2: 24, 3: 16, 4: 16, 7: 16, 8: 24, 9: 32, 10: 32, 11: 16, 12: 24, 13: 32,
+}
+
+// errNotRSA is returned for OpenPGP keys of other algorithms which are skipped.
+var errNotRSA = errors.New("not an RSA OpenPGP key")
+
+// PGPKey describes the OpenPGP key or subkey a key was taken from so that a secret key can be
+// exported once it is broken.
+type PGPKey struct {
+	KeyID  string
+	UserID string
+	Subkey bool
+
+	fingerprint []byte
+	// transferable is every packet of the transferable public key the key belongs to.
+	transferable []byte
+}
+
+// String returns a one line description of the OpenPGP key.
+func (k *PGPKey) String() string {
+	kind := "key"
+	if k.Subkey {
+		kind = "subkey"
+	}
+
+	return fmt.Sprintf("pgp %s %s user id %q", kind, k.KeyID, k.UserID)
+}
+
+// crc24 is the checksum of ASCII armored data from RFC 4880 section 6.1.
+func crc24(b []byte) uint32 {
+	crc := uint32(0xb704ce)
+	for _, c := range b {
+		crc ^= uint32(c) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= 0x1864cfb
+			}
+		}
+	}
+
+	return crc & 0xffffff
+}
+
+// pgpDearmor returns the binary data and the type of ASCII armored OpenPGP data, or b and an empty
+// type if b is not armored.
+func pgpDearmor(b []byte) ([]byte, string, error) {
+	start := bytes.Index(b, []byte("-----BEGIN PGP "))
+	if start < 0 {
+		return b, "", nil
+	}
+
+	var (
+		s       = bufio.NewScanner(bytes.NewReader(b[start:]))
+		typ     string
+		body    strings.Builder
+		sum     string
+		headers = true
+	)
+
+	s.Scan()
+	typ = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s.Text()), "-----BEGIN "), "-----")
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case strings.HasPrefix(line, "-----END "):
+			der, err := base64.StdEncoding.DecodeString(body.String())
+			if err != nil {
+				return nil, "", fmt.Errorf("failed decoding armored %s: %v", typ, err)
+			}
+
+			if sum != "" {
+				want, err := base64.StdEncoding.DecodeString(sum)
+				if err != nil || len(want) != 3 || crc24(der) != uint32(want[0])<<16|uint32(want[1])<<8|uint32(want[2]) {
+					return nil, "", fmt.Errorf("armored %s has a bad checksum", typ)
+				}
+			}
+
+			return der, typ, nil
+		case headers && strings.Contains(line, ": "):
+		case line == "":
+			headers = false
+		case strings.HasPrefix(line, "=") && len(line) == 5:
+			sum = line[1:]
+		default:
+			headers = false
+			body.WriteString(line)
+		}
+	}
+
+	return nil, "", fmt.Errorf("armored %s has no end line", typ)
+}
+
+// pgpArmor returns b ASCII armored with the type typ.
+func pgpArmor(typ string, b []byte) string {
+	var res strings.Builder
+	fmt.Fprintf(&res, "-----BEGIN %s-----\n\n", typ)
+
+	enc := base64.StdEncoding.EncodeToString(b)
+	for len(enc) > 64 {
+		fmt.Fprintln(&res, enc[:64])
+		enc = enc[64:]
+	}
+
+	crc := crc24(b)
+	fmt.Fprintln(&res, enc)
+	fmt.Fprintf(&res, "=%s\n", base64.StdEncoding.EncodeToString([]byte{byte(crc >> 16), byte(crc >> 8), byte(crc)}))
+	fmt.Fprintf(&res, "-----END %s-----\n", typ)
+
+	return res.String()
+}
+
+// pgpPacket is an OpenPGP packet, raw is the whole packet including the header.
+type pgpPacket struct {
+	tag  int
+	body []byte
+	raw  []byte
+}
+
+// readPGPPackets splits b into OpenPGP packets in either the old or the new format.
+func readPGPPackets(b []byte) ([]pgpPacket, error) {
+	var ps []pgpPacket
+	for rest := b; len(rest) > 0; {
+		ctb := rest[0]
+		if ctb&0x80 == 0 {
+			return nil, errors.New("not an OpenPGP packet")
+		}
+
+		var (
+			p   = pgpPacket{}
+			off = 1
+		)
+
+		if ctb&0x40 == 0 {
+			// Old format, the length type is in the low bits of the tag octet.
+			p.tag = int(ctb>>2) & 0xf
+			var l int
+			switch lt := ctb & 3; {
+			case lt == 3:
+				l = len(rest) - 1
+			case len(rest) < 1+1<<lt:
+				return nil, errors.New("OpenPGP packet is truncated")
+			default:
+				for i := 0; i < 1<<lt; i++ {
+					l = l<<8 | int(rest[1+i])
+				}
+				off += 1 << lt
+			}
+
+			if len(rest) < off+l {
+				return nil, errors.New("OpenPGP packet is truncated")
+			}
+
+			p.body = rest[off : off+l]
+			off += l
+		} else {
+			// New format, the body may be split into partial lengths.
+			p.tag = int(ctb & 0x3f)
+			for {
+				if len(rest) <= off {
+					return nil, errors.New("OpenPGP packet is truncated")
+				}
+
+				var (
+					l       int
+					partial bool
+				)
+
+				switch o := int(rest[off]); {
+				case o < 192:
+					l, off = o, off+1
+				case o < 224:
+					if len(rest) < off+2 {
+						return nil, errors.New("OpenPGP packet is truncated")
+					}
+					l, off = (o-192)<<8+int(rest[off+1])+192, off+2
+				case o == 255:
+					if len(rest) < off+5 {
+						return nil, errors.New("OpenPGP packet is truncated")
+					}
+					l, off = int(binary.BigEndian.Uint32(rest[off+1:])), off+5
+				default:
+					l, off, partial = 1<<(o&0x1f), off+1, true
+				}
+
+				if l < 0 || len(rest) < off+l {
+					return nil, errors.New("OpenPGP packet is truncated")
+				}
+
+				p.body = append(p.body, rest[off:off+l]...)
+				off += l
+				if !partial {
+					break
+				}
+			}
+		}
+
+		p.raw = rest[:off]
+		rest = rest[off:]
+		ps = append(ps, p)
+	}
+
+	return ps, nil
+}
+
+// appendPGPPacket appends a new format packet with the tag and body to b.
+func appendPGPPacket(b []byte, tag int, body []byte) []byte {
+	b = append(b, 0xc0|byte(tag))
+	switch l := len(body); {
+	case l < 192:
+		b = append(b, byte(l))
+	case l < 8384:
+		l -= 192
+		b = append(b, byte(l>>8+192), byte(l))
+	default:
+		b = append(b, 255)
+		b = binary.BigEndian.AppendUint32(b, uint32(l))
+	}
+
+	return append(b, body...)
+}
+
+// readMPI reads an OpenPGP multiprecision integer, the bit count followed by the bytes.
+func readMPI(b []byte) (*fmp.Fmpz, []byte, error) {
+	if len(b) < 2 {
+		return nil, nil, errors.New("OpenPGP integer is truncated")
+	}
+
+	l := (int(binary.BigEndian.Uint16(b)) + 7) / 8
+	if len(b) < 2+l {
+		return nil, nil, errors.New("OpenPGP integer is truncated")
+	}
+
+	return new(fmp.Fmpz).SetBytes(b[2 : 2+l]), b[2+l:], nil
+}
+
+// appendMPI appends z as an OpenPGP multiprecision integer to b.
+func appendMPI(b []byte, z *fmp.Fmpz) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(z.BitLen()))
+	return append(b, z.Bytes()...)
+}
+
+// parsePGPPublicKey returns the RSA public key in the body of a version 4 public key packet and
+// the length of the public part, which a secret key packet goes on from.
+func parsePGPPublicKey(body []byte) (*FMPPublicKey, int, error) {
+	if len(body) < 6 || body[0] != 4 {
+		return nil, 0, errors.New("only version 4 OpenPGP keys are supported")
+	}
+
+	switch body[5] {
+	case pgpAlgoRSA, pgpAlgoRSAEncrypt, pgpAlgoRSASign:
+	default:
+		return nil, 0, errNotRSA
+	}
+
+	n, rest, err := readMPI(body[6:])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	e, rest, err := readMPI(rest)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &FMPPublicKey{N: n, E: e}, len(body) - len(rest), nil
+}
+
+// pgpFingerprint is the version 4 fingerprint of the public key packet body pub.
+func pgpFingerprint(pub []byte) []byte {
+	h := sha1.New()
+	h.Write([]byte{0x99, byte(len(pub) >> 8), byte(len(pub))})
+	h.Write(pub)
+	return h.Sum(nil)
+}
+
+// parsePGPSecret reads the unencrypted secret parts d, p and q that follow the public key in a
+// secret key packet, false is returned when they are encrypted or missing.
+func parsePGPSecret(b []byte) (*fmp.Fmpz, []*fmp.Fmpz, bool) {
+	if len(b) < 1 || b[0] != 0 {
+		return nil, nil, false
+	}
+
+	d, rest, err := readMPI(b[1:])
+	if err != nil {
+		return nil, nil, false
+	}
+
+	p, rest, err := readMPI(rest)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	q, _, err := readMPI(rest)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	return d, []*fmp.Fmpz{p, q}, true
+}
+
+// ImportPGPKeys imports the RSA primary keys and subkeys of binary or ASCII armored OpenPGP keys,
+// which may be a whole keyring. Keys of other algorithms are skipped and unencrypted secret keys
+// are imported with their primes. The key id and user id are kept in RSA.PGP.
+func ImportPGPKeys(kb []byte) ([]*RSA, error) {
+	b, _, err := pgpDearmor(kb)
+	if err != nil {
+		return nil, err
+	}
+
+	ps, err := readPGPPackets(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		ks, group    []*RSA
+		transferable []byte
+		userID       string
+	)
+
+	flush := func() {
+		for _, k := range group {
+			k.PGP.UserID = userID
+			k.PGP.transferable = transferable
+		}
+
+		ks = append(ks, group...)
+		group, transferable, userID = nil, nil, ""
+	}
+
+	for _, p := range ps {
+		if p.tag == pgpTagPublicKey || p.tag == pgpTagSecretKey {
+			flush()
+		}
+
+		transferable = append(transferable, p.raw...)
+
+		switch p.tag {
+		case pgpTagUserID:
+			if userID == "" {
+				userID = string(p.body)
+			}
+			continue
+		case pgpTagPublicKey, pgpTagSecretKey, pgpTagPublicSubkey, pgpTagSecretSubkey:
+		default:
+			continue
+		}
+
+		pub, l, err := parsePGPPublicKey(p.body)
+		if errors.Is(err, errNotRSA) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		key := PrivateFromPublic(pub)
+		if p.tag == pgpTagSecretKey || p.tag == pgpTagSecretSubkey {
+			if d, primes, ok := parsePGPSecret(p.body[l:]); ok {
+				key.D, key.Primes = d, primes
+			}
+		}
+
+		k, err := NewRSA(key, nil, nil, "", false)
+		if err != nil {
+			return nil, err
+		}
+
+		fp := pgpFingerprint(p.body[:l])
+		k.PGP = &PGPKey{
+			KeyID:       strings.ToUpper(hex.EncodeToString(fp[12:])),
+			Subkey:      p.tag == pgpTagPublicSubkey || p.tag == pgpTagSecretSubkey,
+			fingerprint: fp,
+		}
+
+		group = append(group, k)
+	}
+
+	flush()
+
+	if len(ks) == 0 {
+		return nil, errors.New("no RSA OpenPGP keys found")
+	}
+
+	return ks, nil
+}
+
+// EncodePGPSecretKey returns the ASCII armored OpenPGP secret key for the broken key k that
+// gpg --import accepts. The other keys of its transferable key are written as GnuPG stubs without
+// secret parts and the user ids and signatures are copied as is, which stay valid as none of the
+// public keys change.
+func EncodePGPSecretKey(k *RSA) (string, error) {
+	if k.PGP == nil {
+		return "", errors.New("the key was not imported from an OpenPGP key")
+	}
+
+	if k.Key.D == nil || len(k.Key.Primes) != 2 {
+		return "", errors.New("an OpenPGP secret key needs d and two primes")
+	}
+
+	ps, err := readPGPPackets(k.PGP.transferable)
+	if err != nil {
+		return "", err
+	}
+
+	// OpenPGP wants p < q and u = p^-1 mod q.
+	p, q := k.Key.Primes[0], k.Key.Primes[1]
+	if p.Cmp(q) > 0 {
+		p, q = q, p
+	}
+
+	var secret []byte
+	for _, z := range []*fmp.Fmpz{k.Key.D, p, q, new(fmp.Fmpz).ModInverse(p, q)} {
+		secret = appendMPI(secret, z)
+	}
+
+	var sum uint16
+	for _, c := range secret {
+		sum += uint16(c)
+	}
+
+	secret = binary.BigEndian.AppendUint16(append([]byte{0}, secret...), sum)
+
+	var res []byte
+	for _, pkt := range ps {
+		tag := pkt.tag
+		switch tag {
+		case pgpTagPublicKey, pgpTagSecretKey:
+			tag = pgpTagSecretKey
+		case pgpTagPublicSubkey, pgpTagSecretSubkey:
+			tag = pgpTagSecretSubkey
+		default:
+			res = append(res, pkt.raw...)
+			continue
+		}
+
+		// Keys of other algorithms have a different public part so only the length is parsed.
+		l, err := pgpPublicLength(pkt.body)
+		if err != nil {
+			return "", err
+		}
+
+		body := append([]byte(nil), pkt.body[:l]...)
+		if bytes.Equal(pgpFingerprint(body), k.PGP.fingerprint) {
+			body = append(body, secret...)
+		} else {
+			body = append(body, pgpDummyS2K...)
+		}
+
+		res = appendPGPPacket(res, tag, body)
+	}
+
+	return pgpArmor("PGP PRIVATE KEY BLOCK", res), nil
+}
+
+// pgpPublicFields are the number of integers in the public part of keys of each algorithm that
+// are made only of integers, ECC keys start with an OID.
+var pgpPublicFields = map[byte]int{
+	pgpAlgoRSA: 2, pgpAlgoRSAEncrypt: 2, pgpAlgoRSASign: 2, 16: 3, 17: 4, 20: 3,
+}
+
+// pgpPublicLength returns the length of the public part of a version 4 key packet body.
+func pgpPublicLength(body []byte) (int, error) {
+	if len(body) < 6 || body[0] != 4 {
+		return 0, errors.New("only version 4 OpenPGP keys are supported")
+	}
+
+	rest := body[6:]
+	n, ok := pgpPublicFields[body[5]]
+	if !ok {
+		// ECDH, ECDSA and EdDSA keys have an OID then a point and ECDH adds the KDF parameters.
+		if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+			return 0, errors.New("OpenPGP key is truncated")
+		}
+
+		rest = rest[1+int(rest[0]):]
+		var err error
+		if _, rest, err = readMPI(rest); err != nil {
+			return 0, err
+		}
+
+		if body[5] == 18 {
+			if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+				return 0, errors.New("OpenPGP key is truncated")
+			}
+
+			rest = rest[1+int(rest[0]):]
+		}
+
+		return len(body) - len(rest), nil
+	}
+
+	for i := 0; i < n; i++ {
+		var err error
+		if _, rest, err = readMPI(rest); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(body) - len(rest), nil
+}
+
+// isRSAPKESK returns true if p is a version 3 public key encrypted session key packet which holds
+// the key id, the algorithm and the encrypted integer of an RSA key.
+func isRSAPKESK(p pgpPacket) bool {
+	return p.tag == pgpTagPKESK && len(p.body) >= 10 && p.body[0] == 3 &&
+		(p.body[9] == pgpAlgoRSA || p.body[9] == pgpAlgoRSAEncrypt)
+}
+
+// IsPGPMessage returns true if msg is an ASCII armored OpenPGP message or a binary one that starts
+// with an RSA public key encrypted session key packet. The binary check is strict so a raw
+// ciphertext which happens to start with a packet tag is not mistaken for a message.
+func IsPGPMessage(msg []byte) bool {
+	if bytes.Contains(msg, []byte("-----BEGIN PGP MESSAGE-----")) {
+		return true
+	}
+
+	ps, err := readPGPPackets(msg)
+	return err == nil && len(ps) > 0 && isRSAPKESK(ps[0])
+}
+
+// PGPCipherText returns the RSA ciphertext of the session key encrypted to the key with the id
+// keyID in the binary or ASCII armored OpenPGP message msg. An empty keyID takes the first RSA
+// encrypted session key and session keys for the wildcard key id match any key.
+func PGPCipherText(msg []byte, keyID string) ([]byte, error) {
+	b, _, err := pgpDearmor(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	ps, err := readPGPPackets(b)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range ps {
+		if !isRSAPKESK(p) {
+			continue
+		}
+
+		id := strings.ToUpper(hex.EncodeToString(p.body[1:9]))
+		if keyID != "" && id != keyID && id != "0000000000000000" {
+			continue
+		}
+
+		c, _, err := readMPI(p.body[10:])
+		if err != nil {
+			return nil, err
+		}
+
+		return c.Bytes(), nil
+	}
+
+	return nil, fmt.Errorf("no RSA encrypted session key for key %q in the OpenPGP message", keyID)
+}
+
+// PGPSessionKey decodes the PKCS#1 v1.5 padded plaintext of a public key encrypted session key,
+// returning the symmetric algorithm and the session key which decrypts the message with
+// gpg --override-session-key.
+func PGPSessionKey(pt []byte) (int, []byte, error) {
+	// The leading zero is lost when the plaintext was converted from an integer.
+	pt = bytes.TrimPrefix(pt, []byte{0})
+	if len(pt) < 2 || pt[0] != 2 {
+		return 0, nil, errors.New("plaintext is not PKCS#1 v1.5 padded")
+	}
+
+	i := bytes.IndexByte(pt[1:], 0)
+	if i < 8 {
+		return 0, nil, errors.New("plaintext is not PKCS#1 v1.5 padded")
+	}
+
+	m := pt[i+2:]
+	if len(m) < 3 {
+		return 0, nil, errors.New("session key is truncated")
+	}
+
+	algo, key := int(m[0]), m[1:len(m)-2]
+	if size, ok := pgpSessionKeySizes[algo]; !ok || size != len(key) {
+		return 0, nil, fmt.Errorf("unsupported session key algorithm %d with a %d byte key", algo, len(key))
+	}
+
+	var sum uint16
+	for _, c := range key {
+		sum += uint16(c)
+	}
+
+	if sum != binary.BigEndian.Uint16(m[len(m)-2:]) {
+		return 0, nil, errors.New("session key checksum does not match")
+	}
+
+	return algo, key, nil
+}
@@ -0,0 +1,208 @@
+package keys
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sourcekris/goRsaTool/ln"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+const (
+	// sshRSA is the OpenSSH name of the RSA key type.
+	sshRSA = "ssh-rsa"
+	// opensshMagic begins the body of an OPENSSH PRIVATE KEY PEM block.
+	opensshMagic = "openssh-key-v1\x00"
+	// opensshPEMType is the PEM block type of OpenSSH private keys.
+	opensshPEMType = "OPENSSH PRIVATE KEY"
+)
+
+// ErrOpenSSHKey is returned when an OPENSSH PRIVATE KEY block cannot be imported, for example
+// because it is encrypted or is not an RSA key.
+var ErrOpenSSHKey = errors.New("failed to parse the OpenSSH private key")
+
+// sshReader reads the fields of the SSH wire format from RFC 4251.
+type sshReader struct {
+	b   []byte
+	err error
+}
+
+func (r *sshReader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+
+	if len(r.b) < 4 {
+		r.err = errors.New("ssh key data is truncated")
+		return 0
+	}
+
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *sshReader) string() []byte {
+	l := r.uint32()
+	if r.err != nil {
+		return nil
+	}
+
+	if uint32(len(r.b)) < l {
+		r.err = errors.New("ssh key data is truncated")
+		return nil
+	}
+
+	s := r.b[:l]
+	r.b = r.b[l:]
+	return s
+}
+
+// mpint reads a non-negative multiple precision integer.
+func (r *sshReader) mpint() *fmp.Fmpz {
+	s := r.string()
+	if r.err != nil {
+		return nil
+	}
+
+	if len(s) > 0 && s[0]&0x80 != 0 {
+		r.err = errors.New("ssh key has a negative integer")
+		return nil
+	}
+
+	return new(fmp.Fmpz).SetBytes(s)
+}
+
+// parseSSHPublicKey parses the wire format of an ssh-rsa public key, the type followed by e and n.
+func parseSSHPublicKey(b []byte) (*FMPPublicKey, error) {
+	r := &sshReader{b: b}
+	if t := string(r.string()); r.err == nil && t != sshRSA {
+		return nil, fmt.Errorf("unsupported ssh key type %q", t)
+	}
+
+	e := r.mpint()
+	n := r.mpint()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return &FMPPublicKey{N: n, E: e}, nil
+}
+
+// ImportAuthorizedKeys imports every ssh-rsa public key in kb, which can be a single OpenSSH
+// public key line or a whole authorized_keys file. Comments, options and keys of other types
+// are skipped and an error is returned when there are no RSA keys.
+func ImportAuthorizedKeys(kb []byte) ([]*RSA, error) {
+	var ks []*RSA
+
+	s := bufio.NewScanner(bytes.NewReader(kb))
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// The key type is preceded by options in authorized_keys files and followed by the key.
+		fs := strings.Fields(line)
+		for i := 0; i+1 < len(fs); i++ {
+			if fs[i] != sshRSA {
+				continue
+			}
+
+			b, err := base64.StdEncoding.DecodeString(fs[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("failed decoding ssh public key: %v", err)
+			}
+
+			pub, err := parseSSHPublicKey(b)
+			if err != nil {
+				return nil, err
+			}
+
+			k, err := NewRSA(PrivateFromPublic(pub), nil, nil, "", false)
+			if err != nil {
+				return nil, err
+			}
+
+			ks = append(ks, k)
+			break
+		}
+	}
+
+	if len(ks) == 0 {
+		return nil, errors.New("no ssh-rsa public keys found")
+	}
+
+	return ks, nil
+}
+
+// parseOpenSSHPrivateKey parses the body of an OPENSSH PRIVATE KEY PEM block. Only unencrypted
+// keys can be read and the first key in the block must be an RSA key.
+func parseOpenSSHPrivateKey(b []byte) (*FMPPrivateKey, error) {
+	if !bytes.HasPrefix(b, []byte(opensshMagic)) {
+		return nil, errors.New("not an openssh-key-v1 private key")
+	}
+
+	r := &sshReader{b: b[len(opensshMagic):]}
+	cipher := string(r.string())
+	r.string() // kdfname
+	r.string() // kdfoptions
+	if nkeys := r.uint32(); r.err == nil && nkeys < 1 {
+		return nil, errors.New("openssh private key holds no keys")
+	}
+
+	r.string() // the public key is repeated in the private section.
+	priv := r.string()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if cipher != "none" {
+		return nil, fmt.Errorf("openssh private key is encrypted with %s which is not supported", cipher)
+	}
+
+	r = &sshReader{b: priv}
+	if c1, c2 := r.uint32(), r.uint32(); r.err == nil && c1 != c2 {
+		return nil, errors.New("openssh private key check values do not match")
+	}
+
+	if t := string(r.string()); r.err == nil && t != sshRSA {
+		return nil, fmt.Errorf("unsupported ssh key type %q", t)
+	}
+
+	var (
+		n    = r.mpint()
+		e    = r.mpint()
+		d    = r.mpint()
+		iqmp = r.mpint()
+		p    = r.mpint()
+		q    = r.mpint()
+	)
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	// The CRT values are reduced modulo p-1 and q-1 so those must be sound before they are used.
+	if p.Cmp(ln.BigOne) <= 0 || q.Cmp(ln.BigOne) <= 0 || !new(fmp.Fmpz).Mul(p, q).Equals(n) {
+		return nil, errors.New("openssh private key primes do not multiply to the modulus")
+	}
+
+	key := PrivateFromPublic(&FMPPublicKey{N: n, E: e})
+	key.D = d
+	key.Primes = []*fmp.Fmpz{p, q}
+	key.Precomputed = &PrecomputedValues{
+		Dp:   new(fmp.Fmpz).Mod(d, new(fmp.Fmpz).Sub(p, ln.BigOne)),
+		Dq:   new(fmp.Fmpz).Mod(d, new(fmp.Fmpz).Sub(q, ln.BigOne)),
+		Qinv: iqmp,
+	}
+
+	return key, nil
+}
@@ -0,0 +1,290 @@
+package keys
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/sourcekris/x509big"
+)
+
+const (
+	// pkcs8PEMType and encryptedPKCS8PEMType are the PEM block types of PKCS#8 private keys.
+	pkcs8PEMType          = "PRIVATE KEY"
+	encryptedPKCS8PEMType = "ENCRYPTED PRIVATE KEY"
+)
+
+var (
+	// ErrPasswordRequired is returned when importing an encrypted private key without a password.
+	ErrPasswordRequired = errors.New("the private key is encrypted, a password is required")
+	// ErrIncorrectPassword is returned when an encrypted private key fails to decrypt.
+	ErrIncorrectPassword = errors.New("incorrect password for the encrypted private key")
+)
+
+var (
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidPBES2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+
+	// prfs are the pseudorandom functions PBKDF2 may use, hmacWithSHA1 is the default.
+	prfs = map[string]func() hash.Hash{
+		"1.2.840.113549.2.7":  sha1.New,
+		"1.2.840.113549.2.8":  sha256.New224,
+		"1.2.840.113549.2.9":  sha256.New,
+		"1.2.840.113549.2.10": sha512.New384,
+		"1.2.840.113549.2.11": sha512.New,
+	}
+
+	// pbes2Ciphers are the block ciphers PBES2 may use in CBC mode and their key sizes.
+	pbes2Ciphers = map[string]struct {
+		keyLen int
+		block  func([]byte) (cipher.Block, error)
+	}{
+		"2.16.840.1.101.3.4.1.2":  {16, aes.NewCipher},
+		"2.16.840.1.101.3.4.1.22": {24, aes.NewCipher},
+		"2.16.840.1.101.3.4.1.42": {32, aes.NewCipher},
+		"1.2.840.113549.3.7":      {24, des.NewTripleDESCipher},
+	}
+)
+
+// pkcs8 is the PKCS#8 PrivateKeyInfo from RFC 5208, the optional attributes are ignored.
+type pkcs8 struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+// encryptedPrivateKeyInfo is the PKCS#8 EncryptedPrivateKeyInfo from RFC 5208.
+type encryptedPrivateKeyInfo struct {
+	Algo          pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params and pbkdf2Params are the parameters of PBES2 and PBKDF2 from RFC 8018.
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// maxPBKDF2Iterations bounds the PBKDF2 iteration count of a key so that a crafted key cannot
+// stall the import, OpenSSL uses 2048 by default.
+const maxPBKDF2Iterations = 10000000
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// parseBigPKCS8PrivateKey parses an unencrypted PKCS#8 RSA private key.
+func parseBigPKCS8PrivateKey(der []byte) (*x509big.BigPrivateKey, error) {
+	var pk pkcs8
+	if _, err := asn1.Unmarshal(der, &pk); err != nil {
+		return nil, fmt.Errorf("failed parsing PKCS#8 private key: %v", err)
+	}
+
+	if !pk.Algo.Algorithm.Equal(oidRSAEncryption) {
+		return nil, fmt.Errorf("PKCS#8 private key has unsupported algorithm %v", pk.Algo.Algorithm)
+	}
+
+	return x509big.ParseBigPKCS1PrivateKey(pk.PrivateKey)
+}
+
+// EncodeFMPPrivateKeyPKCS8 marshalls an RSA private key using FMP types into a PKCS#8 PEM string.
+func EncodeFMPPrivateKeyPKCS8(priv *FMPPrivateKey) string {
+	der, _ := asn1.Marshal(pkcs8{
+		Algo: pkix.AlgorithmIdentifier{
+			Algorithm:  oidRSAEncryption,
+			Parameters: asn1.NullRawValue,
+		},
+		PrivateKey: x509big.MarshalPKCS1BigPrivateKey(FMPtoBigPrivateKey(priv)),
+	})
+
+	return encodeDerToPem(der, pkcs8PEMType)
+}
+
+// pbkdf2 derives a key of keyLen bytes from the password as in RFC 8018 section 5.2.
+func pbkdf2(password, salt []byte, iter, keyLen int, h func() hash.Hash) []byte {
+	prf := hmac.New(h, password)
+	var (
+		key []byte
+		buf [4]byte
+	)
+
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], block)
+		prf.Write(buf[:])
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iter; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:keyLen]
+}
+
+// decryptPKCS8 decrypts a PKCS#8 EncryptedPrivateKeyInfo using PBES2 with PBKDF2 and AES or
+// triple DES in CBC mode, which is what OpenSSL writes, and returns the PrivateKeyInfo.
+func decryptPKCS8(der, password []byte) ([]byte, error) {
+	var epki encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &epki); err != nil {
+		return nil, fmt.Errorf("failed parsing encrypted PKCS#8 private key: %v", err)
+	}
+
+	if !epki.Algo.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("encrypted PKCS#8 private key uses unsupported scheme %v", epki.Algo.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(epki.Algo.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("failed parsing PBES2 parameters: %v", err)
+	}
+
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("encrypted PKCS#8 private key uses unsupported kdf %v", params.KeyDerivationFunc.Algorithm)
+	}
+
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, fmt.Errorf("failed parsing PBKDF2 parameters: %v", err)
+	}
+
+	if kdf.IterationCount < 1 || kdf.IterationCount > maxPBKDF2Iterations {
+		return nil, fmt.Errorf("encrypted PKCS#8 private key has an invalid PBKDF2 iteration count %d", kdf.IterationCount)
+	}
+
+	h := sha1.New
+	if kdf.PRF.Algorithm != nil {
+		var ok bool
+		if h, ok = prfs[kdf.PRF.Algorithm.String()]; !ok {
+			return nil, fmt.Errorf("encrypted PKCS#8 private key uses unsupported prf %v", kdf.PRF.Algorithm)
+		}
+	}
+
+	c, ok := pbes2Ciphers[params.EncryptionScheme.Algorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("encrypted PKCS#8 private key uses unsupported cipher %v", params.EncryptionScheme.Algorithm)
+	}
+
+	if kdf.KeyLength != 0 && kdf.KeyLength != c.keyLen {
+		return nil, fmt.Errorf("encrypted PKCS#8 private key has a %d byte PBKDF2 key for a cipher with %d byte keys", kdf.KeyLength, c.keyLen)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("failed parsing cipher iv: %v", err)
+	}
+
+	block, err := c.block(pbkdf2(password, kdf.Salt, kdf.IterationCount, c.keyLen, h))
+	if err != nil {
+		return nil, err
+	}
+
+	data := epki.EncryptedData
+	if len(iv) != block.BlockSize() || len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, errors.New("encrypted PKCS#8 private key has an invalid iv or length")
+	}
+
+	pt := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(pt, data)
+
+	// Strip the PKCS#7 padding, a bad pad almost always means a wrong password.
+	pad := int(pt[len(pt)-1])
+	if pad == 0 || pad > block.BlockSize() || !bytes.Equal(pt[len(pt)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, ErrIncorrectPassword
+	}
+
+	return pt[:len(pt)-pad], nil
+}
+
+// isEncrypted returns true if block is an encrypted PKCS#8 or legacy Proc-Type encrypted key.
+func isEncrypted(block *pem.Block) bool {
+	// The legacy format is deprecated for being insecure which is no concern when attacking keys.
+	return block.Type == encryptedPKCS8PEMType || x509.IsEncryptedPEMBlock(block)
+}
+
+// decryptBlock returns the DER key in block, decrypting it with password if it is encrypted.
+func decryptBlock(block *pem.Block, password []byte) ([]byte, error) {
+	switch {
+	case !isEncrypted(block):
+		return block.Bytes, nil
+	case len(password) == 0:
+		return nil, ErrPasswordRequired
+	case block.Type == encryptedPKCS8PEMType:
+		return decryptPKCS8(block.Bytes, password)
+	}
+
+	der, err := x509.DecryptPEMBlock(block, password)
+	if errors.Is(err, x509.IncorrectPasswordError) {
+		return nil, ErrIncorrectPassword
+	}
+
+	return der, err
+}
+
+// CrackKeyPassword tries every line of words as the password of the encrypted PEM or PKCS#8
+// private key in kb and returns the key and the password when one decrypts it.
+func CrackKeyPassword(kb []byte, words io.Reader) (*RSA, string, error) {
+	block, _ := pem.Decode(kb)
+	if block == nil {
+		return nil, "", errors.New("failed to decode PEM key")
+	}
+
+	if !isEncrypted(block) {
+		return nil, "", errors.New("the private key is not encrypted")
+	}
+
+	s := bufio.NewScanner(words)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		password := bytes.TrimRight(s.Bytes(), "\r")
+		if len(password) == 0 {
+			continue
+		}
+
+		// A wrong password can still unpad correctly so the key must parse too.
+		der, err := decryptBlock(block, password)
+		if err != nil {
+			continue
+		}
+
+		if priv, err := parseBigPrivateRsaKey(der); err == nil {
+			k, err := NewRSA(priv, nil, nil, "", false)
+			return k, string(password), err
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed reading wordlist: %v", err)
+	}
+
+	return nil, "", errors.New("none of the passwords in the wordlist decrypt the key")
+}
@@ -0,0 +1,81 @@
+package keys
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/sourcekris/goRsaTool/ln"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// rabinTails are the sizes in bytes of the replicated tail redundancy tried on Rabin plaintexts,
+// 8 bytes is the scheme from the Handbook of Applied Cryptography.
+var rabinTails = []int{8, 16, 4}
+
+// IsRabin returns true when the key is a Rabin key, e = 2, which has no private exponent.
+func (t *RSA) IsRabin() bool {
+	return t.Key.PublicKey.E != nil && t.Key.PublicKey.E.Equals(ln.BigTwo)
+}
+
+// rabinRedundancy returns m with its redundancy removed if it follows one of the common Rabin
+// padding schemes, the plaintext repeated in full or its tail replicated.
+func rabinRedundancy(m []byte) ([]byte, bool) {
+	if h := len(m) / 2; len(m)%2 == 0 && h > 0 && bytes.Equal(m[:h], m[h:]) {
+		return m[:h], true
+	}
+
+	for _, r := range rabinTails {
+		if len(m) >= 2*r && bytes.Equal(m[len(m)-r:], m[len(m)-2*r:len(m)-r]) {
+			return m[:len(m)-r], true
+		}
+	}
+
+	return nil, false
+}
+
+// packRabin packs the primes of a Rabin key and decrypts the ciphertext if one was given. Each
+// ciphertext has a square root for every combination of roots modulo the primes, they are all
+// kept in PlainTexts ranked by redundancy, the crib and printability and the best is PlainText.
+func (t *RSA) packRabin(primes []*fmp.Fmpz) {
+	t.Key.Primes = primes
+	t.Key.D = nil
+
+	if t.CipherText == nil || t.PlainText != nil {
+		return
+	}
+
+	type candidate struct {
+		m     []byte
+		score float64
+	}
+
+	var cs []candidate
+	for _, r := range ln.SquareRootsMod(ln.BytesToNumber(t.CipherText), primes) {
+		m := ln.NumberToBytes(r)
+
+		var score float64
+		if stripped, ok := rabinRedundancy(m); ok {
+			m, score = stripped, 4
+		}
+
+		if t.KnownPlainText != nil && bytes.Contains(m, t.KnownPlainText) {
+			score += 8
+		}
+
+		cs = append(cs, candidate{m, score + ln.PlaintextScore(m)})
+	}
+
+	if len(cs) == 0 {
+		return
+	}
+
+	sort.SliceStable(cs, func(i, j int) bool { return cs[i].score > cs[j].score })
+
+	t.PlainTexts = make([][]byte, len(cs))
+	for i, c := range cs {
+		t.PlainTexts[i] = c.m
+	}
+
+	t.PlainText = t.PlainTexts[0]
+}
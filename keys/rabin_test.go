@@ -0,0 +1,60 @@
+package keys
+
+import (
+	"testing"
+
+	"github.com/sourcekris/goRsaTool/ln"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+func TestRabin(t *testing.T) {
+	var (
+		n = ln.FmpString("67779169991156313953976086119991464718779730349607858782745496509542441091211")
+		p = ln.FmpString("206649527463365580008337435483770722577")
+	)
+
+	tt := []struct {
+		name string
+		m    []byte
+		kpt  []byte
+		want string
+	}{
+		{
+			name: "replicated tail",
+			m:    []byte("\x8f\x01rabin tail\x00\x11\x22\x33\x44\x55\x66\x77\x00\x11\x22\x33\x44\x55\x66\x77"),
+			want: "\x8f\x01rabin tail\x00\x11\x22\x33\x44\x55\x66\x77",
+		},
+		{
+			name: "repeated plaintext",
+			m:    []byte("\x01\xfeabc\x01\xfeabc"),
+			want: "\x01\xfeabc",
+		},
+		{
+			name: "printable flag",
+			m:    []byte("flag{rabin_has_4_roots}"),
+			want: "flag{rabin_has_4_roots}",
+		},
+		{
+			name: "crib",
+			m:    []byte("\x00\x9d\x80secret\xff"),
+			kpt:  []byte("secret"),
+			want: "\x9d\x80secret\xff",
+		},
+	}
+
+	for _, tc := range tt {
+		c := new(fmp.Fmpz).Exp(ln.BytesToNumber(tc.m), ln.BigTwo, n)
+		k, _ := NewRSA(PrivateFromPublic(&FMPPublicKey{N: n, E: fmp.NewFmpz(2)}), ln.NumberToBytes(c), nil, "", false)
+		k.KnownPlainText = tc.kpt
+		k.PackGivenP(p)
+
+		if k.Key.D != nil || len(k.PlainTexts) != 4 {
+			t.Errorf("PackGivenP() %s: want no d and 4 candidates got d %v and %d candidates", tc.name, k.Key.D, len(k.PlainTexts))
+		}
+
+		if string(k.PlainText) != tc.want {
+			t.Errorf("PackGivenP() %s: want plaintext %q got %q", tc.name, tc.want, k.PlainText)
+		}
+	}
+}
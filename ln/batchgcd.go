@@ -0,0 +1,60 @@
+package ln
+
+import (
+	fmp "github.com/sourcekris/goflint"
+)
+
+// ProductTree returns the levels of the tree whose leaves are xs and whose nodes are the products
+// of their children, an odd node out is carried up as is. The last level holds the product of
+// all of xs.
+func ProductTree(xs []*fmp.Fmpz) [][]*fmp.Fmpz {
+	tree := [][]*fmp.Fmpz{xs}
+	for level := xs; len(level) > 1; tree = append(tree, level) {
+		next := make([]*fmp.Fmpz, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				break
+			}
+
+			next = append(next, new(fmp.Fmpz).Mul(level[i], level[i+1]))
+		}
+
+		level = next
+	}
+
+	return tree
+}
+
+// BatchGCD returns gcd(ns[i], the product of every other modulus) for each of ns using Bernstein's
+// product and remainder trees, which costs about as much as a few multiplications of the product
+// of ns rather than a gcd for every pair. A result of 1 means ns[i] shares no factor with the
+// others and a result equal to ns[i] means all of its factors are shared or it is repeated.
+func BatchGCD(ns []*fmp.Fmpz) []*fmp.Fmpz {
+	if len(ns) == 0 {
+		return nil
+	}
+
+	tree := ProductTree(ns)
+
+	// Reduce the product modulo the square of every node on the way down.
+	rems := tree[len(tree)-1]
+	for l := len(tree) - 2; l >= 0; l-- {
+		next := make([]*fmp.Fmpz, len(tree[l]))
+		for i, x := range tree[l] {
+			sq := new(fmp.Fmpz).Mul(x, x)
+			next[i] = new(fmp.Fmpz).Mod(rems[i/2], sq)
+		}
+
+		rems = next
+	}
+
+	// P mod n^2 divided by n is the product of the others modulo n.
+	gs := make([]*fmp.Fmpz, len(ns))
+	for i, n := range ns {
+		q := new(fmp.Fmpz).Div(rems[i], n)
+		gs[i] = new(fmp.Fmpz).GCD(q, n)
+	}
+
+	return gs
+}
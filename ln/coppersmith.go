@@ -0,0 +1,502 @@
+package ln
+
+import (
+	"fmt"
+	"math"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+const (
+	// maxLatticeDim bounds the lattice dimension SmallRootsMulti will try when choosing m.
+	maxLatticeDim = 60
+	// maxRootPolys is how many of the shortest reduced polynomials are used to recover roots.
+	maxRootPolys = 4
+)
+
+// LLL returns an LLL reduced basis (delta = 0.99) of the lattice spanned by rows. Rows that reduce
+// to zero because the input rows were linearly dependent are dropped.
+func LLL(rows [][]*fmp.Fmpz) [][]*fmp.Fmpz {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// Entry aliases the matrix memory so use a matrix without a finalizer and copy the entries out.
+	m := fmp.NewFmpzMatNF(len(rows), len(rows[0]))
+	for i, r := range rows {
+		for j, v := range r {
+			m.SetVal(v, j, i)
+		}
+	}
+
+	m.LLL()
+
+	var out [][]*fmp.Fmpz
+	for i := range rows {
+		var (
+			row  = make([]*fmp.Fmpz, len(rows[i]))
+			zero = true
+		)
+
+		for j := range row {
+			row[j] = new(fmp.Fmpz).Set(m.Entry(j, i))
+			if !row[j].IsZero() {
+				zero = false
+			}
+		}
+
+		if !zero {
+			out = append(out, row)
+		}
+	}
+
+	return out
+}
+
+// SmallRootsParams are the parameters of SmallRoots. Zero values select defaults.
+type SmallRootsParams struct {
+	// Beta says the roots are modulo an unknown divisor b >= N^Beta of N, 0 < Beta <= 1. The
+	// default of 1 means b = N.
+	Beta float64
+	// Epsilon trades the size of the roots that can be found for the lattice dimension. The
+	// default is Beta / 8.
+	Epsilon float64
+	// X bounds the absolute value of the roots. The default is N^(Beta^2/deg(f) - Epsilon) / 2
+	// which is the bound the method guarantees to reach.
+	X *fmp.Fmpz
+	// M and T set the number of shift polynomials N^(M-i) * f^i * x^j and x^i * f^M directly
+	// when they are tuned for a problem instead of deriving them from Epsilon.
+	M, T int
+}
+
+// SmallRootsEpsilon returns the largest Epsilon for which SmallRoots can reach roots up to x of a
+// degree delta polynomial modulo a divisor b >= N^beta of n. It is no smaller than beta / 50 which
+// bounds the lattice dimension to about 50 * beta / delta.
+func SmallRootsEpsilon(n, x *fmp.Fmpz, beta float64, delta int) float64 {
+	eps := beta*beta/float64(delta) - (log2(x)+1)/log2(n)
+	switch {
+	case eps < beta/50:
+		return beta / 50
+	case eps > beta/8:
+		return beta / 8
+	}
+
+	return eps
+}
+
+// SmallRoots finds the integer roots x0 of the univariate polynomial f modulo an unknown divisor
+// b >= N^Beta of n with |x0| <= X using Howgrave-Graham's formulation of Coppersmith's method. The
+// lattice dimension and bound are chosen as SageMath's small_roots does.
+func SmallRoots(f *fmp.FmpzPoly, n *fmp.Fmpz, params SmallRootsParams) ([]*fmp.Fmpz, error) {
+	delta := f.Len() - 1
+	if delta < 1 {
+		return nil, fmt.Errorf("polynomial must have positive degree")
+	}
+
+	beta, eps := params.Beta, params.Epsilon
+	if beta == 0 {
+		beta = 1
+	}
+
+	if beta < 0 || beta > 1 {
+		return nil, fmt.Errorf("beta must be in (0, 1], got %v", beta)
+	}
+
+	if eps == 0 {
+		eps = beta / 8
+	}
+
+	x := params.X
+	if x == nil {
+		bits := log2(n)*(beta*beta/float64(delta)-eps) - 1
+		if bits < 0 {
+			return nil, fmt.Errorf("modulus is too small for beta %v and epsilon %v", beta, eps)
+		}
+
+		x = exp2Ceil(bits)
+	}
+
+	monic, err := makeMonic(f, n)
+	if err != nil {
+		return nil, err
+	}
+
+	m := int(math.Ceil(math.Max(beta*beta/(float64(delta)*eps), 7*beta/float64(delta))))
+	if params.M > 0 {
+		m = params.M
+	}
+
+	t := int(math.Floor(float64(delta*m) * (1/beta - 1)))
+	if params.T > 0 {
+		t = params.T
+	}
+
+	var (
+		dim = m*delta + t
+		xp  = poly().SetCoeffUI(1, 1)
+	)
+
+	// The shift polynomials x^j * N^(m-i) * f^i and x^i * f^m all have the roots of f modulo b^m.
+	var gs []*fmp.FmpzPoly
+	for i := 0; i < m; i++ {
+		for j := 0; j < delta; j++ {
+			g := poly().Pow(monic, i)
+			g.MulScalar(g, new(fmp.Fmpz).ExpXI(n, m-i))
+			gs = append(gs, g.Mul(g, poly().Pow(xp, j)))
+		}
+	}
+
+	for i := 0; i < t; i++ {
+		g := poly().Pow(monic, m)
+		gs = append(gs, g.Mul(g, poly().Pow(xp, i)))
+	}
+
+	// Scale column k by X^k so that short vectors are polynomials that are small on [-X, X].
+	scale := make([]*fmp.Fmpz, dim)
+	for k := range scale {
+		scale[k] = new(fmp.Fmpz).ExpXI(x, k)
+	}
+
+	rows := make([][]*fmp.Fmpz, dim)
+	for i, g := range gs {
+		rows[i] = make([]*fmp.Fmpz, dim)
+		for k := range rows[i] {
+			rows[i][k] = new(fmp.Fmpz).Mul(g.GetCoeff(k), scale[k])
+		}
+	}
+
+	var (
+		roots []*fmp.Fmpz
+		seen  = make(map[string]bool)
+		minB  = beta*float64(n.BitLen()) - 1
+	)
+
+	for _, row := range LLL(rows) {
+		h := poly()
+		for k, v := range row {
+			h.SetCoeff(k, new(fmp.Fmpz).Quo(v, scale[k]))
+		}
+
+		for _, r := range IntegerRoots(h) {
+			if seen[r.String()] || new(fmp.Fmpz).Abs(r).Cmp(x) > 0 {
+				continue
+			}
+
+			seen[r.String()] = true
+
+			g := new(fmp.Fmpz).GCD(evalPoly(f, r), n)
+			if beta == 1 && !g.Equals(n) || float64(g.BitLen()) < minB {
+				continue
+			}
+
+			roots = append(roots, r)
+		}
+	}
+
+	return roots, nil
+}
+
+// SmallRootsMulti finds integer roots (x0, x1, ...) of the multivariate polynomial f modulo n
+// with |x_i| <= bounds[i]. The lattice is built from the shift polynomials
+// N^(m-k) * f^k * x0^j0 * x1^j1 ... for 0 <= k <= m and 0 <= j_i < d as in the basic strategy of
+// Jochemsz and May, and roots are recovered from the shortest reduced polynomials with
+// resultants. When d is 0 the total degree of f is used and when m is 0 it is increased from 1
+// until roots are found or the lattice gets too large.
+func SmallRootsMulti(f *MPoly, n *fmp.Fmpz, bounds []*fmp.Fmpz, m, d int) ([][]*fmp.Fmpz, error) {
+	k := f.NumVars()
+	if len(bounds) != k {
+		return nil, fmt.Errorf("got %d bounds for %d variables", len(bounds), k)
+	}
+
+	if f.Degree() < 1 {
+		return nil, fmt.Errorf("polynomial must have positive degree")
+	}
+
+	lc := new(fmp.Fmpz).Mod(f.LeadingCoeff(), n)
+	inv := new(fmp.Fmpz).ModInverse(lc, n)
+	if inv.IsZero() {
+		return nil, fmt.Errorf("leading coefficient shares the factor %v with the modulus", new(fmp.Fmpz).GCD(lc, n))
+	}
+
+	monic := NewMPoly(k)
+	for _, exp := range f.Monomials() {
+		c := new(fmp.Fmpz).Mul(f.Coeff(exp...), inv)
+		monic.AddTerm(c.Mod(c, n), exp...)
+	}
+
+	if d == 0 {
+		d = f.Degree()
+	}
+
+	if m > 0 {
+		return smallRootsMulti(f, monic, n, bounds, m, d), nil
+	}
+
+	for m = 1; (m+1)*int(math.Pow(float64(d), float64(k))) <= maxLatticeDim; m++ {
+		if roots := smallRootsMulti(f, monic, n, bounds, m, d); len(roots) > 0 {
+			return roots, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// smallRootsMulti runs SmallRootsMulti for fixed lattice parameters m and d. f is the original
+// polynomial used to check the roots and monic is f made monic modulo n.
+func smallRootsMulti(f, monic *MPoly, n *fmp.Fmpz, bounds []*fmp.Fmpz, m, d int) [][]*fmp.Fmpz {
+	k := f.NumVars()
+
+	var gs []*MPoly
+	for i := 0; i <= m; i++ {
+		base := monic.Pow(i).Scale(new(fmp.Fmpz).ExpXI(n, m-i))
+		forEachShift(k, d, func(shift []int) {
+			gs = append(gs, base.Mul(NewMPoly(k).AddTerm(BigOne, shift...)))
+		})
+	}
+
+	var (
+		monomials [][]int
+		seen      = make(map[string]bool)
+	)
+
+	for _, g := range gs {
+		for _, exp := range g.Monomials() {
+			if !seen[expKey(exp)] {
+				seen[expKey(exp)] = true
+				monomials = append(monomials, exp)
+			}
+		}
+	}
+
+	sortMonomials(monomials)
+
+	// Scale each monomial column by the bounds so that short vectors are small polynomials.
+	scale := make([]*fmp.Fmpz, len(monomials))
+	for j, exp := range monomials {
+		scale[j] = fmp.NewFmpz(1)
+		for i, e := range exp {
+			scale[j].Mul(scale[j], new(fmp.Fmpz).ExpXI(bounds[i], e))
+		}
+	}
+
+	rows := make([][]*fmp.Fmpz, len(gs))
+	for i, g := range gs {
+		rows[i] = make([]*fmp.Fmpz, len(monomials))
+		for j, exp := range monomials {
+			rows[i][j] = new(fmp.Fmpz).Mul(g.Coeff(exp...), scale[j])
+		}
+	}
+
+	var hs []*MPoly
+	for _, row := range LLL(rows) {
+		h := NewMPoly(k)
+		for j, v := range row {
+			h.AddTerm(new(fmp.Fmpz).Quo(v, scale[j]), monomials[j]...)
+		}
+
+		if h.Degree() > 0 {
+			hs = append(hs, h)
+		}
+	}
+
+	var (
+		roots [][]*fmp.Fmpz
+		found = make(map[string]bool)
+	)
+
+	for _, r := range CommonRoots(hs, bounds) {
+		if !new(fmp.Fmpz).Mod(f.Eval(r), n).IsZero() || found[fmt.Sprint(r)] {
+			continue
+		}
+
+		found[fmt.Sprint(r)] = true
+		roots = append(roots, r)
+	}
+
+	return roots
+}
+
+// CommonRoots returns the integer points with |x_i| <= bounds[i] where the polynomials hs vanish,
+// found by eliminating variables with resultants. Only the first few polynomials are used so hs
+// should be ordered from the most to the least likely to vanish, such as the output of LLL. The
+// points are candidates that should be checked against the original problem.
+func CommonRoots(hs []*MPoly, bounds []*fmp.Fmpz) [][]*fmp.Fmpz {
+	if len(hs) == 0 {
+		return nil
+	}
+
+	vars := make([]int, hs[0].NumVars())
+	for i := range vars {
+		vars[i] = i
+	}
+
+	return commonRoots(hs, vars, bounds)
+}
+
+// commonRoots returns candidate integer roots within bounds of the polynomials hs in the
+// variables vars. Each root has one value per variable of the polynomials, variables not in vars
+// are left nil.
+func commonRoots(hs []*MPoly, vars []int, bounds []*fmp.Fmpz) [][]*fmp.Fmpz {
+	if len(hs) > maxRootPolys {
+		hs = hs[:maxRootPolys]
+	}
+
+	v := vars[0]
+
+	// Eliminate the other variables to get polynomials in v alone.
+	ps := hs
+	for _, w := range vars[1:] {
+		ps = eliminate(ps, w)
+	}
+
+	var (
+		cands []*fmp.Fmpz
+		seen  = make(map[string]bool)
+	)
+
+	for _, p := range ps {
+		if p.DegreeIn(v) < 1 {
+			continue
+		}
+
+		for _, r := range IntegerRoots(p.Univariate(v)) {
+			if !seen[r.String()] && new(fmp.Fmpz).Abs(r).Cmp(bounds[v]) <= 0 {
+				seen[r.String()] = true
+				cands = append(cands, r)
+			}
+		}
+	}
+
+	var roots [][]*fmp.Fmpz
+	for _, r := range cands {
+		if len(vars) == 1 {
+			root := make([]*fmp.Fmpz, hs[0].NumVars())
+			root[v] = r
+			roots = append(roots, root)
+			continue
+		}
+
+		var sub []*MPoly
+		for _, h := range hs {
+			if s := h.Subs(v, r); s.Degree() > 0 {
+				sub = append(sub, s)
+			}
+		}
+
+		if len(sub) == 0 {
+			continue
+		}
+
+		for _, root := range commonRoots(sub, vars[1:], bounds) {
+			root[v] = r
+			roots = append(roots, root)
+		}
+	}
+
+	return roots
+}
+
+// eliminate returns polynomials free of x_w that vanish wherever all of ps do. Polynomials
+// that do not depend on x_w are kept and pairs that do are replaced by their resultant.
+func eliminate(ps []*MPoly, w int) []*MPoly {
+	var out []*MPoly
+	for i, p := range ps {
+		if p.DegreeIn(w) < 1 {
+			out = append(out, p)
+			continue
+		}
+
+		for _, q := range ps[i+1:] {
+			if q.DegreeIn(w) < 1 {
+				continue
+			}
+
+			if r := p.Resultant(q, w); r.Degree() > 0 {
+				out = append(out, r)
+			}
+		}
+
+		if len(out) >= maxRootPolys {
+			break
+		}
+	}
+
+	return out
+}
+
+// forEachShift calls fn with every exponent vector of k variables with entries in [0, d).
+func forEachShift(k, d int, fn func([]int)) {
+	shift := make([]int, k)
+	for {
+		fn(shift)
+
+		i := 0
+		for ; i < k; i++ {
+			if shift[i]++; shift[i] < d {
+				break
+			}
+
+			shift[i] = 0
+		}
+
+		if i == k {
+			return
+		}
+	}
+}
+
+// makeMonic returns f multiplied by the inverse of its leading coefficient modulo n.
+func makeMonic(f *fmp.FmpzPoly, n *fmp.Fmpz) (*fmp.FmpzPoly, error) {
+	lc := new(fmp.Fmpz).Mod(f.GetCoeff(f.Len()-1), n)
+	inv := new(fmp.Fmpz).ModInverse(lc, n)
+	if inv.IsZero() {
+		return nil, fmt.Errorf("leading coefficient shares the factor %v with the modulus", new(fmp.Fmpz).GCD(lc, n))
+	}
+
+	r := poly()
+	for i, c := range f.GetCoeffs() {
+		c.Mul(c, inv)
+		r.SetCoeff(i, c.Mod(c, n))
+	}
+
+	return r, nil
+}
+
+// evalPoly returns f(x).
+func evalPoly(f *fmp.FmpzPoly, x *fmp.Fmpz) *fmp.Fmpz {
+	r := fmp.NewFmpz(0)
+	for i := f.Len() - 1; i >= 0; i-- {
+		r.Mul(r, x).Add(r, f.GetCoeff(i))
+	}
+
+	return r
+}
+
+// log2 returns the base 2 logarithm of the positive integer n.
+func log2(n *fmp.Fmpz) float64 {
+	b := n.BitLen()
+	if b <= 53 {
+		return math.Log2(float64(n.Uint64()))
+	}
+
+	top := new(fmp.Fmpz).Set(n).Rsh(b - 53)
+	return math.Log2(float64(top.Uint64())) + float64(b-53)
+}
+
+// exp2Ceil returns ceil(2^b) for b >= 0 to the precision of a float64.
+func exp2Ceil(b float64) *fmp.Fmpz {
+	ib := int(b)
+	r := new(fmp.Fmpz).SetUint64(uint64(math.Ceil(math.Exp2(b-float64(ib)) * (1 << 52))))
+	if ib >= 52 {
+		return r.Lsh(ib - 52)
+	}
+
+	// Round up when shifting the fraction bits out.
+	r.AddI(1<<(52-ib) - 1)
+	return r.Rsh(52 - ib)
+}
+
+// poly returns a new zero polynomial.
+func poly() *fmp.FmpzPoly {
+	return fmp.NewFmpzPoly()
+}
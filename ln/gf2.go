@@ -0,0 +1,94 @@
+package ln
+
+// GF2Row is a row of a matrix over GF(2) packed 64 columns to a word.
+type GF2Row []uint64
+
+// NewGF2Row returns a zero row with room for ncols columns.
+func NewGF2Row(ncols int) GF2Row {
+	return make(GF2Row, (ncols+63)/64)
+}
+
+// Flip toggles the bit in column c.
+func (r GF2Row) Flip(c int) {
+	r[c/64] ^= 1 << uint(c%64)
+}
+
+// Bit returns true if the bit in column c is set.
+func (r GF2Row) Bit(c int) bool {
+	return r[c/64]>>uint(c%64)&1 == 1
+}
+
+func (r GF2Row) xor(s GF2Row) {
+	for i := range r {
+		r[i] ^= s[i]
+	}
+}
+
+func (r GF2Row) isZero() bool {
+	for _, w := range r {
+		if w != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NullSpaceGF2 returns sets of row indexes whose rows sum to zero over GF(2) using Gaussian
+// elimination. There are at least len(rows) - ncols such sets when there are more rows than
+// columns. The rows are not modified.
+func NullSpaceGF2(rows []GF2Row, ncols int) [][]int {
+	n := len(rows)
+	if n == 0 {
+		return nil
+	}
+
+	// Work on a copy of the matrix and track which of the original rows make up each row.
+	m := make([]GF2Row, n)
+	hist := make([]GF2Row, n)
+	for i, r := range rows {
+		m[i] = append(GF2Row{}, r...)
+		hist[i] = NewGF2Row(n)
+		hist[i].Flip(i)
+	}
+
+	used := make([]bool, n)
+	for c := 0; c < ncols; c++ {
+		pivot := -1
+		for i := 0; i < n; i++ {
+			if !used[i] && m[i].Bit(c) {
+				pivot = i
+				break
+			}
+		}
+
+		if pivot < 0 {
+			continue
+		}
+
+		used[pivot] = true
+		for i := 0; i < n; i++ {
+			if i != pivot && m[i].Bit(c) {
+				m[i].xor(m[pivot])
+				hist[i].xor(hist[pivot])
+			}
+		}
+	}
+
+	var deps [][]int
+	for i := 0; i < n; i++ {
+		if used[i] || !m[i].isZero() {
+			continue
+		}
+
+		var dep []int
+		for j := 0; j < n; j++ {
+			if hist[i].Bit(j) {
+				dep = append(dep, j)
+			}
+		}
+		deps = append(deps, dep)
+	}
+
+	return deps
+}
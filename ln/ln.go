@@ -3,6 +3,7 @@ package ln
 import (
 	"math/bits"
 	"math/rand"
+	"regexp"
 	"time"
 
 	"github.com/jbarham/primegen"
@@ -56,6 +57,32 @@ func NumberToBytes(src *fmp.Fmpz) []byte {
 	return src.Bytes()
 }
 
+// flagRE matches the usual CTF flag format such as flag{...}.
+var flagRE = regexp.MustCompile(`[[:alnum:]_]+\{[ -|~]+\}`)
+
+// PlaintextScore rates how likely b is to be a plaintext rather than noise. It is the fraction
+// of printable ASCII bytes in b plus one if b looks like it contains a flag, so a score of at
+// least one means b is entirely printable or holds a flag.
+func PlaintextScore(b []byte) float64 {
+	if len(b) == 0 {
+		return 0
+	}
+
+	var printable int
+	for _, c := range b {
+		if (c >= ' ' && c <= '~') || c == '\t' || c == '\n' || c == '\r' {
+			printable++
+		}
+	}
+
+	score := float64(printable) / float64(len(b))
+	if flagRE.Match(b) {
+		score++
+	}
+
+	return score
+}
+
 // SolveforD given e, p and q solve for the private exponent d.
 func SolveforD(p *fmp.Fmpz, q *fmp.Fmpz, e *fmp.Fmpz) *fmp.Fmpz {
 	// invmod(e, (p-1)*(q-1))
@@ -108,7 +135,7 @@ func IsPerfectSquare(n *fmp.Fmpz) *fmp.Fmpz {
 
 		t := new(fmp.Fmpz).Sqrt(n)
 
-		if t.Mul(t, t).Equals(n) {
+		if new(fmp.Fmpz).Mul(t, t).Equals(n) {
 			return t
 		}
 	}
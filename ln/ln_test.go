@@ -1,6 +1,7 @@
 package ln
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 
@@ -54,7 +55,7 @@ func TestContfractToRational(t *testing.T) {
 }
 
 func TestIsPerfectSquare(t *testing.T) {
-	if IsPerfectSquare(fmp.NewFmpz(64)).Sign() < 0 || IsPerfectSquare(fmp.NewFmpz(65)).Sign() > 0 {
+	if !IsPerfectSquare(fmp.NewFmpz(64)).Equals(fmp.NewFmpz(8)) || IsPerfectSquare(fmp.NewFmpz(65)).Sign() > 0 {
 		t.Error("IsPerfectSquare Failed")
 	}
 }
@@ -314,3 +315,350 @@ func TestFracPow(t *testing.T) {
 		}
 	}
 }
+
+func TestNullSpaceGF2(t *testing.T) {
+	// Rows are bit vectors over 3 columns, rows 0 and 2 and rows 1, 3 and 4 sum to zero.
+	vals := [][]int{{0, 1}, {1}, {0, 1}, {2}, {1, 2}}
+	var rows []GF2Row
+	for _, v := range vals {
+		r := NewGF2Row(3)
+		for _, c := range v {
+			r.Flip(c)
+		}
+		rows = append(rows, r)
+	}
+
+	deps := NullSpaceGF2(rows, 3)
+	if len(deps) < 2 {
+		t.Fatalf("NullSpaceGF2() failed: expected at least 2 dependencies got %v", deps)
+	}
+
+	for _, dep := range deps {
+		sum := NewGF2Row(3)
+		for _, i := range dep {
+			for c := 0; c < 3; c++ {
+				if rows[i].Bit(c) {
+					sum.Flip(c)
+				}
+			}
+		}
+
+		for c := 0; c < 3; c++ {
+			if sum.Bit(c) {
+				t.Errorf("NullSpaceGF2() failed: dependency %v does not sum to zero", dep)
+			}
+		}
+	}
+}
+
+// polyFromStrings returns the polynomial with the coefficients cs from the constant term up.
+func polyFromStrings(cs ...string) *fmp.FmpzPoly {
+	f := fmp.NewFmpzPoly()
+	for i, c := range cs {
+		f.SetCoeff(i, FmpString(c))
+	}
+
+	return f
+}
+
+func TestSmallRoots(t *testing.T) {
+	// The cases are the examples from the SageMath small_roots documentation.
+	for _, tc := range []struct {
+		name   string
+		f      *fmp.FmpzPoly
+		n      *fmp.Fmpz
+		params SmallRootsParams
+		want   []*fmp.Fmpz
+	}{
+		{
+			name: "cubic modulo 10001 with the default bound",
+			f:    polyFromStrings("-222", "5000", "10", "1"),
+			n:    fmp.NewFmpz(10001),
+			want: []*fmp.Fmpz{fmp.NewFmpz(4)},
+		},
+		{
+			name: "stereotyped message with e = 3 and 56 unknown bits",
+			f: polyFromStrings(
+				"13407807929942597099574024998205846127479039306022210807528354609368469668281037372619731816845123888381818426566258572537549800976201305970869692694457732",
+				"29937193300291860992010841131559411278502580719417943167300239688685112553177383316512861899493711",
+				"13407807929942597099574024998205846127479365820592393377723561443721764029935059638074044128440312529619641120393975149193647768220755299256796763842758038",
+				"1",
+			),
+			n:      FmpString("13407807929942597099574024998205846127479365820592393377723561443721764030142790646165789383030198876725227227082741501683806940107542205183165700530855221"),
+			params: SmallRootsParams{X: fmp.NewFmpz(1 << 56)},
+			want:   []*fmp.Fmpz{FmpString("11887148192923521")},
+		},
+		{
+			name:   "factoring with 110 unknown low bits of q",
+			f:      polyFromStrings("-363771576891766310099793797671333630343658288099453662828493271739666217586001", "1"),
+			n:      FmpString("42121870893450634577463914985889299119866228583627912396576170307551916038097787979293186566145227616333612448410970232425608858298877553806829186317293067"),
+			params: SmallRootsParams{Beta: 0.5, X: new(fmp.Fmpz).SetUint64(1).Lsh(110)},
+			want:   []*fmp.Fmpz{FmpString("59729316696419830940219077315902")},
+		},
+	} {
+		got, err := SmallRoots(tc.f, tc.n, tc.params)
+		if err != nil {
+			t.Errorf("SmallRoots() %s failed: %v", tc.name, err)
+			continue
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("SmallRoots() %s want / got mismatch: %v / %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestSmallRootsEpsilon(t *testing.T) {
+	n := new(fmp.Fmpz).SetUint64(1).Lsh(256)
+	for _, tc := range []struct {
+		name string
+		x    *fmp.Fmpz
+		beta float64
+		want float64
+	}{
+		{"small bound", fmp.NewFmpz(1 << 10), 1, 0.125},
+		{"bound within reach", new(fmp.Fmpz).SetUint64(1).Lsh(55), 0.5, 0.03125},
+		{"bound out of reach", new(fmp.Fmpz).SetUint64(1).Lsh(80), 0.5, 0.01},
+	} {
+		if got := SmallRootsEpsilon(n, tc.x, tc.beta, 1); got < tc.want-1e-9 || got > tc.want+1e-9 {
+			t.Errorf("SmallRootsEpsilon() %s: want %v got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestSmallRootsMulti(t *testing.T) {
+	n := FmpString("13407807929942597099574024998205846127479365820592393377723561443721764030142790646165789383030198876725227227082741501683806940107542205183165700530855221")
+
+	for _, tc := range []struct {
+		name   string
+		f      *MPoly
+		bounds []*fmp.Fmpz
+		want   []*fmp.Fmpz
+	}{
+		{
+			name: "x*y + a*x + b*y + c with 40 bit roots",
+			f: NewMPoly(2).AddTerm(BigOne, 1, 1).
+				AddTerm(FmpString("5606771965892694584365624231783539817872339499384382935732707629225047218128777490971380896047509072708597127020052594510994107704347054653665359394219088"), 1, 0).
+				AddTerm(FmpString("2761454912096535646235754379718057238733275125098651586807763955493071118135125988055892562628945658124645380382431972389299688993167576381850248214461076"), 0, 1).
+				AddTerm(FmpString("9971364977477836178688456761500019629502573077400040761562640934539239282069823228315717849185183692141153222721673276985349763226811888530044993907818076"), 0, 0),
+			bounds: []*fmp.Fmpz{fmp.NewFmpz(1 << 40), fmp.NewFmpz(1 << 40)},
+			want:   []*fmp.Fmpz{fmp.NewFmpz(1040772936760), fmp.NewFmpz(434439589175)},
+		},
+		{
+			name: "x*y + a*x*z + b*z + c with 16 bit roots",
+			f: NewMPoly(3).AddTerm(BigOne, 1, 1, 0).
+				AddTerm(FmpString("10632081642265951363257244994035644885863923144995659724863697166844108310583235840879790086220557984267980361489383835389393622129193194508010673531477931"), 1, 0, 1).
+				AddTerm(FmpString("8779454631602483764074097944085171616393812961001387773578906642065964692916791389794072765971895366356833797624936392863339889330899167256813792152283310"), 0, 0, 1).
+				AddTerm(FmpString("12877570788355932268837258617234135704901985057601797668674041249049747345969258654450994884509116978308943226086021849124883919355166538577496542607927033"), 0, 0, 0),
+			bounds: []*fmp.Fmpz{fmp.NewFmpz(1 << 16), fmp.NewFmpz(1 << 16), fmp.NewFmpz(1 << 16)},
+			want:   []*fmp.Fmpz{fmp.NewFmpz(29647), fmp.NewFmpz(56737), fmp.NewFmpz(36685)},
+		},
+	} {
+		got, err := SmallRootsMulti(tc.f, n, tc.bounds, 0, 0)
+		if err != nil {
+			t.Errorf("SmallRootsMulti() %s failed: %v", tc.name, err)
+			continue
+		}
+
+		if len(got) != 1 || fmt.Sprint(got[0]) != fmt.Sprint(tc.want) {
+			t.Errorf("SmallRootsMulti() %s want / got mismatch: %v / %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestResultant(t *testing.T) {
+	// res_y(x*y - 1, y - x) = -(x^2 - 1) up to sign, which vanishes at x = 1 and x = -1.
+	x, y := MPolyVar(2, 0), MPolyVar(2, 1)
+	p := x.Mul(y).Sub(MPolyConst(2, BigOne))
+	q := y.Sub(x)
+
+	r := p.Resultant(q, 1)
+	if r.DegreeIn(1) > 0 || r.Degree() != 2 {
+		t.Fatalf("Resultant() failed: got %v", r)
+	}
+
+	for _, v := range []int64{1, -1} {
+		if !r.Subs(0, fmp.NewFmpz(v)).IsZero() {
+			t.Errorf("Resultant() failed: %v does not vanish at x = %d", r, v)
+		}
+	}
+}
+
+func TestMultipointEval(t *testing.T) {
+	n := fmp.NewFmpz(1000003)
+	roots := []*fmp.Fmpz{fmp.NewFmpz(3), fmp.NewFmpz(5), fmp.NewFmpz(1000000)}
+	f := PolyFromRoots(roots, n)
+
+	var xs []*fmp.Fmpz
+	for x := int64(0); x < 11; x++ {
+		xs = append(xs, fmp.NewFmpz(x))
+	}
+
+	for i, got := range MultipointEval(f, xs) {
+		want := fmp.NewFmpz(1)
+		for _, r := range roots {
+			want.Mul(want, new(fmp.Fmpz).Sub(xs[i], r)).Mod(want, n)
+		}
+
+		if !got.Equals(want) {
+			t.Errorf("MultipointEval() at %v: want %v got %v", xs[i], want, got)
+		}
+	}
+}
+
+func TestSqrtMod(t *testing.T) {
+	for _, tc := range []struct {
+		a, p int64
+		want bool
+	}{
+		{4, 7, true},
+		{3, 7, false},
+		{11, 97, true},
+		{5, 97, false},
+		{2, 10009, true},
+		{0, 13, true},
+	} {
+		a, p := fmp.NewFmpz(tc.a), fmp.NewFmpz(tc.p)
+		r := SqrtMod(a, p)
+		if (r != nil) != tc.want {
+			t.Errorf("SqrtMod(%d, %d): want a root %v got %v", tc.a, tc.p, tc.want, r)
+			continue
+		}
+
+		if r != nil && !new(fmp.Fmpz).Exp(r, BigTwo, p).Equals(a) {
+			t.Errorf("SqrtMod(%d, %d): %v is not a square root", tc.a, tc.p, r)
+		}
+	}
+}
+
+func TestNthRootsMod(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		x, e, p   *fmp.Fmpz
+		wantRoots int
+	}{
+		{"e coprime to p - 1", fmp.NewFmpz(5), fmp.NewFmpz(7), fmp.NewFmpz(97), 1},
+		{"e = 12 with 2^5 * 3 = p - 1", fmp.NewFmpz(5), fmp.NewFmpz(12), fmp.NewFmpz(97), 12},
+		{"e = 32 with 2^5 * 3 = p - 1", fmp.NewFmpz(10), fmp.NewFmpz(32), fmp.NewFmpz(97), 32},
+		{"e = 9 with 3^2 | p - 1", fmp.NewFmpz(1234), fmp.NewFmpz(9), fmp.NewFmpz(10009), 9},
+		{
+			"e = 100 with 2 | p - 1 and a 512 bit p",
+			FmpString("1234567890123456789"),
+			fmp.NewFmpz(100),
+			FmpString("13013195056445077675245767987987229724588379930923318266833492046660374216223334270611792324721132438307229159984813414250922197169316235737830919431103659"),
+			2,
+		},
+	} {
+		c := new(fmp.Fmpz).Exp(tc.x, tc.e, tc.p)
+		roots, err := NthRootsMod(c, tc.e, tc.p)
+		if err != nil {
+			t.Fatalf("NthRootsMod() %s: unexpected error %v", tc.name, err)
+		}
+
+		if len(roots) != tc.wantRoots {
+			t.Errorf("NthRootsMod() %s: want %d roots got %d", tc.name, tc.wantRoots, len(roots))
+		}
+
+		seen, found := make(map[string]bool), false
+		for _, r := range roots {
+			if !new(fmp.Fmpz).Exp(r, tc.e, tc.p).Equals(c) {
+				t.Errorf("NthRootsMod() %s: %v is not a root", tc.name, r)
+			}
+
+			seen[r.String()] = true
+			found = found || r.Equals(tc.x)
+		}
+
+		if len(seen) != len(roots) || !found {
+			t.Errorf("NthRootsMod() %s: roots are not distinct or miss %v: %v", tc.name, tc.x, roots)
+		}
+	}
+}
+
+func TestPlaintextScore(t *testing.T) {
+	for _, tc := range []struct {
+		b        []byte
+		min, max float64
+	}{
+		{[]byte("hello world\n"), 1, 1},
+		{[]byte("easyctf{m0dul4r_fuN!}"), 2, 2},
+		{[]byte{0x00, 0xff, 'a', 'b'}, 0.5, 0.5},
+		{[]byte{0x80, 0x01, 0xfe}, 0, 0},
+		{nil, 0, 0},
+	} {
+		if got := PlaintextScore(tc.b); got < tc.min || got > tc.max {
+			t.Errorf("PlaintextScore(%q) = %v want between %v and %v", tc.b, got, tc.min, tc.max)
+		}
+	}
+}
+
+func TestSquareRootsMod(t *testing.T) {
+	p := FmpString("206649527463365580008337435483770722577")
+	q := FmpString("327990926585457938257140776345444065243")
+
+	for _, tc := range []struct {
+		name      string
+		primes    []*fmp.Fmpz
+		wantRoots int
+	}{
+		{"two primes", []*fmp.Fmpz{p, q}, 4},
+		{"three primes", []*fmp.Fmpz{p, q, fmp.NewFmpz(10007)}, 8},
+		{"prime power", []*fmp.Fmpz{p, p, q}, 4},
+		{"cube of a small prime", []*fmp.Fmpz{fmp.NewFmpz(101), fmp.NewFmpz(101), fmp.NewFmpz(101)}, 2},
+	} {
+		n := fmp.NewFmpz(1)
+		for _, f := range tc.primes {
+			n.MulZ(f)
+		}
+
+		x := new(fmp.Fmpz).Mod(FmpString("123456789123456789123456789"), n)
+		c := new(fmp.Fmpz).Exp(x, BigTwo, n)
+
+		roots := SquareRootsMod(c, tc.primes)
+		if len(roots) != tc.wantRoots {
+			t.Errorf("SquareRootsMod() %s: want %d roots got %d", tc.name, tc.wantRoots, len(roots))
+		}
+
+		found := false
+		for _, r := range roots {
+			if !new(fmp.Fmpz).Exp(r, BigTwo, n).Equals(c) {
+				t.Errorf("SquareRootsMod() %s: %v is not a square root", tc.name, r)
+			}
+
+			found = found || r.Equals(x)
+		}
+
+		if !found {
+			t.Errorf("SquareRootsMod() %s: %v not among the roots %v", tc.name, x, roots)
+		}
+	}
+}
+
+func TestBatchGCD(t *testing.T) {
+	ns := []*fmp.Fmpz{
+		fmp.NewFmpz(101 * 103),
+		fmp.NewFmpz(103 * 107),
+		fmp.NewFmpz(109 * 113),
+		fmp.NewFmpz(101 * 127),
+		fmp.NewFmpz(131 * 137),
+		fmp.NewFmpz(131 * 137),
+		fmp.NewFmpz(139 * 149),
+	}
+
+	want := []int64{101 * 103, 103, 1, 101, 131 * 137, 131 * 137, 1}
+
+	gs := BatchGCD(ns)
+	if len(gs) != len(want) {
+		t.Fatalf("BatchGCD() want %d results got %d", len(want), len(gs))
+	}
+
+	for i, g := range gs {
+		if !g.Equals(fmp.NewFmpz(want[i])) {
+			t.Errorf("BatchGCD() modulus %v: want %d got %v", ns[i], want[i], g)
+		}
+	}
+
+	if got := BatchGCD(ns[:1]); !got[0].Equals(BigOne) {
+		t.Errorf("BatchGCD() of a single modulus: want 1 got %v", got[0])
+	}
+}
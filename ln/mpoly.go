@@ -0,0 +1,472 @@
+package ln
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// MPoly is a polynomial with integer coefficients in a fixed number of variables x0, x1, ...
+// Create polynomials with NewMPoly, MPolyVar or MPolyConst. Apart from AddTerm the methods
+// return a new polynomial and leave their receiver and arguments unchanged.
+type MPoly struct {
+	nvars int
+	terms map[string]*mterm
+}
+
+// mterm is a single non-zero term c * x0^exp[0] * x1^exp[1] ...
+type mterm struct {
+	exp []int
+	c   *fmp.Fmpz
+}
+
+// NewMPoly returns the zero polynomial in nvars variables.
+func NewMPoly(nvars int) *MPoly {
+	return &MPoly{nvars: nvars, terms: make(map[string]*mterm)}
+}
+
+// MPolyVar returns the polynomial x_i in nvars variables.
+func MPolyVar(nvars, i int) *MPoly {
+	exp := make([]int, nvars)
+	exp[i] = 1
+	return NewMPoly(nvars).AddTerm(BigOne, exp...)
+}
+
+// MPolyConst returns the constant polynomial c in nvars variables.
+func MPolyConst(nvars int, c *fmp.Fmpz) *MPoly {
+	return NewMPoly(nvars).AddTerm(c, make([]int, nvars)...)
+}
+
+// expKey returns the map key of the monomial with exponents exp.
+func expKey(exp []int) string {
+	b := make([]byte, 0, 2*len(exp))
+	for _, e := range exp {
+		b = append(b, byte(e), byte(e>>8))
+	}
+
+	return string(b)
+}
+
+// NumVars returns the number of variables of p.
+func (p *MPoly) NumVars() int {
+	return p.nvars
+}
+
+// AddTerm adds c * x0^exp[0] * x1^exp[1] ... to p in place and returns p.
+func (p *MPoly) AddTerm(c *fmp.Fmpz, exp ...int) *MPoly {
+	if len(exp) != p.nvars {
+		panic(fmt.Sprintf("AddTerm: got %d exponents for %d variables", len(exp), p.nvars))
+	}
+
+	if c.IsZero() {
+		return p
+	}
+
+	k := expKey(exp)
+	if t, ok := p.terms[k]; ok {
+		t.c.Add(t.c, c)
+		if t.c.IsZero() {
+			delete(p.terms, k)
+		}
+
+		return p
+	}
+
+	p.terms[k] = &mterm{exp: append([]int(nil), exp...), c: new(fmp.Fmpz).Set(c)}
+	return p
+}
+
+// Coeff returns the coefficient of the monomial x0^exp[0] * x1^exp[1] ... in p.
+func (p *MPoly) Coeff(exp ...int) *fmp.Fmpz {
+	if t, ok := p.terms[expKey(exp)]; ok {
+		return new(fmp.Fmpz).Set(t.c)
+	}
+
+	return fmp.NewFmpz(0)
+}
+
+// IsZero returns true if p is the zero polynomial.
+func (p *MPoly) IsZero() bool {
+	return len(p.terms) == 0
+}
+
+// Copy returns a copy of p.
+func (p *MPoly) Copy() *MPoly {
+	r := NewMPoly(p.nvars)
+	for _, t := range p.terms {
+		r.AddTerm(t.c, t.exp...)
+	}
+
+	return r
+}
+
+// Add returns p + q.
+func (p *MPoly) Add(q *MPoly) *MPoly {
+	r := p.Copy()
+	for _, t := range q.terms {
+		r.AddTerm(t.c, t.exp...)
+	}
+
+	return r
+}
+
+// Sub returns p - q.
+func (p *MPoly) Sub(q *MPoly) *MPoly {
+	return p.Add(q.Scale(BigNOne))
+}
+
+// Scale returns c * p.
+func (p *MPoly) Scale(c *fmp.Fmpz) *MPoly {
+	r := NewMPoly(p.nvars)
+	for _, t := range p.terms {
+		r.AddTerm(new(fmp.Fmpz).Mul(t.c, c), t.exp...)
+	}
+
+	return r
+}
+
+// Mul returns p * q.
+func (p *MPoly) Mul(q *MPoly) *MPoly {
+	var (
+		r   = NewMPoly(p.nvars)
+		exp = make([]int, p.nvars)
+		c   = new(fmp.Fmpz)
+	)
+
+	for _, a := range p.terms {
+		for _, b := range q.terms {
+			for i := range exp {
+				exp[i] = a.exp[i] + b.exp[i]
+			}
+
+			r.AddTerm(c.Mul(a.c, b.c), exp...)
+		}
+	}
+
+	return r
+}
+
+// Pow returns p^e for e >= 0.
+func (p *MPoly) Pow(e int) *MPoly {
+	r := MPolyConst(p.nvars, BigOne)
+	for b := p; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			r = r.Mul(b)
+		}
+
+		if e > 1 {
+			b = b.Mul(b)
+		}
+	}
+
+	return r
+}
+
+// Subs returns p with x_i replaced by the integer v. The result has the same number of
+// variables but no longer depends on x_i.
+func (p *MPoly) Subs(i int, v *fmp.Fmpz) *MPoly {
+	var (
+		r   = NewMPoly(p.nvars)
+		exp = make([]int, p.nvars)
+	)
+
+	for _, t := range p.terms {
+		copy(exp, t.exp)
+		exp[i] = 0
+		r.AddTerm(new(fmp.Fmpz).Mul(t.c, new(fmp.Fmpz).ExpXI(v, t.exp[i])), exp...)
+	}
+
+	return r
+}
+
+// Eval returns the value of p at the point xs which has one value per variable.
+func (p *MPoly) Eval(xs []*fmp.Fmpz) *fmp.Fmpz {
+	var (
+		r   = fmp.NewFmpz(0)
+		tmp = new(fmp.Fmpz)
+	)
+
+	for _, t := range p.terms {
+		v := new(fmp.Fmpz).Set(t.c)
+		for i, e := range t.exp {
+			if e > 0 {
+				v.Mul(v, tmp.ExpXI(xs[i], e))
+			}
+		}
+
+		r.Add(r, v)
+	}
+
+	return r
+}
+
+// Degree returns the total degree of p or -1 for the zero polynomial.
+func (p *MPoly) Degree() int {
+	d := -1
+	for _, t := range p.terms {
+		if td := sum(t.exp); td > d {
+			d = td
+		}
+	}
+
+	return d
+}
+
+// DegreeIn returns the degree of p in x_i or -1 for the zero polynomial.
+func (p *MPoly) DegreeIn(i int) int {
+	d := -1
+	for _, t := range p.terms {
+		if t.exp[i] > d {
+			d = t.exp[i]
+		}
+	}
+
+	return d
+}
+
+// CoeffIn returns the coefficient of x_i^d when p is viewed as a polynomial in x_i.
+func (p *MPoly) CoeffIn(i, d int) *MPoly {
+	var (
+		r   = NewMPoly(p.nvars)
+		exp = make([]int, p.nvars)
+	)
+
+	for _, t := range p.terms {
+		if t.exp[i] != d {
+			continue
+		}
+
+		copy(exp, t.exp)
+		exp[i] = 0
+		r.AddTerm(t.c, exp...)
+	}
+
+	return r
+}
+
+// Monomials returns the exponents of the monomials of p from the largest to the smallest in
+// graded lexicographic order.
+func (p *MPoly) Monomials() [][]int {
+	var ms [][]int
+	for _, t := range p.terms {
+		ms = append(ms, t.exp)
+	}
+
+	sortMonomials(ms)
+	return ms
+}
+
+// LeadingCoeff returns the coefficient of the largest monomial of p in graded lexicographic
+// order.
+func (p *MPoly) LeadingCoeff() *fmp.Fmpz {
+	if p.IsZero() {
+		return fmp.NewFmpz(0)
+	}
+
+	return p.Coeff(p.Monomials()[0]...)
+}
+
+// Univariate returns p as a polynomial in x_i. The other variables must not appear in p.
+func (p *MPoly) Univariate(i int) *fmp.FmpzPoly {
+	r := fmp.NewFmpzPoly()
+	for _, t := range p.terms {
+		r.SetCoeff(t.exp[i], t.c)
+	}
+
+	return r
+}
+
+// String returns p in a human readable form with the largest monomials first.
+func (p *MPoly) String() string {
+	if p.IsZero() {
+		return "0"
+	}
+
+	var s []string
+	for _, exp := range p.Monomials() {
+		term := []string{p.Coeff(exp...).String()}
+		for i, e := range exp {
+			switch {
+			case e == 1:
+				term = append(term, fmt.Sprintf("x%d", i))
+			case e > 1:
+				term = append(term, fmt.Sprintf("x%d^%d", i, e))
+			}
+		}
+
+		s = append(s, strings.Join(term, "*"))
+	}
+
+	return strings.Join(s, " + ")
+}
+
+// divExact returns p / q if q divides p exactly.
+func (p *MPoly) divExact(q *MPoly) (*MPoly, bool) {
+	if q.IsZero() {
+		return nil, false
+	}
+
+	var (
+		quo  = NewMPoly(p.nvars)
+		rem  = p.Copy()
+		lead = q.Monomials()[0]
+		lc   = q.Coeff(lead...)
+		exp  = make([]int, p.nvars)
+		c    = new(fmp.Fmpz)
+		r    = new(fmp.Fmpz)
+	)
+
+	for !rem.IsZero() {
+		m := rem.Monomials()[0]
+		for i := range exp {
+			if exp[i] = m[i] - lead[i]; exp[i] < 0 {
+				return nil, false
+			}
+		}
+
+		c.QuoRem(rem.Coeff(m...), lc, r)
+		if !r.IsZero() {
+			return nil, false
+		}
+
+		t := NewMPoly(p.nvars).AddTerm(c, exp...)
+		quo.AddTerm(c, exp...)
+		rem = rem.Sub(t.Mul(q))
+	}
+
+	return quo, true
+}
+
+// Resultant returns the resultant of p and q with respect to x_i which no longer depends on
+// x_i. Both polynomials must have positive degree in x_i.
+func (p *MPoly) Resultant(q *MPoly, i int) *MPoly {
+	dp, dq := p.DegreeIn(i), q.DegreeIn(i)
+	size := dp + dq
+
+	// Build the Sylvester matrix, dq rows of shifted coefficients of p then dp rows of q.
+	m := make([][]*MPoly, size)
+	for r := range m {
+		m[r] = make([]*MPoly, size)
+		for c := range m[r] {
+			m[r][c] = NewMPoly(p.nvars)
+		}
+	}
+
+	for r := 0; r < dq; r++ {
+		for j := 0; j <= dp; j++ {
+			m[r][r+j] = p.CoeffIn(i, dp-j)
+		}
+	}
+
+	for r := 0; r < dp; r++ {
+		for j := 0; j <= dq; j++ {
+			m[dq+r][r+j] = q.CoeffIn(i, dq-j)
+		}
+	}
+
+	return bareissDet(m)
+}
+
+// bareissDet returns the determinant of the square matrix m using fraction free Gaussian
+// elimination. The matrix is modified.
+func bareissDet(m [][]*MPoly) *MPoly {
+	var (
+		n    = len(m)
+		neg  = false
+		prev = MPolyConst(m[0][0].nvars, BigOne)
+	)
+
+	for k := 0; k < n-1; k++ {
+		if m[k][k].IsZero() {
+			piv := -1
+			for r := k + 1; r < n; r++ {
+				if !m[r][k].IsZero() {
+					piv = r
+					break
+				}
+			}
+
+			if piv < 0 {
+				return NewMPoly(prev.nvars)
+			}
+
+			m[k], m[piv] = m[piv], m[k]
+			neg = !neg
+		}
+
+		for r := k + 1; r < n; r++ {
+			for c := k + 1; c < n; c++ {
+				v := m[r][c].Mul(m[k][k]).Sub(m[r][k].Mul(m[k][c]))
+				m[r][c], _ = v.divExact(prev)
+			}
+		}
+
+		prev = m[k][k]
+	}
+
+	if neg {
+		return m[n-1][n-1].Scale(BigNOne)
+	}
+
+	return m[n-1][n-1]
+}
+
+// IntegerRoots returns the distinct integer roots of the univariate polynomial f.
+func IntegerRoots(f *fmp.FmpzPoly) []*fmp.Fmpz {
+	var (
+		roots []*fmp.Fmpz
+		seen  = make(map[string]bool)
+		fac   = f.Factor()
+	)
+
+	for i := 0; i < fac.Len(); i++ {
+		g := fac.GetPoly(i)
+		if g.Len() != 2 {
+			continue
+		}
+
+		// g = a*x + b has the integer root -b/a when a divides b.
+		a, b := g.GetCoeff(1), g.GetCoeff(0)
+		r, rem := new(fmp.Fmpz), new(fmp.Fmpz)
+		if r.QuoRem(b, a, rem); !rem.IsZero() {
+			continue
+		}
+
+		r.Neg(r)
+		if !seen[r.String()] {
+			seen[r.String()] = true
+			roots = append(roots, r)
+		}
+	}
+
+	return roots
+}
+
+// sortMonomials sorts ms from the largest to the smallest monomial in graded lexicographic
+// order.
+func sortMonomials(ms [][]int) {
+	sort.Slice(ms, func(a, b int) bool {
+		if da, db := sum(ms[a]), sum(ms[b]); da != db {
+			return da > db
+		}
+
+		for i := range ms[a] {
+			if ms[a][i] != ms[b][i] {
+				return ms[a][i] > ms[b][i]
+			}
+		}
+
+		return false
+	})
+}
+
+func sum(xs []int) int {
+	var s int
+	for _, x := range xs {
+		s += x
+	}
+
+	return s
+}
@@ -0,0 +1,336 @@
+package ln
+
+import (
+	"fmt"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+// MaxNthRoots bounds the number of roots NthRootsMod will list, that is gcd(e, p - 1).
+const MaxNthRoots = 1 << 20
+
+// SqrtMod returns a square root of a modulo the odd prime p using the Tonelli-Shanks algorithm or
+// nil if a is not a quadratic residue.
+func SqrtMod(a, p *fmp.Fmpz) *fmp.Fmpz {
+	a = new(fmp.Fmpz).Mod(a, p)
+	if a.IsZero() {
+		return new(fmp.Fmpz)
+	}
+
+	if a.Jacobi(p) != 1 {
+		return nil
+	}
+
+	// p - 1 = q * 2^s with q odd.
+	q := new(fmp.Fmpz).Sub(p, BigOne)
+	s := 0
+	for q.TstBit(0) == 0 {
+		q.Rsh(1)
+		s++
+	}
+
+	// When p = 3 mod 4 the root is a^((p+1)/4).
+	if s == 1 {
+		e := new(fmp.Fmpz).Add(p, BigOne)
+		return new(fmp.Fmpz).Exp(a, e.Rsh(2), p)
+	}
+
+	// Any quadratic non-residue z gives a generator z^q of the 2-Sylow subgroup.
+	z := fmp.NewFmpz(2)
+	for z.Jacobi(p) != -1 {
+		z.AddI(1)
+	}
+
+	var (
+		c  = new(fmp.Fmpz).Exp(z, q, p)
+		t  = new(fmp.Fmpz).Exp(a, q, p)
+		qq = new(fmp.Fmpz).Add(q, BigOne)
+		r  = new(fmp.Fmpz).Exp(a, qq.Rsh(1), p)
+		m  = s
+	)
+
+	for !t.Equals(BigOne) {
+		// Find the least i with t^(2^i) = 1.
+		i, t2 := 0, new(fmp.Fmpz).Set(t)
+		for !t2.Equals(BigOne) {
+			t2.Mul(t2, t2).ModZ(p)
+			i++
+		}
+
+		b := new(fmp.Fmpz).Set(c)
+		for j := 0; j < m-i-1; j++ {
+			b.Mul(b, b).ModZ(p)
+		}
+
+		m = i
+		c.Mul(b, b).ModZ(p)
+		t.Mul(t, c).ModZ(p)
+		r.Mul(r, b).ModZ(p)
+	}
+
+	return r
+}
+
+// SqrtModPrimePower returns a square root of a modulo p^k for an odd prime p by Hensel lifting a
+// root modulo p, or nil if there is none. Roots of multiples of p are only found for k = 1.
+func SqrtModPrimePower(a, p *fmp.Fmpz, k int) *fmp.Fmpz {
+	r := SqrtMod(a, p)
+	if r == nil || k == 1 {
+		return r
+	}
+
+	if r.IsZero() {
+		return nil
+	}
+
+	// Newton's iteration r = r - (r^2 - a) / 2r gains one power of p per step.
+	pk := new(fmp.Fmpz).Set(p)
+	for i := 1; i < k; i++ {
+		pk.Mul(pk, p)
+
+		f := new(fmp.Fmpz).Mul(r, r)
+		f.Sub(f, a)
+		inv := new(fmp.Fmpz).ModInverse(new(fmp.Fmpz).Mul(r, BigTwo), pk)
+		f.Mul(f, inv)
+		r.Sub(r, f).Mod(r, pk)
+	}
+
+	return r
+}
+
+// SquareRootsMod returns every square root of c modulo the product of primes, repeated primes
+// are taken as prime powers. It returns nil if c is not a square modulo one of them.
+func SquareRootsMod(c *fmp.Fmpz, primes []*fmp.Fmpz) []*fmp.Fmpz {
+	var (
+		ps []*fmp.Fmpz
+		ks []int
+	)
+
+outer:
+	for _, p := range primes {
+		for i, q := range ps {
+			if q.Equals(p) {
+				ks[i]++
+				continue outer
+			}
+		}
+
+		ps, ks = append(ps, p), append(ks, 1)
+	}
+
+	var (
+		roots []*fmp.Fmpz
+		m     *fmp.Fmpz
+	)
+
+	for i, p := range ps {
+		pk := new(fmp.Fmpz).ExpXI(p, ks[i])
+
+		var r *fmp.Fmpz
+		switch {
+		case p.Equals(BigTwo) && ks[i] == 1:
+			r = new(fmp.Fmpz).Mod(c, BigTwo)
+		case !p.Equals(BigTwo):
+			r = SqrtModPrimePower(new(fmp.Fmpz).Mod(c, pk), p, ks[i])
+		}
+
+		if r == nil {
+			return nil
+		}
+
+		rs := []*fmp.Fmpz{r}
+		if neg := new(fmp.Fmpz).Sub(pk, r); !neg.Mod(neg, pk).Equals(r) {
+			rs = append(rs, neg)
+		}
+
+		if m == nil {
+			roots, m = rs, pk
+			continue
+		}
+
+		var next []*fmp.Fmpz
+		for _, x := range roots {
+			for _, r := range rs {
+				next = append(next, SolveCRT([][]*fmp.Fmpz{{x, m}, {r, pk}}))
+			}
+		}
+
+		roots = next
+		m = new(fmp.Fmpz).Mul(m, pk)
+	}
+
+	return roots
+}
+
+// smallFactors returns the prime factors of g in increasing order with their multiplicities. g
+// must fit in a machine word.
+func smallFactors(g uint64) ([]uint64, []int) {
+	var (
+		fs   []uint64
+		mult []int
+	)
+
+	for r := uint64(2); r*r <= g; r++ {
+		if g%r != 0 {
+			continue
+		}
+
+		fs, mult = append(fs, r), append(mult, 0)
+		for g%r == 0 {
+			mult[len(mult)-1]++
+			g /= r
+		}
+	}
+
+	if g > 1 {
+		fs, mult = append(fs, g), append(mult, 1)
+	}
+
+	return fs, mult
+}
+
+// isResidue returns true if a is an r-th power residue modulo p where r divides p - 1.
+func isResidue(a, r, p *fmp.Fmpz) bool {
+	e := new(fmp.Fmpz).Sub(p, BigOne)
+	e.Div(e, r)
+
+	return new(fmp.Fmpz).Exp(a, e, p).Equals(BigOne)
+}
+
+// unityRoot returns an element of order exactly r^k modulo p where r^k divides p - 1.
+func unityRoot(r *fmp.Fmpz, k int, p *fmp.Fmpz) *fmp.Fmpz {
+	var (
+		rk  = new(fmp.Fmpz).ExpXI(r, k)
+		rk1 = new(fmp.Fmpz).ExpXI(r, k-1)
+		e   = new(fmp.Fmpz).Sub(p, BigOne)
+	)
+
+	e.Div(e, rk)
+	for h := fmp.NewFmpz(2); ; h.AddI(1) {
+		z := new(fmp.Fmpz).Exp(h, e, p)
+		if !new(fmp.Fmpz).Exp(z, rk1, p).Equals(BigOne) {
+			return z
+		}
+	}
+}
+
+// rthRoot returns an r-th root of the r-th power residue a modulo p for a prime r dividing p - 1
+// using the Adleman-Manders-Miller algorithm, which is Tonelli-Shanks for r = 2.
+func rthRoot(a, r, p *fmp.Fmpz) *fmp.Fmpz {
+	if r.Equals(BigTwo) {
+		return SqrtMod(a, p)
+	}
+
+	// p - 1 = r^t * s with s coprime to r.
+	var (
+		s = new(fmp.Fmpz).Sub(p, BigOne)
+		t = 0
+	)
+
+	for new(fmp.Fmpz).Mod(s, r).IsZero() {
+		s.Div(s, r)
+		t++
+	}
+
+	// alpha with r * alpha = 1 mod s so that (a^alpha)^r = a * b with b in the r-Sylow subgroup.
+	alpha := fmp.NewFmpz(1)
+	if !s.Equals(BigOne) {
+		alpha.ModInverse(r, s)
+	}
+
+	var (
+		ra = new(fmp.Fmpz).Mul(r, alpha)
+		b  = new(fmp.Fmpz).Exp(a, ra.Sub(ra, BigOne), p)
+		zt = unityRoot(r, t, p)
+		w  = new(fmp.Fmpz).Exp(zt, new(fmp.Fmpz).ExpXI(r, t-1), p)
+		h  = fmp.NewFmpz(1)
+	)
+
+	// Find h with h^r = b^-1 one r-adic digit at a time, after step i b has order dividing
+	// r^(t-1-i).
+	for i := 1; i < t; i++ {
+		d := new(fmp.Fmpz).Exp(b, new(fmp.Fmpz).ExpXI(r, t-1-i), p)
+
+		// d * w^j = 1 for some 0 <= j < r.
+		j := fmp.NewFmpz(0)
+		for wj := new(fmp.Fmpz).Set(d); !wj.Equals(BigOne) && j.Cmp(r) < 0; wj.Mul(wj, w).ModZ(p) {
+			j.AddI(1)
+		}
+
+		b.Mul(b, new(fmp.Fmpz).Exp(zt, new(fmp.Fmpz).Mul(r, j), p)).ModZ(p)
+		h.Mul(h, new(fmp.Fmpz).Exp(zt, j, p)).ModZ(p)
+		zt.Exp(zt, r, p)
+	}
+
+	x := new(fmp.Fmpz).Exp(a, alpha, p)
+	return x.Mul(x, h).ModZ(p)
+}
+
+// NthRootsMod returns all the solutions x of x^e = c modulo the prime p. There are either none or
+// gcd(e, p - 1) of them, an error is returned when there would be more than MaxNthRoots.
+func NthRootsMod(c, e, p *fmp.Fmpz) ([]*fmp.Fmpz, error) {
+	c = new(fmp.Fmpz).Mod(c, p)
+	if c.IsZero() {
+		return []*fmp.Fmpz{new(fmp.Fmpz)}, nil
+	}
+
+	var (
+		pm1 = new(fmp.Fmpz).Sub(p, BigOne)
+		g   = new(fmp.Fmpz).GCD(e, pm1)
+	)
+
+	if g.Cmp(fmp.NewFmpz(MaxNthRoots)) > 0 {
+		return nil, fmt.Errorf("%v has too many e-th roots of unity modulo p", g)
+	}
+
+	if !isResidue(c, g, p) {
+		return nil, nil
+	}
+
+	// Take a g-th root one prime factor of g at a time. Within a prime power an r-th root may not
+	// be a residue of the next power, one of its multiples by an r-th root of unity is.
+	var (
+		y      = new(fmp.Fmpz).Set(c)
+		zeta   = fmp.NewFmpz(1)
+		fs, ks = smallFactors(g.Uint64())
+	)
+
+	for f, rv := range fs {
+		var (
+			r = new(fmp.Fmpz).SetUint64(rv)
+			k = ks[f]
+			z = unityRoot(r, k, p)
+			w = new(fmp.Fmpz).Exp(z, new(fmp.Fmpz).ExpXI(r, k-1), p)
+		)
+
+		zeta.Mul(zeta, z).ModZ(p)
+
+		for i := k - 1; i >= 0; i-- {
+			y = rthRoot(y, r, p)
+			for ri := new(fmp.Fmpz).ExpXI(r, i); i > 0 && !isResidue(y, ri, p); {
+				y.Mul(y, w).ModZ(p)
+			}
+		}
+	}
+
+	// y^g = c so x = y^u for u = (e / g)^-1 mod (p - 1) / g has x^e = c.
+	var (
+		eg = new(fmp.Fmpz).Div(e, g)
+		pg = new(fmp.Fmpz).Div(pm1, g)
+		u  = new(fmp.Fmpz).ModInverse(eg, pg)
+		x  = new(fmp.Fmpz).Exp(y, u, p)
+	)
+
+	if pg.Equals(BigOne) {
+		x.Set(y)
+	}
+
+	// Every root is x times a g-th root of unity, zeta generates them.
+	roots := make([]*fmp.Fmpz, 0, g.Int64())
+	for i := int64(0); i < g.Int64(); i++ {
+		roots = append(roots, new(fmp.Fmpz).Set(x))
+		x.Mul(x, zeta).ModZ(p)
+	}
+
+	return roots, nil
+}
@@ -0,0 +1,94 @@
+package ln
+
+import (
+	fmp "github.com/sourcekris/goflint"
+)
+
+// linear returns the polynomial x - r modulo n.
+func linear(r, n *fmp.Fmpz, mctx *fmp.FmpzModCtx) *fmp.FmpzModPoly {
+	c := new(fmp.Fmpz).Mod(r, n)
+	c.Sub(n, c).Mod(c, n)
+
+	return fmp.NewFmpzModPoly(mctx).SetCoeff(0, c).SetCoeffUI(1, 1)
+}
+
+// productLevel returns the pairwise products of ps, an odd polynomial out is carried up as is.
+func productLevel(ps []*fmp.FmpzModPoly, mctx *fmp.FmpzModCtx) []*fmp.FmpzModPoly {
+	next := make([]*fmp.FmpzModPoly, 0, (len(ps)+1)/2)
+	for i := 0; i < len(ps); i += 2 {
+		if i+1 == len(ps) {
+			next = append(next, ps[i])
+			break
+		}
+
+		next = append(next, fmp.NewFmpzModPoly(mctx).Mul(ps[i], ps[i+1]))
+	}
+
+	return next
+}
+
+// subproductTree returns the levels of the tree whose leaves are x - xs[i] and whose nodes are the
+// products of their children. The last level holds the product of all the leaves.
+func subproductTree(xs []*fmp.Fmpz, n *fmp.Fmpz, mctx *fmp.FmpzModCtx) [][]*fmp.FmpzModPoly {
+	leaves := make([]*fmp.FmpzModPoly, len(xs))
+	for i, x := range xs {
+		leaves[i] = linear(x, n, mctx)
+	}
+
+	tree := [][]*fmp.FmpzModPoly{leaves}
+	for len(tree[len(tree)-1]) > 1 {
+		tree = append(tree, productLevel(tree[len(tree)-1], mctx))
+	}
+
+	return tree
+}
+
+// PolyFromRoots returns the monic polynomial with the given roots, the product of x - roots[i]
+// modulo n. Only two levels of the product tree are held at once.
+func PolyFromRoots(roots []*fmp.Fmpz, n *fmp.Fmpz) *fmp.FmpzModPoly {
+	mctx := fmp.NewFmpzModCtx(n)
+	if len(roots) == 0 {
+		return fmp.NewFmpzModPoly(mctx).SetCoeffUI(0, 1)
+	}
+
+	level := make([]*fmp.FmpzModPoly, len(roots))
+	for i, r := range roots {
+		level[i] = linear(r, n, mctx)
+	}
+
+	for len(level) > 1 {
+		level = productLevel(level, mctx)
+	}
+
+	return level[0]
+}
+
+// MultipointEval returns f(xs[i]) for every point in xs. The remainders of f are taken down a
+// subproduct tree so the cost is that of O(log(len(xs))) polynomial multiplications of the size
+// of f rather than len(xs) evaluations.
+func MultipointEval(f *fmp.FmpzModPoly, xs []*fmp.Fmpz) []*fmp.Fmpz {
+	if len(xs) == 0 {
+		return nil
+	}
+
+	n := f.GetMod()
+	tree := subproductTree(xs, n, fmp.NewFmpzModCtx(n))
+
+	_, r := f.DivRem(tree[len(tree)-1][0])
+	rems := []*fmp.FmpzModPoly{r}
+	for l := len(tree) - 2; l >= 0; l-- {
+		next := make([]*fmp.FmpzModPoly, len(tree[l]))
+		for i, node := range tree[l] {
+			_, next[i] = rems[i/2].DivRem(node)
+		}
+
+		rems = next
+	}
+
+	vals := make([]*fmp.Fmpz, len(xs))
+	for i, r := range rems {
+		vals[i] = new(fmp.Fmpz).Set(r.GetCoeff(0))
+	}
+
+	return vals
+}
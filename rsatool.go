@@ -1,18 +1,27 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/sourcekris/goRsaTool/attacks"
 	"github.com/sourcekris/goRsaTool/attacks/jwtmodulus"
+	"github.com/sourcekris/goRsaTool/attacks/roca"
 	"github.com/sourcekris/goRsaTool/attacks/signatures"
 	"github.com/sourcekris/goRsaTool/keys"
 	"github.com/sourcekris/goRsaTool/ln"
+	"github.com/sourcekris/goRsaTool/solver"
 	"github.com/sourcekris/goRsaTool/utils"
 
 	fmp "github.com/sourcekris/goflint"
@@ -31,50 +40,97 @@ var (
 	primeArg       = fset.String("p", "", "One of the primes. If provided will shortcut the attack phase and produce a private key.")
 	dArg           = fset.String("d", "", "Give d in createkey mode to create a private key.")
 	d0Arg          = fset.String("d0", "", "Give LSBs of d, used in partiald attacks.")
+	dKnownBits     = fset.Int("dbits", 0, "Number of known bits in the d_high, dp_high or dp_low key fields, used in the partiald attack. Defaults to the size of the field.")
+	pKnownBits     = fset.Int("pbits", 0, "Number of known bits in the p_high or p_low key fields, used in the partialp attack. Defaults to the size of the field.")
+	crtMaxBits     = fset.Int("crtbits", 0, "Size in bits of the largest dp or dq searched for by the smallcrt attack. Defaults to 32.")
+	factorBaseSize = fset.Int("factorbase", 0, "Number of primes in the factor base of the dixons attack. Defaults to a size suited to the modulus.")
 	cipherText     = fset.String("ciphertext", "", "An RSA encrypted binary file to decrypt, necessary for certain attacks.")
 	numP           = fset.Int("numprimes", 2, "Number of primes expected to be factored.")
 	keyList        = fset.String("keylist", "", "Comma seperated list of keys for multi-key attacks.")
+	keyDir         = fset.String("keydir", "", "Directory of keys for multi-key attacks (e.g. batchgcd), files that are not keys are skipped.")
 	ctList         = fset.String("ctlist", "", "Comma seperated list of ciphertext binaries for multi-key attacks.")
 	ptList         = fset.String("ptlist", "", "Comma sepereated list of plaintext files for use in signature mode.")
 	sigList        = fset.String("siglist", "", "Comma seperated list of signatures files.")
 	jwtList        = fset.String("jwtlist", "", "Comma seperated list of files containing JWTs.")
 	hintList       = fset.String("hintlist", "", "Comma seperated list of hints.")
 	bruteMax       = fset.String("brutemax", "4096", "Maximum value for brute force related attacks (e.g. apbq attack).")
+	unknownBytes   = fset.Int("unknownbytes", 0, "Number of unknown bytes following the known plaintext in the stereotyped attack, 0 tries every length when the attack is selected with -attack.")
+	jwkOutput      = fset.Bool("jwk", false, "Also print recovered private keys as JWKs, e.g. for forging JWTs.")
+	pkcs8Output    = fset.Bool("pkcs8", false, "Also print recovered private keys in PKCS#8 format.")
+	password       = fset.String("password", "", "Password to decrypt an encrypted PEM or PKCS#8 private key.")
+	wordList       = fset.String("wordlist", "", "File of passwords, one per line, to brute force the password of an encrypted private key.")
 	attack         = fset.String("attack", "all", "Specific attack to try. Specify \"all\" for everything that works unnatended.")
-	list           = fset.Bool("list", false, "List the attacks supported by the attack flag.")
+	list           = fset.Bool("list", false, "List the attacks supported by the attack flag and the inputs each one requires.")
+	workers        = fset.Int("workers", runtime.NumCPU(), "Maximum number of attacks to run in parallel when the attack is \"all\" and of goroutines used by attacks that split up their work.")
+	attackList     = fset.String("attacks", "", "Comma seperated list of attacks to run when the attack is \"all\".")
+	skipList       = fset.String("skip", "", "Comma seperated list of attacks to skip when the attack is \"all\".")
+	timeout        = fset.Duration("timeout", 0, "Maximum time each attack may run for (e.g. 30s, 10m), overrides the default timeouts.")
+	attackTimeouts = fset.String("timeout-per-attack", "", "Comma seperated list of name=duration timeouts for specific attacks (e.g. ecm=1h,fermat=30s).")
 	logger         *log.Logger
 )
 
-// unnatended will run all supported attacks against t that are listed as working in unnatended mode.
-func unnatended(t []*keys.RSA) []error {
-	var errs []error
+// listAttacks returns a table of the registered attacks and the inputs each one requires.
+func listAttacks() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ATTACK\tMULTIKEY\tUNNATENDED\tCOST\tREQUIRES")
 	for _, a := range attacks.SupportedAttacks.Supported {
-		if a.Unnatended {
-			if err := attacks.SupportedAttacks.Execute(a.Name, t); err != nil {
-				errs = append(errs, err)
-			}
-			for _, k := range t {
-				if k.Key.D != nil {
-					if k.Verbose {
-						logger.Printf("key factored with attack: %v\n", a.Name)
-					}
-					return nil
-				}
-			}
+		fmt.Fprintf(w, "%s\t%v\t%v\t%v\t%v\n", a.Name, a.SupportsMulti, a.Unnatended, a.Cost, a.Requires)
+	}
+	w.Flush()
+
+	return b.String()
+}
+
+// parseTimeouts parses a comma seperated list of name=duration pairs.
+func parseTimeouts(s string) (map[string]time.Duration, error) {
+	ts := make(map[string]time.Duration)
+	for _, t := range fileList(s) {
+		name, d, ok := strings.Cut(t, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected name=duration but got %q", t)
+		}
+
+		dur, err := time.ParseDuration(d)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing timeout for attack %s: %w", name, err)
 		}
+
+		ts[name] = dur
 	}
 
-	return errs
+	return ts, nil
 }
 
-// listAttacks returns a string containing the list of registered attacks.
-func listAttacks() string {
-	var res string
-	for _, a := range attacks.SupportedAttacks.Supported {
-		res = fmt.Sprintf("%s%s\n", res, a.Name)
+// configureAttacks returns the attacks to run after applying the -attacks, -skip, -timeout and
+// -timeout-per-attack flags. The attack selection only applies when all attacks are requested.
+func configureAttacks(all bool) (*attacks.Attacks, error) {
+	var include, skip []string
+	if all {
+		include, skip = fileList(*attackList), fileList(*skipList)
+	}
+
+	as, err := attacks.SupportedAttacks.Select(include, skip)
+	if err != nil {
+		return nil, err
+	}
+
+	if *timeout > 0 {
+		as.SetTimeout(*timeout)
 	}
 
-	return res
+	ts, err := parseTimeouts(*attackTimeouts)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, d := range ts {
+		if err := as.SetAttackTimeout(name, d); err != nil {
+			return nil, err
+		}
+	}
+
+	return as, nil
 }
 
 // fileList returns a list of filenames or nil.
@@ -89,6 +145,76 @@ func fileList(fl string) []string {
 	return nil
 }
 
+// dirList returns the names of the regular files in the directory dir.
+func dirList(dir string) ([]string, error) {
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var fs []string
+	for _, de := range des {
+		if de.Type().IsRegular() {
+			fs = append(fs, filepath.Join(dir, de.Name()))
+		}
+	}
+
+	return fs, nil
+}
+
+// importOptions returns the options applied to every imported key from the command line flags.
+func importOptions() (*keys.ImportOptions, error) {
+	opts := &keys.ImportOptions{
+		Password:       []byte(*password),
+		WordList:       *wordList,
+		UnknownBytes:   *unknownBytes,
+		DKnownBits:     *dKnownBits,
+		PKnownBits:     *pKnownBits,
+		CRTMaxBits:     *crtMaxBits,
+		FactorBaseSize: *factorBaseSize,
+		NumPrimes:      *numP,
+		Workers:        *workers,
+		PastPrimesFile: *pastPrimesFile,
+		Verbose:        *verboseMode,
+		Log:            logger,
+	}
+
+	if *d0Arg != "" {
+		d0, ok := new(fmp.Fmpz).SetString(*d0Arg, 0)
+		if !ok {
+			return nil, errors.New("failed parsing -d0 flag as an integer")
+		}
+
+		opts.DLSB = d0.Bytes()
+	}
+
+	if *primeArg != "" {
+		p, ok := new(fmp.Fmpz).SetString(*primeArg, 0)
+		if !ok {
+			return nil, errors.New("failed parsing -p flag as an integer")
+		}
+
+		opts.Prime = p
+	}
+
+	if *bruteMax != "" {
+		bm, err := strconv.Atoi(*bruteMax)
+		if err != nil {
+			return nil, errors.New("failed parsing -brutemax as an integer")
+		}
+
+		opts.BruteMax = int64(bm)
+	}
+
+	if *hintList != "" {
+		for _, hint := range strings.Split(*hintList, ",") {
+			opts.Hints = append(opts.Hints, ln.FmpString(hint))
+		}
+	}
+
+	return opts, nil
+}
+
 func createKeyFromArgs() (*keys.RSA, error) {
 	var cliCt []byte
 	if *cArg != "" {
@@ -134,6 +260,21 @@ func main() {
 		klist = append(klist, *keyFile)
 	}
 
+	// Add every file in the -keydir directory, remembering them so that non key files are skipped.
+	inDir := make(map[string]bool)
+	if *keyDir != "" {
+		dlist, err := dirList(*keyDir)
+		if err != nil {
+			logger.Fatalf("failed reading key directory: %v", err)
+		}
+
+		for _, kf := range dlist {
+			inDir[kf] = true
+		}
+
+		klist = append(klist, dlist...)
+	}
+
 	// If no key file or key file list are provided, do we have n and e to make a key up on the fly with?
 	if klist == nil {
 		if *modulusArg != "" && *exponentArg != "" {
@@ -149,54 +290,17 @@ func main() {
 
 	// We got one or more keys to work on, so lets do that.
 	if len(klist) > 0 || useFlagsForKey {
+		opts, err := importOptions()
+		if err != nil {
+			logger.Fatal(err)
+		}
+
 		var rsaKeys []*keys.RSA
 		for i, kf := range klist {
-			var (
-				targetRSA *keys.RSA
-				nonPemKey bool
-				err       error
-			)
-
-			if !useFlagsForKey {
-				kb, err := os.ReadFile(kf)
-				if err != nil {
-					log.Fatal(err)
-				}
-				targetRSA, err = keys.ImportKey(kb)
-				if err != nil {
-					// Failed to read a valid PEM key. Maybe it is an integer list type key?
-					targetRSA, err = keys.ImportIntegerList(kb)
-					if err != nil {
-						logger.Fatalf("failed reading key file: %v", err)
-					}
-
-					nonPemKey = true
-				}
-			} else {
-				// Also include c if it is provided on command line.
-				var cliCt []byte
-				if *cArg != "" {
-					cliCt = ln.NumberToBytes(ln.FmpString(*cArg))
-				}
-				targetRSA, err = keys.NewRSA(
-					keys.PrivateFromPublic(
-						&keys.FMPPublicKey{
-							N: ln.FmpString(*modulusArg),
-							E: ln.FmpString(*exponentArg),
-						}), cliCt, nil, "", false)
-				if err != nil {
-					logger.Fatalf("failed converting modulus and exponent into an RSA key: %v", err)
-				}
-			}
-
-			targetRSA.PastPrimesFile = *pastPrimesFile
-			targetRSA.Verbose = *verboseMode
-
-			var (
-				c  []byte
-				cf string
-			)
+			fo := *opts
+			fo.KeyFilename = kf
 
+			var cf string
 			switch {
 			case *cipherText != "":
 				cf = *cipherText
@@ -205,66 +309,59 @@ func main() {
 			}
 
 			if cf != "" {
-				c, err = utils.ReadCipherText(cf)
+				fo.CipherText, err = utils.ReadCipherText(cf)
 				if err != nil {
 					logger.Fatalf("failed reading ciphertext file: %v", err)
 				}
-
-				targetRSA.CipherText = c
 			}
 
-			if *d0Arg != "" {
-				d0, ok := new(fmp.Fmpz).SetString(*d0Arg, 0)
-				if !ok {
-					logger.Fatal("failed parsing -d0 flag as an integer")
-				}
-
-				targetRSA.DLSB = d0.Bytes()
-			}
+			var (
+				fileKeys  []*keys.RSA
+				nonPemKey bool
+			)
 
-			if *primeArg != "" {
-				p, ok := new(fmp.Fmpz).SetString(*primeArg, 0)
-				if !ok {
-					logger.Fatal("failed parsing -p flag as an integer")
+			if !useFlagsForKey {
+				kb, err := os.ReadFile(kf)
+				if err != nil {
+					log.Fatal(err)
 				}
 
-				targetRSA.Key.Primes = append(targetRSA.Key.Primes, p)
-			}
+				fileKeys, nonPemKey, err = keys.Import(kb, &fo)
+				if err != nil && inDir[kf] {
+					if *verboseMode {
+						logger.Printf("skipping %s: %v", kf, err)
+					}
+					continue
+				}
 
-			if *bruteMax != "" {
-				bm, err := strconv.Atoi(*bruteMax)
 				if err != nil {
-					logger.Fatal("failed parsing -brutemax as an integer")
+					logger.Fatalf("failed reading key file: %v", err)
 				}
-				targetRSA.BruteMax = int64(bm)
-			}
-
-			if *hintList != "" {
-				hints := strings.Split(*hintList, ",")
-				if len(hints) == 0 {
-					logger.Fatal("expected at least 1 hint when -hintlist specified")
+			} else {
+				targetRSA, err := createKeyFromArgs()
+				if err != nil {
+					logger.Fatal(err)
 				}
 
-				for _, hint := range hints {
-					targetRSA.Hints = append(targetRSA.Hints, ln.FmpString(hint))
-				}
+				fileKeys = fo.Apply([]*keys.RSA{targetRSA})
 			}
 
-			// Add the key filename, logger and expected number of primes to the key.
-			targetRSA.KeyFilename = kf
-			targetRSA.Log = logger
-			targetRSA.NumPrimes = *numP
+			for _, targetRSA := range fileKeys {
+				if *dumpKeyMode {
+					targetRSA.DumpKey()
 
-			if *dumpKeyMode {
-				targetRSA.DumpKey()
+					if roca.IsVulnerable(targetRSA.Key.N) {
+						fmt.Println("key has the ROCA fingerprint and is vulnerable to CVE-2017-15361, try -attack roca")
+					}
 
-				if nonPemKey && targetRSA.Key.PublicKey.E != nil {
-					// The input was an integer list key so the user might actually want a PEM dump.
-					fmt.Println(keys.EncodeFMPPublicKey(targetRSA.Key.PublicKey))
+					if nonPemKey && targetRSA.Key.PublicKey.E != nil {
+						// The input was an integer list key so the user might actually want a PEM dump.
+						fmt.Println(keys.EncodeFMPPublicKey(targetRSA.Key.PublicKey))
+					}
 				}
-			}
 
-			rsaKeys = append(rsaKeys, targetRSA)
+				rsaKeys = append(rsaKeys, targetRSA)
+			}
 		}
 
 		if *dumpKeyMode {
@@ -272,29 +369,54 @@ func main() {
 			return
 		}
 
-		var errs []error
+		// Stop any running attacks cleanly on Ctrl-C.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		as, err := configureAttacks(*attack == "all" && *primeArg == "")
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		sv := &solver.Solver{Attacks: as, Workers: *workers}
+		if *verboseMode {
+			sv.Logger = logger
+		}
+
+		var rep *solver.Report
 		switch {
 		case *attack == "all" && *primeArg != "":
-			errs = append(errs, attacks.SupportedAttacks.Execute("knownprime", rsaKeys))
+			rep, err = sv.SolveWith(ctx, "knownprime", rsaKeys)
 		case *attack == "all":
-			errs = unnatended(rsaKeys)
-		case attacks.SupportedAttacks.IsSupported(*attack):
-			if *keyList != "" && !attacks.SupportedAttacks.SupportsMulti(*attack) {
-				logger.Println("-keylist flag used for attack that does not support multikeys - only the first key will be attacked.")
+			rep, err = sv.Solve(ctx, rsaKeys)
+		case as.IsSupported(*attack):
+			if (*keyList != "" || *keyDir != "") && !as.SupportsMulti(*attack) {
+				logger.Println("-keylist or -keydir flag used for attack that does not support multikeys - only the first key will be attacked.")
 			}
-			errs = append(errs, attacks.SupportedAttacks.Execute(*attack, rsaKeys))
+			rep, err = sv.SolveWith(ctx, *attack, rsaKeys)
 		default:
-			errs = []error{fmt.Errorf("unsupported attack: %v. Use -list to see a list of supported attacks", *attack)}
+			err = fmt.Errorf("unsupported attack: %v. Use -list to see a list of supported attacks", *attack)
 		}
 
-		for _, e := range errs {
-			if e != nil {
-				logger.Fatal(e)
-			}
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		if *attack == "all" && rep.Solved() {
+			logger.Printf("key solved with attack: %v\n", rep.Winner.Name)
 		}
 
 		// Were we able to solve for any of the private keys or ciphertexts?
 		utils.ReportResults(rsaKeys)
+		if *jwkOutput {
+			utils.ReportJWKs(rsaKeys)
+		}
+		if *pkcs8Output {
+			utils.ReportPKCS8(rsaKeys)
+		}
+		if len(rsaKeys) > 1 {
+			utils.ReportSharedFactors(rsaKeys)
+		}
 
 		return
 	}
@@ -0,0 +1,136 @@
+// Package solver recovers RSA private keys and plaintexts using the registered attacks. It is
+// the library equivalent of running rsatool against a set of keys.
+package solver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sourcekris/goRsaTool/attacks"
+	"github.com/sourcekris/goRsaTool/keys"
+
+	// Register every attack with attacks.SupportedAttacks.
+	_ "github.com/sourcekris/goRsaTool/attacks/all"
+)
+
+// Solver runs attacks against RSA keys.
+type Solver struct {
+	// Attacks are the attacks to try. attacks.SupportedAttacks is used if nil.
+	Attacks *attacks.Attacks
+	// Timeout limits how long Solve runs for in total. Each attack is still limited by its own
+	// timeout. Zero means no limit.
+	Timeout time.Duration
+	// Workers is the maximum number of attacks to run at once, one per CPU if less than 1.
+	Workers int
+	// Logger receives the result of each attack. Nothing is logged if nil.
+	Logger *log.Logger
+}
+
+// Report describes the outcome of solving a set of keys.
+type Report struct {
+	// Winner is the result of the attack that solved the keys, nil if none did.
+	Winner *attacks.Result
+	// Results holds the result of every attack that was tried or skipped.
+	Results []*attacks.Result
+	// Keys are the solved keys with the recovered values filled in.
+	Keys []*keys.RSA
+}
+
+// Solved returns true if an attack succeeded against the keys.
+func (r *Report) Solved() bool {
+	return r != nil && r.Winner != nil
+}
+
+// Err returns nil if the keys were solved or an error describing why each attack failed.
+func (r *Report) Err() error {
+	if r.Solved() {
+		return nil
+	}
+
+	var errs []error
+	for _, res := range r.Results {
+		if res.Status != attacks.StatusNotApplicable {
+			errs = append(errs, res.Err())
+		}
+	}
+
+	if len(errs) == 0 {
+		return errors.New("none of the attacks are applicable to the key")
+	}
+
+	return errors.Join(errs...)
+}
+
+// New returns a Solver that uses all of the supported attacks.
+func New() *Solver {
+	return &Solver{Attacks: attacks.SupportedAttacks}
+}
+
+func (s *Solver) attacks() *attacks.Attacks {
+	if s.Attacks == nil {
+		return attacks.SupportedAttacks
+	}
+
+	return s.Attacks
+}
+
+func (s *Solver) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.Timeout > 0 {
+		return context.WithTimeout(ctx, s.Timeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+func (s *Solver) log(res *attacks.Result) {
+	if s.Logger != nil {
+		s.Logger.Println(res)
+	}
+}
+
+// Solve runs all of the applicable unnatended attacks against ks, cheapest first, until one of
+// them succeeds. ks is updated with the values recovered by the winning attack. The Report is
+// returned even when err is non-nil so that the result of each attack can be inspected.
+func (s *Solver) Solve(ctx context.Context, ks []*keys.RSA) (*Report, error) {
+	if len(ks) == 0 {
+		return nil, errors.New("no keys to solve")
+	}
+
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	winner, results := s.attacks().ExecuteParallel(ctx, ks, s.Workers)
+	for _, res := range results {
+		s.log(res)
+	}
+
+	r := &Report{Winner: winner, Results: results, Keys: ks}
+	return r, r.Err()
+}
+
+// SolveWith runs only the named attack against ks.
+func (s *Solver) SolveWith(ctx context.Context, name string, ks []*keys.RSA) (*Report, error) {
+	if len(ks) == 0 {
+		return nil, errors.New("no keys to solve")
+	}
+
+	if !s.attacks().IsSupported(name) {
+		return nil, fmt.Errorf("unsupported attack: %v", name)
+	}
+
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	res := s.attacks().Execute(ctx, name, ks)
+	s.log(res)
+
+	r := &Report{Results: []*attacks.Result{res}, Keys: ks}
+	if res.Succeeded() {
+		r.Winner = res
+	}
+
+	return r, res.Err()
+}
@@ -0,0 +1,92 @@
+package solver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sourcekris/goRsaTool/attacks"
+	"github.com/sourcekris/goRsaTool/keys"
+
+	fmp "github.com/sourcekris/goflint"
+)
+
+func TestSolve(t *testing.T) {
+	slow := func(ctx context.Context, _ []*keys.RSA) *attacks.Result {
+		<-ctx.Done()
+		return attacks.Stopped(ctx.Err())
+	}
+
+	fast := func(_ context.Context, ks []*keys.RSA) *attacks.Result {
+		ks[0].PlainText = []byte("flag")
+		return attacks.Solved(ks[0])
+	}
+
+	tt := []struct {
+		name    string
+		attacks map[string]*attacks.Attack
+		timeout time.Duration
+		solved  bool
+		wantErr bool
+	}{
+		{
+			name: "solved by the fast attack",
+			attacks: map[string]*attacks.Attack{
+				"fast": {Name: "fast", Unnatended: true, Timeout: time.Minute, F: fast},
+				"slow": {Name: "slow", Unnatended: true, Timeout: time.Minute, F: slow},
+			},
+			solved: true,
+		},
+		{
+			name: "solver timeout stops slow attacks",
+			attacks: map[string]*attacks.Attack{
+				"slow": {Name: "slow", Unnatended: true, Timeout: time.Minute, F: slow},
+			},
+			timeout: 100 * time.Millisecond,
+			wantErr: true,
+		},
+		{
+			name: "no applicable attacks",
+			attacks: map[string]*attacks.Attack{
+				"fast": {Name: "fast", Unnatended: true, Timeout: time.Minute, Requires: attacks.Requirements{CipherText: true}, F: fast},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		as := attacks.NewAttacks()
+		for _, at := range tc.attacks {
+			as.Register(at)
+		}
+
+		k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: fmp.NewFmpz(35), E: fmp.NewFmpz(3)}), nil, nil, "", false)
+
+		s := &Solver{Attacks: as, Timeout: tc.timeout, Workers: 2}
+		rep, err := s.Solve(context.Background(), []*keys.RSA{k})
+		if (err != nil) != tc.wantErr {
+			t.Errorf("Solve() failed: %s expected error %v got %v", tc.name, tc.wantErr, err)
+		}
+
+		if rep.Solved() != tc.solved {
+			t.Errorf("Solve() failed: %s expected solved %v got %v", tc.name, tc.solved, rep.Solved())
+		}
+
+		if tc.solved && string(rep.Keys[0].PlainText) != "flag" {
+			t.Errorf("Solve() failed: %s expected plaintext to be recovered got %q", tc.name, rep.Keys[0].PlainText)
+		}
+	}
+}
+
+func TestSolveWith(t *testing.T) {
+	s := &Solver{Attacks: attacks.NewAttacks()}
+	k, _ := keys.NewRSA(keys.PrivateFromPublic(&keys.FMPPublicKey{N: fmp.NewFmpz(35), E: fmp.NewFmpz(3)}), nil, nil, "", false)
+
+	if _, err := s.SolveWith(context.Background(), "nope", []*keys.RSA{k}); err == nil {
+		t.Errorf("SolveWith() failed: expected an error for an unsupported attack")
+	}
+
+	if _, err := s.Solve(context.Background(), nil); err == nil {
+		t.Errorf("Solve() failed: expected an error when given no keys")
+	}
+}
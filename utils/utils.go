@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"strings"
 	"unicode"
 
 	"github.com/sourcekris/goRsaTool/keys"
@@ -87,18 +88,121 @@ func EncodeAndPrintKey(n, e, d string) error {
 // ReportResults iterates a slice of keys and prints the privatekeys or plaintexts found.
 func ReportResults(ks []*keys.RSA) {
 	for _, k := range ks {
+		if k.Certificate != nil && (k.Key.D != nil || k.Key.Primes != nil) {
+			fmt.Printf("broke the key of the %v\n", k.Certificate)
+		}
+
 		if k.Key.D != nil && k.Key.Primes != nil {
 			fmt.Println(keys.EncodeFMPPrivateKey(&k.Key))
 		}
 
+		if k.PGP != nil && k.Key.D != nil && len(k.Key.Primes) == 2 {
+			if sk, err := keys.EncodePGPSecretKey(k); err != nil {
+				fmt.Printf("failed encoding the %v as a secret key: %v\n", k.PGP, err)
+			} else {
+				fmt.Println(sk)
+			}
+		}
+
 		if k.Key.D != nil && k.Key.Primes == nil {
 			fmt.Printf("recovered d but was unable to recover all the primes\nd = %v\n", k.Key.D)
 		}
 
+		if k.IsRabin() && k.Key.Primes != nil {
+			fmt.Println("rabin key (e = 2) has no private exponent, recovered the primes:")
+			for i, p := range k.Key.Primes {
+				fmt.Printf("prime[%d] = %v\n", i, p)
+			}
+		}
+
 		if len(k.PlainText) > 0 {
 			fmt.Printf("Recovered plaintext as an integer: %s\n", ln.BytesToNumber(k.PlainText))
 			fmt.Println("Recovered plaintext: ")
 			fmt.Println(string(k.PlainText))
 		}
+
+		if algo, sk, err := keys.PGPSessionKey(k.PlainText); err == nil {
+			fmt.Println("Recovered an OpenPGP session key, decrypt the message with:")
+			fmt.Printf("gpg --override-session-key %d:%X --decrypt\n", algo, sk)
+		}
+
+		if len(k.PlainTexts) > 1 {
+			fmt.Println("All candidate plaintexts, most likely first:")
+			for i, m := range k.PlainTexts {
+				fmt.Printf("%d: %q\n", i, m)
+			}
+		}
+	}
+}
+
+// reportPrivateKeys prints every private key recovered in ks using the encoding enc.
+func reportPrivateKeys(ks []*keys.RSA, format string, enc func(*keys.RSA) (string, error)) {
+	for _, k := range ks {
+		if k.Key.D == nil || len(k.Key.Primes) < 2 {
+			continue
+		}
+
+		s, err := enc(k)
+		if err != nil {
+			fmt.Printf("failed encoding %s as %s: %v\n", k.KeyFilename, format, err)
+			continue
+		}
+
+		fmt.Println(s)
+	}
+}
+
+// ReportJWKs prints every private key recovered in ks as a JWK keeping the key id of the original.
+func ReportJWKs(ks []*keys.RSA) {
+	reportPrivateKeys(ks, "a jwk", func(k *keys.RSA) (string, error) {
+		return keys.EncodeFMPPrivateKeyJWK(&k.Key, k.KeyID)
+	})
+}
+
+// ReportPKCS8 prints every private key recovered in ks in PKCS#8 format.
+func ReportPKCS8(ks []*keys.RSA) {
+	reportPrivateKeys(ks, "PKCS#8", func(k *keys.RSA) (string, error) {
+		return keys.EncodeFMPPrivateKeyPKCS8(&k.Key), nil
+	})
+}
+
+// ReportSharedFactors prints every prime found in more than one of the keys and the keys that it
+// factors, as found by the commonfactors or batchgcd attacks.
+func ReportSharedFactors(ks []*keys.RSA) {
+	type shared struct {
+		p     *fmp.Fmpz
+		names []string
+	}
+
+	var (
+		ss   []*shared
+		seen = make(map[string]*shared)
+	)
+
+	for i, k := range ks {
+		name := k.KeyFilename
+		if name == "" {
+			name = fmt.Sprintf("key %d", i)
+		}
+
+		for _, p := range k.Key.Primes {
+			s, ok := seen[p.String()]
+			if !ok {
+				s = &shared{p: p}
+				seen[p.String()] = s
+				ss = append(ss, s)
+			}
+
+			// Prime powers list the same prime more than once.
+			if len(s.names) == 0 || s.names[len(s.names)-1] != name {
+				s.names = append(s.names, name)
+			}
+		}
+	}
+
+	for _, s := range ss {
+		if len(s.names) > 1 {
+			fmt.Printf("shared factor %v breaks %d keys: %s\n", s.p, len(s.names), strings.Join(s.names, ", "))
+		}
 	}
 }